@@ -1,202 +1,661 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"math/rand"
 	"os"
+	"sort"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
+
+	"github.com/DSpeichert/vcg-auction/vcg"
 )
 
-// Agent's bid (mapping of allocation => utility)
-// index is a binary "flag", in which:
-// right-most bit is item 0, second from the right is item 1 and so on
-type Bid map[int64]float64
-
-// Contains bids for all agents (1..n)
-type BidSet []Bid
-
-func (bs BidSet) CopyExcludingAgent(agent int) (new_bs BidSet) {
-	new_bs = make(BidSet, len(bs)-1)
-	for a, bid := range bs {
-		if a < agent {
-			new_bs[a] = make(Bid)
-			for k, v := range bid {
-				new_bs[a][k] = v
-			}
-		} else if a > agent {
-			new_bs[a-1] = make(Bid)
-			for k, v := range bid {
-				new_bs[a-1][k] = v
-			}
-		}
+// errReported is returned for errors which were already printed, such as
+// invalid flags.
+var errReported = errors.New("error already reported")
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	switch err {
+	case nil, flag.ErrHelp:
+	case errReported:
+		os.Exit(1)
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	return
 }
 
-// Allocation: Agent x Item = Bool
-// Agent 0 is "nobody"
-type Allocation map[int]map[int]bool
-
-func (a Allocation) FindTotalUtility(bs BidSet) (u float64) {
-	for agent, items := range a {
-		var flags int64
-		for item, _ := range items {
-			flags = flags | 1<<uint(item)
+// run dispatches to the subcommand named by args[0]:
+//
+//	generate -n 4 -m 4 -o bids.json   write random bids
+//	solve -i bids.json                find the optimal allocation (of stdin without -i)
+//	price -i bids.json                find the allocation and its VCG prices
+//	batch -i auctions.json            solve a JSON array of auctions
+//	repl                              type bids and solve them interactively
+//	serve -addr :8080                 solve bids posted over HTTP
+//
+// Without a subcommand it solves and prices random bids for n agents and m
+// items, or the bids of -input.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "generate":
+			return runGenerate(args[1:], stdout, stderr)
+		case "solve":
+			return runSolve(args[1:], stdin, stdout, stderr, false)
+		case "price":
+			return runSolve(args[1:], stdin, stdout, stderr, true)
+		case "batch":
+			return runBatch(args[1:], stdin, stdout, stderr)
+		case "repl":
+			return runREPL(args[1:], stdin, stdout, stderr)
+		case "serve":
+			return runServe(args[1:], stdout, stderr)
 		}
-		if agent > 0 {
-			u += bs[agent][flags]
+	}
+	return runAuction(args, stdout, stderr)
+}
+
+// parseFlags parses args into fs, which reports errors itself.
+func parseFlags(fs *flag.FlagSet, args []string, stderr io.Writer) error {
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
 		}
+		return errReported
 	}
+	return nil
+}
+
+// solveConfig holds the flags shared by all commands which solve.
+type solveConfig struct {
+	output           *string
+	memoize          *bool
+	prune            *bool
+	verbose          *bool
+	max_estimate     *time.Duration
+	timeout          *time.Duration
+	deterministic    *bool
+	checkpoint       *string
+	checkpoint_every *time.Duration
+	reserves         *string
+	timings          *bool
+}
+
+func addSolveFlags(fs *flag.FlagSet) (cfg solveConfig) {
+	cfg.output = fs.String("output", "text", "output `format`: text or json")
+	cfg.memoize = fs.Bool("memoize", false, "solve with dynamic programming instead of enumerating all allocations")
+	cfg.prune = fs.Bool("prune", false, "skip branches of the search which cannot beat the best allocation found so far")
+	cfg.verbose = fs.Bool("v", false, "log every node of the search (tiny instances only)")
+	cfg.max_estimate = fs.Duration("max-estimate", 10*time.Minute, "refuse to search when it is estimated to take longer than `duration` (0 for no limit)")
+	cfg.deterministic = fs.Bool("deterministic", false, "search sequentially so the result depends only on the input")
+	cfg.timeout = fs.Duration("timeout", 0, "stop searching after `duration` and print the best allocation found so far (0 for no limit)")
+	cfg.checkpoint = fs.String("checkpoint", "", "save the state of the search to `file` periodically and resume from it if it exists")
+	cfg.checkpoint_every = fs.Duration("checkpoint-every", time.Minute, "save the checkpoint every `duration`")
+	cfg.reserves = fs.String("reserves", "", "read the reserve prices of the items from a CSV `file` of item,reserve rows")
+	cfg.timings = fs.Bool("timings", false, "print how long generating or loading the bids, finding the allocation and pricing took; JSON output becomes {\"solution\": ..., \"timings\": ...}")
 	return
 }
 
-func (a Allocation) FindTotalUtilityExceptAgent(bs BidSet, excluded_agent int) (u float64) {
-	for agent, items := range a {
-		var flags int64
-		for item, _ := range items {
-			flags = flags | 1<<uint(item)
+// validate checks the values of the flags and returns where to write
+// progress messages, see info.
+func (cfg solveConfig) validate(stdout, stderr io.Writer) (io.Writer, error) {
+	if *cfg.timeout < 0 {
+		return nil, fmt.Errorf("-timeout must not be negative, got %s", *cfg.timeout)
+	}
+	if *cfg.checkpoint != "" {
+		if *cfg.memoize {
+			return nil, errors.New("-checkpoint does not work with -memoize")
 		}
-		if agent > 0 && agent != excluded_agent {
-			u += bs[agent][flags]
+		if *cfg.checkpoint_every <= 0 {
+			return nil, fmt.Errorf("-checkpoint-every must be positive, got %s", *cfg.checkpoint_every)
 		}
 	}
-	return
+	return cfg.info(stdout, stderr)
+}
+
+// info returns where to write progress messages: with JSON output, stdout
+// only carries the solution.
+func (cfg solveConfig) info(stdout, stderr io.Writer) (io.Writer, error) {
+	switch *cfg.output {
+	case "text":
+		return stdout, nil
+	case "json":
+		return stderr, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format %q.", *cfg.output)
+	}
+}
+
+// checkEstimate prints how long searching n agents and m items is estimated
+// to take and refuses a search longer than -max-estimate, unless -timeout or
+// -checkpoint stop it early. It is called before the bids are generated, or
+// as soon as they are loaded, since both can take long for large m.
+func (cfg solveConfig) checkEstimate(n, m int, info io.Writer) error {
+	if *cfg.memoize {
+		return nil
+	}
+	nodes, estimate := vcg.EstimateComplexity(n, m)
+	fmt.Fprintf(info, "Searching %d allocations is estimated to take %s\n", nodes, estimate)
+	if *cfg.timeout == 0 && *cfg.checkpoint == "" && *cfg.max_estimate > 0 && estimate > *cfg.max_estimate {
+		return fmt.Errorf("Refusing to search for longer than %s, pass -max-estimate 0 to search anyway or -timeout to stop early.", *cfg.max_estimate)
+	}
+	return nil
 }
 
-func (a Allocation) Copy() (c Allocation) {
-	c = make(Allocation)
-	for k, v := range a {
-		c[k] = make(map[int]bool)
-		for k2, v2 := range v {
-			c[k][k2] = v2
+// runGenerate writes random bids as JSON.
+func runGenerate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	n := fs.Int("n", 0, "number of `agents`")
+	m := fs.Int("m", 0, "number of `items`")
+	bundles := fs.Int("bundles", 0, "let every agent bid on `k` random bundles instead of all of them, needed above 63 items")
+	max_size := fs.Int("max-bundle-size", 0, "draw the bundles of -bundles with at most `k` items (0 for any size)")
+	out := fs.String("o", "-", "write the bids to `file` (- for stdout), in binary if it ends in .bin")
+	seed := fs.Int64("seed", 0, "generate the bids from `seed` instead of a random one")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	wide := *m > vcg.MaxFlagItems
+	if wide {
+		if *bundles <= 0 {
+			return fmt.Errorf("more than %d items need -bundles, got %d items", vcg.MaxFlagItems, *m)
+		}
+		if isBinary(*out) {
+			return fmt.Errorf("the binary encoding holds at most %d items, got %d", vcg.MaxFlagItems, *m)
 		}
+		if *n < 1 {
+			return fmt.Errorf("n must be a positive integer, got %d", *n)
+		}
+	} else if _, _, err := parseArgs([]string{strconv.Itoa(*n), strconv.Itoa(*m)}); err != nil {
+		return err
 	}
-	return
+
+	s := effectiveSeed(fs, *seed)
+	fmt.Fprintf(stderr, "Using seed %d\n", s)
+	opts := vcg.GenOptions{Agents: *n, Items: *m, BundlesPerAgent: *bundles, MaxBundleSize: *max_size, Seed: s}
+	var save func(w io.Writer) error
+	if wide {
+		bs := vcg.GenerateWideBidSet(opts)
+		save = func(w io.Writer) error { return vcg.SaveWideBidSet(w, bs, *m) }
+	} else {
+		bs := vcg.GenerateBidSet(opts)
+		save = func(w io.Writer) error { return vcg.SaveBidSet(w, bs, *m) }
+		if isBinary(*out) {
+			save = func(w io.Writer) error { return vcg.SaveBidSetBinary(w, bs, *m) }
+		}
+	}
+	if *out == "-" {
+		return save(stdout)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err = save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-type Solution struct {
-	Allocation    Allocation
-	TotalUtility  float64
-	PricePerAgent []float64
+// runSolve solves the bids of a file, or of stdin, and, if price is set,
+// prices them.
+func runSolve(args []string, stdin io.Reader, stdout, stderr io.Writer, price bool) error {
+	name := "solve"
+	if price {
+		name = "price"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	input := fs.String("i", "-", "read bids from a JSON, CSV (*.csv) or binary (*.bin) `file` (- for stdin, JSON or CSV by its first character)")
+	cfg := addSolveFlags(fs)
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	info, err := cfg.validate(stdout, stderr)
+	if err != nil {
+		return err
+	}
+	var bs vcg.BidSet
+	var wide vcg.WideBidSet
+	var n, m int
+	start := time.Now()
+	if *input == "-" {
+		if bs, wide, n, m, err = sniffBids(stdin); err != nil {
+			return fmt.Errorf("stdin: %s", err)
+		}
+		fmt.Fprintf(info, "Using n = %d agents and m = %d items from stdin\n", n, m)
+	} else {
+		if bs, wide, n, m, err = loadBids(*input); err != nil {
+			return err
+		}
+		fmt.Fprintf(info, "Using n = %d agents and m = %d items from %s\n", n, m, *input)
+	}
+	if wide != nil {
+		return solveAndPrintWide(wide, n, m, cfg, price, time.Since(start), stdout, info)
+	}
+	if err = cfg.checkEstimate(n, m, info); err != nil {
+		return err
+	}
+	return solveAndPrint(bs, n, m, cfg, price, time.Since(start), stdout, info)
 }
 
-func (s *Solution) CalculatePrices(bs BidSet, n, m int) {
-	s.PricePerAgent = make([]float64, len(s.Allocation))
-	for agent, _ := range s.Allocation {
-		if agent > 0 {
-			new_bs := bs.CopyExcludingAgent(agent)
-			alternative_solution := solveAllocation(new_bs, n-1, m)
-			s.PricePerAgent[agent] = alternative_solution.TotalUtility - s.Allocation.FindTotalUtilityExceptAgent(bs, agent)
+// runBatch solves the JSON array of auctions of a file and writes their
+// solutions as a JSON array.
+func runBatch(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	input := fs.String("i", "-", "read the auctions from a JSON `file` (- for stdin)")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	r := stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
 		}
+		defer f.Close()
+		r = f
+	}
+	bss, ms, err := vcg.LoadBidSets(r)
+	if err != nil {
+		return fmt.Errorf("%s: %s", *input, err)
+	}
+	out, err := json.Marshal(vcg.SolveBatch(bss, ms))
+	if err != nil {
+		return err
 	}
+	fmt.Fprintln(stdout, string(out))
+	return nil
 }
 
-func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("Pass n and m as arguments.")
-		os.Exit(1)
+// runAuction solves and, unless -no-prices is set, prices random bids or
+// those of -input.
+func runAuction(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	input := fs.String("input", "", "read bids from a JSON, CSV (*.csv) or binary (*.bin) `file` instead of randomizing them")
+	bundles := fs.Int("bundles", 0, "let every random agent bid on `k` random bundles instead of all of them")
+	no_prices := fs.Bool("no-prices", false, "only find the allocation, without the VCG prices")
+	seed := fs.Int64("seed", 0, "generate the random bids from `seed` instead of a random one")
+	cfg := addSolveFlags(fs)
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	info, err := cfg.validate(stdout, stderr)
+	if err != nil {
+		return err
 	}
-	n, _ := strconv.Atoi(os.Args[1])
-	m, _ := strconv.Atoi(os.Args[2])
-	fmt.Printf("Using n = %d agents and m = %d items\nWill use %d threads.\n", n, m, n*n)
 
-	rand.Seed(time.Now().UnixNano())
-	fmt.Println("Generating agent's utilities for all combinations of allocations to them...")
-	start := time.Now()
-	bs := randomizeBidSet(n, m)
-	elapsed := time.Since(start)
-	fmt.Printf("Randomizing agent's utilities took %s\n", elapsed)
+	var bs vcg.BidSet
+	var n, m int
+	if *cfg.checkpoint != "" && *input == "" {
+		return errors.New("-checkpoint needs the bids of -input")
+	}
+	generation_start := time.Now()
+	if *input != "" {
+		var wide vcg.WideBidSet
+		if bs, wide, n, m, err = loadBids(*input); err != nil {
+			return err
+		}
+		fmt.Fprintf(info, "Using n = %d agents and m = %d items from %s\n", n, m, *input)
+		if wide != nil {
+			return solveAndPrintWide(wide, n, m, cfg, !*no_prices, time.Since(generation_start), stdout, info)
+		}
+		if err = cfg.checkEstimate(n, m, info); err != nil {
+			return err
+		}
+	} else {
+		if n, m, err = parseArgs(fs.Args()); err != nil {
+			return err
+		}
+		fmt.Fprintf(info, "Using n = %d agents and m = %d items\n", n, m)
+		if err = cfg.checkEstimate(n, m, info); err != nil {
+			return err
+		}
+
+		s := effectiveSeed(fs, *seed)
+		fmt.Fprintf(info, "Generating agent's utilities for all combinations of allocations to them with seed %d...\n", s)
+		start := time.Now()
+		bs = vcg.GenerateBidSet(vcg.GenOptions{Agents: n, Items: m, BundlesPerAgent: *bundles, Seed: s})
+		elapsed := time.Since(start)
+		fmt.Fprintf(info, "Randomizing agent's utilities took %s\n", elapsed)
+	}
+	generation := time.Since(generation_start)
 	if m < 10 {
 		for agent, bid := range bs {
-			if agent != 0 { // agent 0 is nobody!
-				fmt.Printf("Bids for Agent %d\n", agent)
-				for items, utility := range bid {
-					fmt.Printf("  %0"+strconv.Itoa(m)+"b => %f\n", items, utility)
+			if agent != vcg.Unassigned {
+				fmt.Fprintf(info, "Bids for Agent %d\n", agent)
+				bundles := make([]int64, 0, len(bid))
+				for items := range bid {
+					bundles = append(bundles, items)
+				}
+				sort.Slice(bundles, func(i, j int) bool { return bundles[i] < bundles[j] })
+				for _, items := range bundles {
+					fmt.Fprintf(info, "  %0"+strconv.Itoa(m)+"b => %f\n", items, bid[items])
 				}
 			}
 		}
 	}
+	return solveAndPrint(bs, n, m, cfg, !*no_prices, generation, stdout, info)
+}
 
-	// start looking for solutions
-	start = time.Now()
-	solution := solveAllocation(bs, n, m)
-	solution.CalculatePrices(bs, n, m)
-	elapsed = time.Since(start)
-	fmt.Printf("%+v\n", solution)
-	fmt.Printf("Finding solution took %s\n", elapsed)
+// effectiveSeed returns seed if the -seed flag of fs was set, a random seed
+// otherwise. Printing it lets a run be repeated with -seed.
+func effectiveSeed(fs *flag.FlagSet, seed int64) int64 {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			set = true
+		}
+	})
+	if set {
+		return seed
+	}
+	return rand.Int63()
 }
 
-// this is not parallel - no need to synchronize map writes
-func randomizeBidSet(n, m int) (bs BidSet) {
-	bs = make(BidSet, n+1)
-	for a := 1; a <= n; a++ {
-		bs[a] = getRandomBid(m)
+// loadBids reads bids from a JSON, CSV or binary file. A JSON document of
+// more than vcg.MaxFlagItems items is returned as wide bids instead.
+func loadBids(path string) (bs vcg.BidSet, wide vcg.WideBidSet, n, m int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	defer f.Close()
+	if isBinary(path) {
+		bs, n, m, err = vcg.LoadBidSetBinary(f)
+	} else if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		bs, n, m, err = vcg.LoadBidSetCSV(f)
+	} else {
+		bs, wide, n, m, err = loadJSONBids(f)
+	}
+	if err != nil {
+		return nil, nil, 0, 0, fmt.Errorf("%s: %s", path, err)
 	}
 	return
 }
 
-func getRandomBid(m int) (b Bid) {
-	b = make(Bid)
-	recursiveRandomBidGenerator(b, 0, 0, 1, m)
-	return
+// loadJSONBids reads bids from a JSON document, as wide bids if it has more
+// than vcg.MaxFlagItems items.
+func loadJSONBids(r io.Reader) (bs vcg.BidSet, wide vcg.WideBidSet, n, m int, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	if wide, n, m, err = vcg.LoadWideBidSet(bytes.NewReader(data)); err != nil || m > vcg.MaxFlagItems {
+		return nil, wide, n, m, err
+	}
+	bs, n, m, err = vcg.LoadBidSet(bytes.NewReader(data))
+	return bs, nil, n, m, err
 }
 
-func recursiveRandomBidGenerator(b Bid, carry int64, previous_sum int, current_bit, bits int) {
-	new_carry := carry                                    // prepending 0
-	b[new_carry] = float64(previous_sum) * rand.Float64() // no utility for no items (sum == 0)
-	if current_bit < bits {
-		recursiveRandomBidGenerator(b, new_carry, previous_sum, current_bit+1, bits)
+// loadReserves reads the reserve prices of m items from the CSV file path.
+func loadReserves(path string, m int) ([]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reserves, err := vcg.LoadReservePrices(f, m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
 	}
+	return reserves, nil
+}
+
+// isBinary reports whether path names a file of bids in the encoding of
+// vcg.SaveBidSetBinary.
+func isBinary(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".bin")
+}
 
-	new_carry = carry | 1<<uint(current_bit-1) // prepending 1 but current_bit = 1 is actually "array index 0"
-	b[new_carry] = float64(previous_sum+1) * rand.Float64()
-	if current_bit < bits {
-		recursiveRandomBidGenerator(b, new_carry, previous_sum+1, current_bit+1, bits)
+// sniffBids reads bids from r as JSON if its first non-blank character
+// opens a JSON object, like loadJSONBids, as CSV otherwise.
+func sniffBids(r io.Reader) (bs vcg.BidSet, wide vcg.WideBidSet, n, m int, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return loadJSONBids(bytes.NewReader(data))
 	}
+	bs, n, m, err = vcg.LoadBidSetCSV(bytes.NewReader(data))
+	return bs, nil, n, m, err
+}
+
+// phaseTimings is how long each phase of a run took, printed with -timings.
+// In JSON, the durations are in seconds.
+type phaseTimings struct {
+	Generation          time.Duration
+	WinnerDetermination time.Duration
+	Pricing             time.Duration
+}
+
+func (t phaseTimings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]float64{
+		"generation":           t.Generation.Seconds(),
+		"winner_determination": t.WinnerDetermination.Seconds(),
+		"pricing":              t.Pricing.Seconds(),
+	})
 }
 
-func solveAllocation(bs BidSet, n, m int) (s Solution) {
-	allocation := make(Allocation)
-	for a := 0; a <= n; a++ {
-		allocation[a] = make(map[int]bool)
+// solveAndPrint solves bs, prices the solution if price is set, and prints
+// it to stdout. generation is how long getting bs took, for -timings.
+func solveAndPrint(bs vcg.BidSet, n, m int, cfg solveConfig, price bool, generation time.Duration, stdout, info io.Writer) error {
+	opts := vcg.Options{Memoize: *cfg.memoize, Prune: *cfg.prune, Deterministic: *cfg.deterministic}
+	var err error
+	if *cfg.reserves != "" {
+		if opts.ReservePrices, err = loadReserves(*cfg.reserves, m); err != nil {
+			return err
+		}
 	}
-	recursiveAllocationGenerator(&s, bs, allocation, 0, m, 2, nil)
-	return
+	if !*cfg.memoize {
+		// checkpoints need the sequential search
+		if workers := opts.Workers(n, m); workers > 1 && *cfg.checkpoint == "" {
+			fmt.Fprintf(info, "Will search with %d workers.\n", workers)
+		} else {
+			fmt.Fprintln(info, "Will search sequentially.")
+		}
+	}
+
+	// start looking for solutions
+	start := time.Now()
+	if *cfg.verbose {
+		opts.Logger = log.New(info, "", 0)
+	}
+	ctx := context.Background()
+	if *cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *cfg.timeout)
+		defer cancel()
+	}
+	var solution vcg.Solution
+	if *cfg.checkpoint != "" {
+		solution, err = solveWithCheckpoints(ctx, bs, m, opts, *cfg.checkpoint, *cfg.checkpoint_every, info)
+	} else {
+		solution, err = vcg.SolveAllocationContextWithOptions(ctx, bs, n, m, opts)
+	}
+	switch {
+	case err == context.DeadlineExceeded:
+		// prices of a suboptimal allocation are meaningless
+		fmt.Fprintf(info, "Search timed out after %s, the allocation below is the best found so far and POSSIBLY SUBOPTIMAL.\n", *cfg.timeout)
+		price = false
+	case err != nil:
+		return err
+	}
+	timings := phaseTimings{Generation: generation, WinnerDetermination: time.Since(start)}
+	if price {
+		pricing_start := time.Now()
+		switch err := solution.CalculatePricesContextWithOptions(ctx, bs, n, m, opts); {
+		case err == context.DeadlineExceeded:
+			fmt.Fprintf(info, "Pricing timed out after %s, the allocation below is optimal but printed WITHOUT PRICES.\n", *cfg.timeout)
+		case err != nil:
+			return err
+		}
+		timings.Pricing = time.Since(pricing_start)
+	}
+	return printSolution(solution, timings, time.Since(start), cfg, stdout, info)
+}
+
+// solveAndPrintWide is solveAndPrint for bids on more than vcg.MaxFlagItems
+// items, which vcg.SolveAllocationWide solves without options, so the flags
+// tuning the search are refused.
+func solveAndPrintWide(bs vcg.WideBidSet, n, m int, cfg solveConfig, price bool, generation time.Duration, stdout, info io.Writer) error {
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"-memoize", *cfg.memoize},
+		{"-prune", *cfg.prune},
+		{"-v", *cfg.verbose},
+		{"-deterministic", *cfg.deterministic},
+		{"-timeout", *cfg.timeout > 0},
+		{"-checkpoint", *cfg.checkpoint != ""},
+		{"-reserves", *cfg.reserves != ""},
+	} {
+		if flag.set {
+			return fmt.Errorf("%s is not supported with more than %d items", flag.name, vcg.MaxFlagItems)
+		}
+	}
+	if err := bs.Validate(n, m); err != nil {
+		return err
+	}
+	items := len(bs.SearchedItems(n, m))
+	fmt.Fprintf(info, "Searching %d of the items, leaving the other %d unsold\n", items, m-items)
+	if err := cfg.checkEstimate(n, items, info); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	solution := vcg.SolveAllocationWide(bs, n, m)
+	timings := phaseTimings{Generation: generation, WinnerDetermination: time.Since(start)}
+	if price {
+		pricing_start := time.Now()
+		if err := solution.CalculatePricesWide(bs, n, m); err != nil {
+			return err
+		}
+		timings.Pricing = time.Since(pricing_start)
+	}
+	return printSolution(solution, timings, time.Since(start), cfg, stdout, info)
 }
 
-func recursiveAllocationGenerator(s *Solution, bs BidSet, a Allocation, current_item, items, nested_parallelism int, pwg *sync.WaitGroup) {
-	if pwg != nil {
-		defer pwg.Done()
+// printSolution prints solution to stdout in the format of -output, and how
+// long finding it took to info.
+func printSolution(solution vcg.Solution, timings phaseTimings, elapsed time.Duration, cfg solveConfig, stdout, info io.Writer) error {
+	if *cfg.output == "json" {
+		var doc interface{} = solution
+		if *cfg.timings {
+			doc = struct {
+				Solution vcg.Solution `json:"solution"`
+				Timings  phaseTimings `json:"timings"`
+			}{solution, timings}
+		}
+		out, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(out))
+	} else {
+		fmt.Fprintf(stdout, "%+v\n", solution)
 	}
-	wg := &sync.WaitGroup{}
-	for agent := 0; agent < len(a); agent++ {
+	fmt.Fprintf(info, "Finding solution took %s\n", elapsed)
+	if *cfg.timings {
+		fmt.Fprintf(info, "Timings: generation %s, winner determination %s, pricing %s\n", timings.Generation, timings.WinnerDetermination, timings.Pricing)
+	}
+	return nil
+}
 
-		//fmt.Printf("agent: %d, current_item: %d\n", agent, current_item)
-		a[agent][current_item] = true
+// solveWithCheckpoints solves bs like vcg.SolveAllocationContextWithOptions,
+// but sequentially and saving where the search is to path every so often,
+// and when ctx is done. If path exists, the search resumes from it. Once the
+// search completes, path is removed.
+func solveWithCheckpoints(ctx context.Context, bs vcg.BidSet, m int, opts vcg.Options, path string, every time.Duration, info io.Writer) (s vcg.Solution, err error) {
+	sv := &vcg.Solver{Options: opts}
+	sv.Iterative, sv.Sequential = true, true
+	// the Solver counts items by their bids or reserves, count all m
+	for len(sv.ReservePrices) < m {
+		sv.ReservePrices = append(sv.ReservePrices, 0)
+	}
 
-		if current_item < items-1 {
-			if nested_parallelism > current_item {
-				wg.Add(1)
-				go recursiveAllocationGenerator(s, bs, a.Copy(), current_item+1, items, nested_parallelism, wg)
-			} else {
-				recursiveAllocationGenerator(s, bs, a, current_item+1, items, nested_parallelism, nil)
-			}
-			delete(a[agent], current_item)
-		} else {
-			//fmt.Printf("Considering allocation: %+v\n", a)
-			total_utility := a.FindTotalUtility(bs)
-			//fmt.Printf("Total utility: %f\n", total_utility)
+	f, err := os.Open(path)
+	if err == nil {
+		err = sv.LoadCheckpoint(f)
+		f.Close()
+		if err != nil {
+			return s, fmt.Errorf("%s: %s", path, err)
+		}
+		fmt.Fprintf(info, "Resuming the search from %s\n", path)
+	} else if !os.IsNotExist(err) {
+		return s, err
+	}
 
-			if s.TotalUtility < total_utility {
-				s.Allocation = a.Copy()
-				s.TotalUtility = total_utility
+	for {
+		round_ctx, cancel := context.WithTimeout(ctx, every)
+		s, err = sv.SolveContext(round_ctx, bs)
+		cancel()
+		if err == nil {
+			if err = os.Remove(path); os.IsNotExist(err) {
+				err = nil
 			}
-
-			// cleanup for backtrack
-			delete(a[agent], current_item)
+			return s, err
+		}
+		if err != context.DeadlineExceeded && err != context.Canceled {
+			return s, err
+		}
+		if err := saveCheckpoint(sv, path); err != nil {
+			return s, err
 		}
+		fmt.Fprintf(info, "Saved the state of the search to %s\n", path)
+		if ctx.Err() != nil {
+			return s, ctx.Err()
+		}
+	}
+}
+
+// saveCheckpoint writes the checkpoint of sv to path, replacing it only once
+// the new one is complete.
+func saveCheckpoint(sv *vcg.Solver, path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = sv.SaveCheckpoint(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// parseArgs reads the number of agents n and items m for a random instance.
+func parseArgs(args []string) (n, m int, err error) {
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("pass n and m as arguments, got %d arguments", len(args))
+	}
+	if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+		return 0, 0, fmt.Errorf("n must be a positive integer, got %q", args[0])
+	}
+	if m, err = strconv.Atoi(args[1]); err != nil || m < 1 {
+		return 0, 0, fmt.Errorf("m must be a positive integer, got %q", args[1])
+	}
+	if m > vcg.MaxFlagItems {
+		return 0, 0, fmt.Errorf("random bids support at most %d items, got %d", vcg.MaxFlagItems, m)
 	}
-	wg.Wait()
+	return n, m, nil
 }
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DSpeichert/vcg-auction/vcg"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		n, m int
+		err  string
+	}{
+		{[]string{"3", "4"}, 3, 4, ""},
+		{[]string{"1", "63"}, 1, 63, ""},
+		{nil, 0, 0, "pass n and m as arguments, got 0 arguments"},
+		{[]string{"3"}, 0, 0, "pass n and m as arguments, got 1 arguments"},
+		{[]string{"3", "4", "5"}, 0, 0, "pass n and m as arguments, got 3 arguments"},
+		{[]string{"foo", "4"}, 0, 0, `n must be a positive integer, got "foo"`},
+		{[]string{"3", "bar"}, 0, 0, `m must be a positive integer, got "bar"`},
+		{[]string{"-1", "4"}, 0, 0, `n must be a positive integer, got "-1"`},
+		{[]string{"3", "-4"}, 0, 0, `m must be a positive integer, got "-4"`},
+		{[]string{"0", "4"}, 0, 0, `n must be a positive integer, got "0"`},
+		{[]string{"3", "64"}, 0, 0, "random bids support at most 63 items, got 64"},
+	} {
+		n, m, err := parseArgs(test.args)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseArgs(%q): got error %v, want %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil || n != test.n || m != test.m {
+			t.Errorf("parseArgs(%q) = %d, %d, %v, want %d, %d", test.args, n, m, err, test.n, test.m)
+		}
+	}
+}
+
+// TestRunRejectsBadArgs checks that bad arguments are an error, not a
+// solve of an empty instance.
+func TestRunRejectsBadArgs(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"foo", "bar"}, strings.NewReader(""), &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), `n must be a positive integer, got "foo"`) {
+		t.Errorf("got error %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("printed %q", stdout.String())
+	}
+}
+
+// TestRunRefusesLongSearch checks a search estimated to take longer than
+// -max-estimate is refused before the bids are generated.
+func TestRunRefusesLongSearch(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-max-estimate", "1ns", "-seed", "1", "3", "6"}, strings.NewReader(""), &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "Refusing to search for longer than 1ns") {
+		t.Errorf("got error %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Searching 4096 allocations is estimated to take") {
+		t.Errorf("printed %q, want the estimate", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "Generating") {
+		t.Errorf("printed %q, want no bids generated", stdout.String())
+	}
+
+	// loaded bids are refused before they are printed or solved
+	stdout.Reset()
+	err = run([]string{"solve", "-max-estimate", "1ns"}, strings.NewReader(`{"items": 6, "agents": [{"bids": [{"items": [0], "value": 1}]}, {"bids": []}, {"bids": []}]}`), &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "Refusing to search for longer than 1ns") {
+		t.Errorf("solve: got error %v", err)
+	}
+	if strings.Contains(stdout.String(), "Will search") {
+		t.Errorf("solve: printed %q, want the search refused first", stdout.String())
+	}
+}
+
+// TestRunWorkers checks the number of workers printed is the number the
+// search runs with.
+func TestRunWorkers(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-seed", "1", "3", "6"}, fmt.Sprintf("Will search with %d workers.\n", runtime.GOMAXPROCS(0))},
+		{[]string{"-deterministic", "-seed", "1", "3", "6"}, "Will search sequentially.\n"},
+		{[]string{"-seed", "1", "3", "4"}, "Will search sequentially.\n"},
+	} {
+		if test.want != "Will search sequentially.\n" && runtime.GOMAXPROCS(0) == 1 {
+			test.want = "Will search sequentially.\n"
+		}
+		var stdout, stderr bytes.Buffer
+		if err := run(append([]string{"-no-prices"}, test.args...), strings.NewReader(""), &stdout, &stderr); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(stdout.String(), test.want) {
+			t.Errorf("%v: printed %q, want %q", test.args, stdout.String(), test.want)
+		}
+		if strings.Contains(stdout.String(), "threads") {
+			t.Errorf("%v: printed %q", test.args, stdout.String())
+		}
+	}
+}
+
+// TestSubcommands generates bids to a file with the generate subcommand and
+// solves and prices them with the solve and price subcommands.
+func TestSubcommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bids.json")
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"generate", "-n", "2", "-m", "3", "-seed", "1", "-o", path}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatalf("generate: %s", err)
+	}
+	if stderr.String() != "Using seed 1\n" {
+		t.Errorf("generate printed %q to stderr", stderr.String())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, n, m, err := vcg.LoadBidSet(f)
+	f.Close()
+	if err != nil || n != 2 || m != 3 {
+		t.Fatalf("generated %d agents and %d items, error %v", n, m, err)
+	}
+	want := vcg.SolveAllocation(bs, n, m)
+
+	stdout.Reset()
+	if err := run([]string{"solve", "-i", path}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatalf("solve: %s", err)
+	}
+	if !strings.Contains(stdout.String(), "{Allocation:"+want.Allocation.String()+" ") {
+		t.Errorf("solve printed %q, want the allocation %s", stdout.String(), want.Allocation)
+	}
+	if !strings.Contains(stdout.String(), "PricePerAgent:map[]") {
+		t.Errorf("solve printed %q, want no prices", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := run([]string{"price", "-i", "examples/problem1.json", "-output", "json"}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatalf("price: %s", err)
+	}
+	var doc struct {
+		TotalUtility float64 `json:"total_utility"`
+		Agents       []struct {
+			Agent int     `json:"agent"`
+			Price float64 `json:"price"`
+		} `json:"agents"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("price printed %q: %s", stdout.String(), err)
+	}
+	prices := make(map[int]float64)
+	for _, a := range doc.Agents {
+		prices[a.Agent] = a.Price
+	}
+	if doc.TotalUtility != 13 || !reflect.DeepEqual(prices, map[int]float64{1: 3, 2: 4, 3: 2, 4: 0}) {
+		t.Errorf("price printed utility %v and prices %v", doc.TotalUtility, prices)
+	}
+}
+
+// TestRunTimeout checks a search which cannot finish within -timeout stops
+// and prints the best allocation found so far, labeled as such, unpriced.
+func TestRunTimeout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	err := run([]string{"-timeout", "1ms", "-seed", "1", "6", "9"}, strings.NewReader(""), &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %s", elapsed)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "Search timed out after 1ms, the allocation below is the best found so far and POSSIBLY SUBOPTIMAL.") {
+		t.Errorf("printed %q, want the label", out)
+	}
+	if !strings.Contains(out, "Optimal:false") || !strings.Contains(out, "PricePerAgent:map[]") {
+		t.Errorf("printed %q, want an unpriced incumbent", out)
+	}
+}
+
+// TestRunPricingTimeout checks -timeout also stops pricing, which takes
+// far longer than the search with many more agents than items.
+func TestRunPricingTimeout(t *testing.T) {
+	args := []string{"-deterministic", "-seed", "1", "20", "4"}
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	if err := run(append([]string{"-no-prices"}, args...), strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	timeout := 4 * time.Since(start)
+
+	stdout.Reset()
+	start = time.Now()
+	if err := run(append([]string{"-timeout", timeout.String()}, args...), strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Errorf("took %s with -timeout %s", elapsed, timeout)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "Pricing timed out after "+timeout.String()+", the allocation below is optimal but printed WITHOUT PRICES.") {
+		t.Errorf("printed %q, want the label", out)
+	}
+	if !strings.Contains(out, "Optimal:true") || !strings.Contains(out, "PricePerAgent:map[]") {
+		t.Errorf("printed %q, want an unpriced optimal allocation", out)
+	}
+}
+
+func TestRunRejectsNegativeTimeout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-timeout", "-1s", "2", "2"}, strings.NewReader(""), &stdout, &stderr)
+	if err == nil || err.Error() != "-timeout must not be negative, got -1s" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+// TestREPL drives the repl subcommand through bids, a solve, a changed bid
+// with prices, errors and a reset.
+func TestREPL(t *testing.T) {
+	script := `bid alice {apple,pear} 5
+bid bob {apple} 3
+bid carol {pear} 3
+bids
+solve
+bid carol {pear} 1
+price
+bogus
+bid x 3
+reset
+solve
+`
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"repl"}, strings.NewReader(script), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	want := `Type help for a list of commands.
+> > > > alice {apple,pear} 5
+bob {apple} 3
+carol {pear} 3
+> alice: {}
+bob: {apple}
+carol: {pear}
+unsold: {}
+total utility: 6
+> > alice: {apple,pear} pays 4
+bob: {} pays 0
+carol: {} pays 0
+unsold: {}
+total utility: 5
+> error: unknown command "bogus", type help for a list of commands
+> error: want bid <agent> {<item>,...} <value>
+> > error: no items bid on yet
+> 
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("printed\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestREPLTooManyItems bids on 64 items in the repl and checks solve prints
+// an error rather than panicking.
+func TestREPLTooManyItems(t *testing.T) {
+	var script strings.Builder
+	for item := 0; item <= vcg.MaxFlagItems; item++ {
+		fmt.Fprintf(&script, "bid alice {item%d} 1\n", item)
+	}
+	script.WriteString("solve\n")
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"repl"}, strings.NewReader(script.String()), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if want := "> error: 64 items bid on, at most 63 supported\n> \n"; !strings.HasSuffix(stdout.String(), want) {
+		t.Errorf("printed %q, want it to end in %q", stdout.String(), want)
+	}
+}
+
+// TestRunCheckpoint solves bids with -checkpoint, saving every millisecond,
+// and checks it finds the solution of a run without it and removes the
+// checkpoint once done.
+func TestRunCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bids, checkpoint := filepath.Join(dir, "bids.json"), filepath.Join(dir, "search.checkpoint")
+	f, err := os.Create(bids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vcg.SaveBidSet(f, vcg.GenerateBidSet(vcg.GenOptions{Agents: 3, Items: 10, Sparsity: 0.3, Seed: 7}), 10)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, stdout, stderr bytes.Buffer
+	if err := run([]string{"-input", bids, "-no-prices", "-output", "json"}, strings.NewReader(""), &want, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if err := run([]string{"-input", bids, "-no-prices", "-output", "json", "-checkpoint", checkpoint, "-checkpoint-every", "1ms"}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != want.String() {
+		t.Errorf("printed %s, want %s", stdout.String(), want.String())
+	}
+	if !strings.Contains(stderr.String(), "Saved the state of the search to "+checkpoint) {
+		t.Errorf("printed %q, want a checkpoint saved", stderr.String())
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left behind: %v", err)
+	}
+}
+
+// TestSolveStdin pipes the bids of problem1 to the solve subcommand, as
+// JSON and as CSV.
+func TestSolveStdin(t *testing.T) {
+	for _, path := range []string{"examples/problem1.json", "examples/problem1.csv"} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if err := run([]string{"solve"}, bytes.NewReader(append([]byte("\n  "), data...)), &stdout, &stderr); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		out := stdout.String()
+		if !strings.HasPrefix(out, "Using n = 4 agents and m = 4 items from stdin\n") {
+			t.Errorf("%s: printed %q", path, out)
+		}
+		if !strings.Contains(out, "{Allocation:agent1:{item3} agent2:{item0,item1} agent3:{item2} TotalUtility:13 ") {
+			t.Errorf("%s: printed %q, want the solution of problem1", path, out)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"solve"}, strings.NewReader("agent,items,value\n1,0,x\n"), &stdout, &stderr)
+	if err == nil || err.Error() != `stdin: line 2: value "x" is not a number` {
+		t.Errorf("got error %v", err)
+	}
+}
+
+// TestRunNoPrices checks -no-prices finds the same allocation without
+// pricing it, in less time than the n extra solves of the pricing take.
+func TestRunNoPrices(t *testing.T) {
+	solve := func(args ...string) (out string, fastest time.Duration) {
+		for i := 0; i < 3; i++ {
+			var stdout, stderr bytes.Buffer
+			start := time.Now()
+			if err := run(append(args, "-seed", "1", "4", "7"), strings.NewReader(""), &stdout, &stderr); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); i == 0 || elapsed < fastest {
+				fastest = elapsed
+			}
+			out = stdout.String()
+		}
+		return
+	}
+	priced, with_prices := solve()
+	unpriced, without_prices := solve("-no-prices")
+	t.Logf("%s with prices, %s without", with_prices, without_prices)
+
+	allocation := regexp.MustCompile(`\{Allocation:.* TotalUtility:\S+ `)
+	if a, b := allocation.FindString(priced), allocation.FindString(unpriced); a == "" || a != b {
+		t.Errorf("allocations %q and %q differ", a, b)
+	}
+	if strings.Contains(priced, "PricePerAgent:map[] ") {
+		t.Errorf("printed %q, want prices", priced)
+	}
+	if !strings.Contains(unpriced, "PricePerAgent:map[] ") {
+		t.Errorf("printed %q with -no-prices", unpriced)
+	}
+	if without_prices >= with_prices {
+		t.Errorf("took %s with -no-prices, no less than %s", without_prices, with_prices)
+	}
+}
+
+// TestRunSeed checks two runs with the same -seed print the same bids and
+// name the seed, and a run without it prints the seed it picked.
+func TestRunSeed(t *testing.T) {
+	bids := func(args ...string) (seed, out string) {
+		var stdout, stderr bytes.Buffer
+		if err := run(append(args, "-no-prices", "3", "3"), strings.NewReader(""), &stdout, &stderr); err != nil {
+			t.Fatal(err)
+		}
+		out = stdout.String()
+		match := regexp.MustCompile(`with seed (-?\d+)\.\.\.\n`).FindStringSubmatch(out)
+		if match == nil {
+			t.Fatalf("printed %q, want the seed", out)
+		}
+		start := strings.Index(out, "Bids for Agent 1\n")
+		end := strings.Index(out, "Will search")
+		if start < 0 || end < start {
+			t.Fatalf("printed %q, want the bids", out)
+		}
+		return match[1], out[start:end]
+	}
+	seed, first := bids("-seed", "42")
+	if seed != "42" {
+		t.Errorf("printed seed %s, want 42", seed)
+	}
+	if _, second := bids("-seed", "42"); second != first {
+		t.Errorf("seed 42 gave different bids:\n%s\n%s", first, second)
+	}
+	if _, other := bids("-seed", "43"); other == first {
+		t.Error("seeds 42 and 43 gave the same bids")
+	}
+	picked, random := bids()
+	if _, again := bids("-seed", picked); again != random {
+		t.Errorf("seed %s did not reproduce the bids:\n%s\n%s", picked, random, again)
+	}
+}
+
+// TestRunReserves solves problem1 with a reserve of 5 on item 2 only, which
+// keeps item 2 unsold and leaves the other items' reserves at 0.
+func TestRunReserves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reserves.csv")
+	if err := ioutil.WriteFile(path, []byte("item,reserve\n2,5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-input", "examples/problem1.json", "-reserves", path}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{Allocation:agent1:{item3} agent2:{item0,item1} unsold:{item2} TotalUtility:14 "; !strings.Contains(stdout.String(), want) {
+		t.Errorf("printed %q, want %s", stdout.String(), want)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("4,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-input", "examples/problem1.json", "-reserves", path}, strings.NewReader(""), &stdout, &stderr)
+	if want := path + `: line 1: item "4" is not an index in 0..3`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+// TestRunTimings checks -timings reports generation, winner determination
+// and pricing, as text and in the JSON output.
+func TestRunTimings(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-timings", "-seed", "1", "3", "4"}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`\nTimings: generation (\S+), winner determination (\S+), pricing (\S+)\n`).FindStringSubmatch(stdout.String())
+	if match == nil {
+		t.Fatalf("printed %q, want the timings", stdout.String())
+	}
+	for i, phase := range []string{"generation", "winner determination", "pricing"} {
+		if d, err := time.ParseDuration(match[i+1]); err != nil || d < 0 {
+			t.Errorf("%s took %q", phase, match[i+1])
+		}
+	}
+
+	stdout.Reset()
+	if err := run([]string{"-timings", "-output", "json", "-input", "examples/problem1.json"}, strings.NewReader(""), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Solution struct {
+			TotalUtility float64 `json:"total_utility"`
+		} `json:"solution"`
+		Timings map[string]float64 `json:"timings"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("printed %q: %s", stdout.String(), err)
+	}
+	if doc.Solution.TotalUtility != 13 {
+		t.Errorf("printed %q, want the solution of problem1", stdout.String())
+	}
+	for _, phase := range []string{"generation", "winner_determination", "pricing"} {
+		if d, ok := doc.Timings[phase]; !ok || d < 0 {
+			t.Errorf("timings %v, want %s", doc.Timings, phase)
+		}
+	}
+	if len(doc.Timings) != 3 {
+		t.Errorf("timings %v, want 3 phases", doc.Timings)
+	}
+}
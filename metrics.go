@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the collectors of the server, in a registry of its own so
+// every handler counts only its own requests.
+type metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	durations prometheus.Histogram
+	nodes     prometheus.Histogram
+}
+
+func newMetrics() *metrics {
+	mt := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vcg_http_requests_total",
+			Help: "HTTP requests by path and status code.",
+		}, []string{"path", "code"}),
+		durations: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "vcg_solve_duration_seconds",
+			Help:    "Time taken to solve and price an auction.",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+		}),
+		nodes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "vcg_solve_nodes_explored",
+			Help:    "Nodes of the search tree explored by a solve, pricing excluded.",
+			Buckets: prometheus.ExponentialBuckets(1, 10, 10),
+		}),
+	}
+	mt.registry.MustRegister(mt.requests, mt.durations, mt.nodes)
+	return mt
+}
+
+// solve records a completed solve which took d and explored nodes.
+func (mt *metrics) solve(d time.Duration, nodes int64) {
+	mt.durations.Observe(d.Seconds())
+	mt.nodes.Observe(float64(nodes))
+}
+
+// handler serves the metrics in the Prometheus text format.
+func (mt *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(mt.registry, promhttp.HandlerOpts{})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// count wraps h to count its requests in mt.
+func (mt *metrics) count(path string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{w, http.StatusOK}
+		h(rec, r)
+		mt.requests.WithLabelValues(path, strconv.Itoa(rec.code)).Inc()
+	}
+}
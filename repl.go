@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DSpeichert/vcg-auction/vcg"
+)
+
+const replHelp = `Commands:
+  bid <agent> {<item>,...} <value>   bid value on a bundle, replacing an earlier bid on it
+  bids                               list all bids
+  solve                              print the optimal allocation
+  price                              print the optimal allocation and its VCG prices
+  reset                              forget all bids
+  help                               print this help
+  quit                               leave (as does end of input)
+Agents and items are named freely, e.g. bid alice {apple,pear} 5.`
+
+// runREPL reads commands from stdin until quit or the end of the input and
+// prints their results to stdout. Invalid commands print an error and are
+// otherwise ignored.
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("repl takes no arguments, got %q", args)
+	}
+	nbs := make(vcg.NamedBidSet)
+	scanner := bufio.NewScanner(stdin)
+	fmt.Fprintln(stdout, "Type help for a list of commands.")
+	for {
+		fmt.Fprint(stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "bid":
+			agent, bid, err := parseBidCommand(strings.TrimSpace(line[len("bid"):]))
+			if err != nil {
+				fmt.Fprintf(stdout, "error: %s\n", err)
+				continue
+			}
+			nbs[agent] = replaceBid(nbs[agent], bid)
+		case "bids":
+			printBids(stdout, nbs)
+		case "solve", "price":
+			printREPLSolution(stdout, nbs, fields[0] == "price")
+		case "reset":
+			nbs = make(vcg.NamedBidSet)
+		case "help":
+			fmt.Fprintln(stdout, replHelp)
+		case "quit", "exit":
+			return nil
+		default:
+			fmt.Fprintf(stdout, "error: unknown command %q, type help for a list of commands\n", fields[0])
+		}
+	}
+}
+
+// parseBidCommand parses the arguments of bid: `<agent> {<item>,...} <value>`.
+func parseBidCommand(args string) (agent string, bid vcg.NamedBundleBid, err error) {
+	open_brace, close_brace := strings.Index(args, "{"), strings.Index(args, "}")
+	if open_brace < 0 || close_brace < open_brace {
+		return "", bid, fmt.Errorf("want bid <agent> {<item>,...} <value>")
+	}
+	agent = strings.TrimSpace(args[:open_brace])
+	if agent == "" || strings.ContainsAny(agent, " \t") {
+		return "", bid, fmt.Errorf("want a single agent name before the bundle, got %q", agent)
+	}
+	bid.Items = []string{}
+	for _, item := range strings.Split(args[open_brace+1:close_brace], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			bid.Items = append(bid.Items, item)
+		}
+	}
+	sort.Strings(bid.Items)
+	if bid.Value, err = strconv.ParseFloat(strings.TrimSpace(args[close_brace+1:]), 64); err != nil {
+		return "", bid, fmt.Errorf("want a number after the bundle, got %q", strings.TrimSpace(args[close_brace+1:]))
+	}
+	return
+}
+
+// replaceBid adds bid to bids, dropping an earlier bid on the same items.
+func replaceBid(bids []vcg.NamedBundleBid, bid vcg.NamedBundleBid) []vcg.NamedBundleBid {
+	key := strings.Join(bid.Items, ",")
+	for i, b := range bids {
+		if strings.Join(b.Items, ",") == key {
+			bids[i] = bid
+			return bids
+		}
+	}
+	return append(bids, bid)
+}
+
+func printBids(w io.Writer, nbs vcg.NamedBidSet) {
+	agents := make([]string, 0, len(nbs))
+	for agent := range nbs {
+		agents = append(agents, agent)
+	}
+	sort.Strings(agents)
+	for _, agent := range agents {
+		for _, bid := range nbs[agent] {
+			fmt.Fprintf(w, "%s {%s} %g\n", agent, strings.Join(bid.Items, ","), bid.Value)
+		}
+	}
+}
+
+// printREPLSolution solves nbs and prints every agent's items, with its
+// price if price is set, then the unsold items and the total utility.
+func printREPLSolution(w io.Writer, nbs vcg.NamedBidSet, price bool) {
+	if m := len(nbs.Items()); m > vcg.MaxFlagItems {
+		fmt.Fprintf(w, "error: %d items bid on, at most %d supported\n", m, vcg.MaxFlagItems)
+		return
+	}
+	bs, agents, items := nbs.Compile()
+	if len(items) == 0 {
+		fmt.Fprintln(w, "error: no items bid on yet")
+		return
+	}
+	n, m := len(agents), len(items)
+	solution := vcg.SolveAllocation(bs, n, m)
+	if price {
+		if err := solution.CalculatePrices(bs, n, m); err != nil {
+			fmt.Fprintf(w, "error: %s\n", err)
+			return
+		}
+	}
+	ns := solution.Decode(agents, items)
+	for _, agent := range agents {
+		fmt.Fprintf(w, "%s: {%s}", agent, strings.Join(ns.Allocation[agent], ","))
+		if price {
+			fmt.Fprintf(w, " pays %g", ns.Prices[agent])
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "unsold: {%s}\ntotal utility: %g\n", strings.Join(ns.Unsold, ","), ns.TotalUtility)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/DSpeichert/vcg-auction/vcg"
+)
+
+// maxRequestBytes limits the size of the bids posted to /solve.
+const maxRequestBytes = 32 << 20
+
+// runServe serves the solver over HTTP until the server fails.
+func runServe(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "listen on `address`")
+	timeout := fs.Duration("timeout", 10*time.Second, "give up on a request after `duration`, solving and pricing included")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if *timeout <= 0 {
+		return fmt.Errorf("-timeout must be positive, got %s", *timeout)
+	}
+	logger := log.New(stderr, "", log.LstdFlags)
+	logger.Printf("Listening on %s", *addr)
+	return http.ListenAndServe(*addr, newHandler(*timeout, logger))
+}
+
+// newHandler returns the routes of the server:
+//
+//	POST /solve    bids in the JSON format of vcg.LoadBidSet, answered with
+//	               the priced solution as written by Solution.MarshalJSON
+//	GET  /healthz  answers ok
+//	GET  /metrics  request counts, solve durations and search tree nodes
+//	               explored per solve, in the Prometheus text format
+//
+// Errors are answered as {"error": "..."}.
+func newHandler(timeout time.Duration, logger *log.Logger) http.Handler {
+	mt := newMetrics()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", mt.handler())
+	mux.HandleFunc("/healthz", mt.count("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			writeError(w, http.StatusMethodNotAllowed, "use GET")
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}))
+	mux.HandleFunc("/solve", mt.count("/solve", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		bs, n, m, err := vcg.LoadBidSet(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		start := time.Now()
+		solution, err := vcg.SolveAllocationContextWithOptions(ctx, bs, n, m, vcg.Options{})
+		if err == nil && n > 0 {
+			err = solution.CalculatePricesContextWithOptions(ctx, bs, n, m, vcg.Options{})
+		}
+		switch {
+		case err == context.DeadlineExceeded:
+			writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("solving took longer than %s", timeout))
+			return
+		case err != nil:
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		elapsed := time.Since(start)
+		mt.solve(elapsed, solution.Nodes)
+		logger.Printf("Solved n = %d agents and m = %d items in %s", n, m, elapsed)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(solution)
+	}))
+	return mux
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
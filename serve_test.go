@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestServeSolve posts the bids of examples/problem1.json to /solve and
+// checks the priced solution in the response.
+func TestServeSolve(t *testing.T) {
+	server := httptest.NewServer(newHandler(10*time.Second, log.New(ioutil.Discard, "", 0)))
+	defer server.Close()
+
+	f, err := os.Open("examples/problem1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	resp, err := http.Post(server.URL+"/solve", "application/json", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("got status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var doc struct {
+		TotalUtility float64 `json:"total_utility"`
+		Optimal      bool    `json:"optimal"`
+		Agents       []struct {
+			Agent int     `json:"agent"`
+			Items []int   `json:"items"`
+			Price float64 `json:"price"`
+		} `json:"agents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.TotalUtility != 13 || !doc.Optimal {
+		t.Errorf("got utility %v, optimal %v", doc.TotalUtility, doc.Optimal)
+	}
+	items, prices := make(map[int][]int), make(map[int]float64)
+	for _, a := range doc.Agents {
+		items[a.Agent], prices[a.Agent] = a.Items, a.Price
+	}
+	if want := map[int][]int{1: {3}, 2: {0, 1}, 3: {2}, 4: {}}; !reflect.DeepEqual(items, want) {
+		t.Errorf("got items %v, want %v", items, want)
+	}
+	if want := map[int]float64{1: 3, 2: 4, 3: 2, 4: 0}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("got prices %v, want %v", prices, want)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	server := httptest.NewServer(newHandler(time.Millisecond, log.New(ioutil.Discard, "", 0)))
+	defer server.Close()
+
+	// 6 agents bidding on every bundle of 9 items take far longer than 1ms
+	var large bytes.Buffer
+	large.WriteString(`{"agents": [`)
+	for agent := 0; agent < 6; agent++ {
+		if agent > 0 {
+			large.WriteString(",")
+		}
+		large.WriteString(`{"bids": [{"items": [0, 1, 2, 3, 4, 5, 6, 7, 8], "value": 1}]}`)
+	}
+	large.WriteString(`]}`)
+
+	for _, test := range []struct {
+		name, method, path, body string
+		status                   int
+		err                      string
+	}{
+		{"invalid bids", "POST", "/solve", `{"agents": [{"bids": [{"items": [0]}]}]}`, http.StatusBadRequest, `agents[0].bids[0]: missing "value"`},
+		{"solve by GET", "GET", "/solve", "", http.StatusMethodNotAllowed, "use POST"},
+		{"healthz by POST", "POST", "/healthz", "", http.StatusMethodNotAllowed, "use GET"},
+		{"timeout", "POST", "/solve", large.String(), http.StatusServiceUnavailable, "solving took longer than 1ms"},
+	} {
+		req, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Error string `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&doc)
+		resp.Body.Close()
+		if resp.StatusCode != test.status || err != nil || doc.Error != test.err {
+			t.Errorf("%s: got status %d, error %q (%v), want %d, %q", test.name, resp.StatusCode, doc.Error, err, test.status, test.err)
+		}
+	}
+}
+
+func TestServeHealthz(t *testing.T) {
+	server := httptest.NewServer(newHandler(time.Second, log.New(ioutil.Discard, "", 0)))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK || string(body) != "ok\n" {
+		t.Errorf("got status %d, body %q, error %v", resp.StatusCode, body, err)
+	}
+}
+
+// TestServeMetrics scrapes /metrics before and after a solve and checks the
+// request counter and both histograms moved.
+func TestServeMetrics(t *testing.T) {
+	server := httptest.NewServer(newHandler(10*time.Second, log.New(ioutil.Discard, "", 0)))
+	defer server.Close()
+	scrape := func() string {
+		resp, err := http.Get(server.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	before := scrape()
+	for _, line := range []string{
+		"vcg_solve_duration_seconds_count 0",
+		"vcg_solve_nodes_explored_count 0",
+	} {
+		if !strings.Contains(before, line+"\n") {
+			t.Errorf("got\n%s\nwant %q", before, line)
+		}
+	}
+	if strings.Contains(before, "vcg_http_requests_total{") {
+		t.Errorf("got\n%s\nwant no requests counted", before)
+	}
+
+	resp, err := http.Post(server.URL+"/solve", "application/json", strings.NewReader(`{"items": 2, "agents": [{"bids": [{"items": [0], "value": 1}]}, {"bids": [{"items": [0, 1], "value": 3}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(server.URL + "/solve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// the solve tries both agents and the seller for both items, 3 + 3^2
+	// nodes
+	after := scrape()
+	for _, line := range []string{
+		"# TYPE vcg_http_requests_total counter",
+		`vcg_http_requests_total{code="200",path="/solve"} 1`,
+		`vcg_http_requests_total{code="405",path="/solve"} 1`,
+		"# TYPE vcg_solve_duration_seconds histogram",
+		`vcg_solve_duration_seconds_bucket{le="+Inf"} 1`,
+		"vcg_solve_duration_seconds_count 1",
+		"# TYPE vcg_solve_nodes_explored histogram",
+		`vcg_solve_nodes_explored_bucket{le="10"} 0`,
+		`vcg_solve_nodes_explored_bucket{le="100"} 1`,
+		"vcg_solve_nodes_explored_sum 12",
+		"vcg_solve_nodes_explored_count 1",
+	} {
+		if !strings.Contains(after, line+"\n") {
+			t.Errorf("got\n%s\nwant %q", after, line)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package vcg
+
+import (
+	"context"
+	"sort"
+)
+
+// SolveAllocationAll returns every allocation with the maximum total
+// utility, in the order of the tie-break of SolveAllocation (which returns
+// the first one), and that utility. More than one allocation means the
+// optimum is not unique; the VCG prices of each may differ.
+// Utilities are compared exactly, so ties in bids which are not exactly
+// representable in floating point may be missed.
+// Like SolveAllocation, it panics if bs is not valid.
+func SolveAllocationAll(bs BidSet, n, m int) (allocations []Allocation, total_utility float64) {
+	if err := bs.Validate(n, m); err != nil {
+		panic("vcg: " + err.Error())
+	}
+	opts := Options{}
+	sr := newSearch(context.Background(), n, m, 0, opts, func(a Allocation, flags []int64) float64 {
+		return opts.utilityOfFlags(flags, bs, 0)
+	}, nil)
+	sr.inc.all = true
+	sr.run()
+	allocations = sr.inc.ties
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].less(allocations[j])
+	})
+	return allocations, sr.inc.s.TotalUtility
+}
@@ -0,0 +1,63 @@
+package vcg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSolveAllocationAllTied gives agents 1 and 2 the same bid on either
+// item, so two allocations tie for the optimum.
+func TestSolveAllocationAllTied(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 5, 0x2: 5}, Bid{0x1: 5, 0x2: 5}}
+	allocations, u := SolveAllocationAll(bs, 2, 2)
+	if u != 10 {
+		t.Errorf("utility %v, want 10", u)
+	}
+	if len(allocations) != 2 {
+		t.Fatalf("got %d allocations %v, want 2", len(allocations), allocations)
+	}
+	if got := allocations[0].owners(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("first allocation gives the items to %v, want [1 2]", got)
+	}
+	if got := allocations[1].owners(); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("second allocation gives the items to %v, want [2 1]", got)
+	}
+	if s := SolveAllocation(bs, 2, 2); !reflect.DeepEqual(s.Allocation.owners(), allocations[0].owners()) {
+		t.Errorf("SolveAllocation found %v, not the first of the ties", s.Allocation)
+	}
+}
+
+// TestSolveAllocationAllUnique checks an instance with a unique optimum and
+// one with many ties.
+func TestSolveAllocationAllUnique(t *testing.T) {
+	bs := problem1Bids()
+	allocations, u := SolveAllocationAll(bs, 4, 4)
+	if len(allocations) != 1 || u != 13 {
+		t.Fatalf("got %v with utility %v, want one allocation of utility 13", allocations, u)
+	}
+
+	bs = tiedBidSet()
+	allocations, u = SolveAllocationAll(bs, 3, 5)
+	s := SolveAllocationSequential(bs, 3, 5)
+	if u != s.TotalUtility || len(allocations) < 2 || !reflect.DeepEqual(allocations[0].owners(), s.Allocation.owners()) {
+		t.Errorf("got %d allocations of utility %v, want several of %v starting with %v", len(allocations), u, s.TotalUtility, s.Allocation)
+	}
+	for _, a := range allocations {
+		if a.FindTotalUtility(bs) != u {
+			t.Errorf("%v is worth %v, not %v", a, a.FindTotalUtility(bs), u)
+		}
+	}
+}
+
+// TestSolveAllocationAllInvalid checks an invalid bid set makes
+// SolveAllocationAll panic with the error of Validate, like SolveAllocation.
+func TestSolveAllocationAllInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "agent 1: bundle 100 holds items outside 0..1") {
+			t.Errorf("SolveAllocationAll panicked with %v", r)
+		}
+	}()
+	SolveAllocationAll(BidSet{nil, Bid{0x4: 1}}, 1, 2)
+}
@@ -0,0 +1,309 @@
+package vcg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Allocation: Agent x Item = Bool
+// Agent 0 is "nobody", see Unassigned
+type Allocation map[int]map[int]bool
+
+// Unassigned is agent 0, which holds the items that are not sold. It is not
+// a bidder and has no price. It stands for the seller: its entry in a
+// BidSet holds the seller's reservation values for keeping bundles of
+// items, see SellerUtility (the generator and the loaders set it to an
+// empty Bid). The items it holds are also worth their reserve price, see
+// Options.ReservePrices, so exactly 0 without any reserves.
+const Unassigned = 0
+
+// Flags returns the items allocated to agent as Bid flags.
+// Only valid when all items are below MaxFlagItems.
+func (a Allocation) Flags(agent int) (flags int64) {
+	for item := range a[agent] {
+		flags = flags | 1<<uint(item)
+	}
+	return
+}
+
+// Bundle returns the items allocated to agent, for any number of items.
+func (a Allocation) Bundle(agent int) (b Bundle) {
+	for item := range a[agent] {
+		b = b.Add(item)
+	}
+	return
+}
+
+// String lists the items of every agent holding any, in order, like
+// "agent1:{item0,item3} agent2:{item1} unsold:{item2}".
+func (a Allocation) String() string {
+	var parts []string
+	add := func(name string, agent int) {
+		items := a.items(agent)
+		if len(items) == 0 {
+			return
+		}
+		names := make([]string, len(items))
+		for i, item := range items {
+			names[i] = fmt.Sprintf("item%d", item)
+		}
+		parts = append(parts, name+":{"+strings.Join(names, ",")+"}")
+	}
+	for agent := 1; agent < len(a); agent++ {
+		add(fmt.Sprintf("agent%d", agent), agent)
+	}
+	add("unsold", Unassigned)
+	if parts == nil {
+		return "{}"
+	}
+	return strings.Join(parts, " ")
+}
+
+// items returns the items allocated to agent in increasing order.
+func (a Allocation) items(agent int) (items []int) {
+	items = []int{}
+	for item := range a[agent] {
+		items = append(items, item)
+	}
+	sort.Ints(items)
+	return
+}
+
+// FindTotalUtility sums the utility of every real agent for the items it
+// holds and the seller's utility for the unsold ones.
+// Bundles an agent did not bid on are worth 0, see Options.DefaultValue.
+func (a Allocation) FindTotalUtility(bs BidSet) (u float64) {
+	return a.FindTotalUtilityValuations(bs.Valuations()) + a.SellerUtility(bs)
+}
+
+// FindTotalUtilityStrict is FindTotalUtility for allocations built outside
+// the solver. It returns an error instead of crediting an item held by more
+// than one agent, agent 0 included, to each of them.
+func (a Allocation) FindTotalUtilityStrict(bs BidSet) (u float64, err error) {
+	owners := make(map[int]int)
+	for agent := 0; agent < len(a); agent++ {
+		for _, item := range a.items(agent) {
+			if owner, ok := owners[item]; ok {
+				return 0, fmt.Errorf("item %d is held by both agent %d and agent %d", item, owner, agent)
+			}
+			owners[item] = agent
+		}
+	}
+	for agent := range a {
+		if agent < 0 || agent >= len(a) {
+			return 0, fmt.Errorf("agent %d out of range 0..%d", agent, len(a)-1)
+		}
+	}
+	return a.FindTotalUtility(bs), nil
+}
+
+func (a Allocation) FindTotalUtilityExceptAgent(bs BidSet, excluded_agent int) (u float64) {
+	for agent := range a {
+		if agent != Unassigned && agent != excluded_agent {
+			u += bs[agent][a.Flags(agent)]
+		}
+	}
+	return u + a.SellerUtility(bs)
+}
+
+// ReserveUtility is the sum of the reserve prices of items held by agent 0,
+// added in the order of the items.
+func (a Allocation) ReserveUtility(reserves []float64) (u float64) {
+	for item, reserve := range reserves {
+		if a[Unassigned][item] {
+			u += reserve
+		}
+	}
+	return
+}
+
+// reserveValue is ReserveUtility for the unsold items given as Bid flags.
+func reserveValue(reserves []float64, unsold int64) (u float64) {
+	for item, reserve := range reserves {
+		if item < MaxFlagItems && unsold&(1<<uint(item)) != 0 {
+			u += reserve
+		}
+	}
+	return
+}
+
+// SellerUtility is the seller's reservation value for the items held by
+// agent 0. The seller's Bid, bs[Unassigned], is read as an OR bid (see
+// ORBid): it keeps the best set of disjoint bundles it bids on within the
+// unsold items, so a bundle reserve only counts when all of its items are
+// unsold, and bundles on single items act like per-item reserves.
+func (a Allocation) SellerUtility(bs BidSet) float64 {
+	return sellerValue(bs, a.Flags(Unassigned))
+}
+
+// sellerValue is SellerUtility for the unsold items given as Bid flags.
+func sellerValue(bs BidSet, unsold int64) float64 {
+	if len(bs) == 0 || len(bs[Unassigned]) == 0 {
+		return 0
+	}
+	return ORBid(bs[Unassigned]).ValueOf(unsold)
+}
+
+// FindTotalUtilityWide is FindTotalUtility for instances with more than
+// MaxFlagItems items.
+func (a Allocation) FindTotalUtilityWide(bs WideBidSet) (u float64) {
+	for agent := range a {
+		if agent != Unassigned {
+			u += bs[agent].Get(a.Bundle(agent))
+		}
+	}
+	return
+}
+
+func (a Allocation) Copy() (c Allocation) {
+	c = make(Allocation)
+	for k, v := range a {
+		c[k] = make(map[int]bool)
+		for k2, v2 := range v {
+			c[k][k2] = v2
+		}
+	}
+	return
+}
+
+// copyTo overwrites c with a copy of a, reusing the maps of c.
+func (a Allocation) copyTo(c Allocation) {
+	for agent, items := range a {
+		if c[agent] == nil {
+			c[agent] = make(map[int]bool)
+		}
+		for item := range c[agent] {
+			delete(c[agent], item)
+		}
+		for item, held := range items {
+			c[agent][item] = held
+		}
+	}
+}
+
+// owners returns the agent holding each item, indexed by item.
+// Items not held by anyone are marked with -1. It is meant for allocations
+// of the search, whose items are 0..m-1; negative items are ignored, and
+// holders suits allocations of any items.
+func (a Allocation) owners() (o []int) {
+	for agent, items := range a {
+		for item := range items {
+			if item < 0 {
+				continue
+			}
+			for len(o) <= item {
+				o = append(o, -1)
+			}
+			o[item] = agent
+		}
+	}
+	return
+}
+
+// holders is owners keyed by item, for allocations built outside the
+// solver whose items may be of any index.
+func (a Allocation) holders() (h map[int]int) {
+	h = make(map[int]int)
+	for agent, items := range a {
+		for item := range items {
+			h[item] = agent
+		}
+	}
+	return
+}
+
+// Move is an item changing hands between two allocations, see
+// DiffAllocations. An agent of -1 means the item is missing from that
+// allocation.
+type Move struct {
+	From, To int
+}
+
+// DiffAllocations returns, keyed by item, the agent holding every item in a
+// and the one holding it in b, for the items whose holder differs. Agent 0
+// holds the unsold items. Items are not checked against any range, see
+// IsFeasible for that.
+func DiffAllocations(a, b Allocation) (moved map[int]Move) {
+	moved = make(map[int]Move)
+	ah, bh := a.holders(), b.holders()
+	for item, from := range ah {
+		to, ok := bh[item]
+		if !ok {
+			to = -1
+		}
+		if from != to {
+			moved[item] = Move{from, to}
+		}
+	}
+	for item, to := range bh {
+		if _, ok := ah[item]; !ok {
+			moved[item] = Move{-1, to}
+		}
+	}
+	return
+}
+
+// less reports whether a precedes b when comparing the agents holding
+// item 0, item 1 and so on. It is used to break ties between allocations
+// of equal utility deterministically.
+func (a Allocation) less(b Allocation) bool {
+	return a.lessRanked(b, nil)
+}
+
+// lessRanked is less with agents compared by rank, indexed by agent,
+// instead of by number. Agents beyond rank compare by number, after all
+// ranked ones.
+func (a Allocation) lessRanked(b Allocation, rank []int) bool {
+	rank_of := func(agent int) int {
+		if agent >= 0 && agent < len(rank) {
+			return rank[agent]
+		}
+		return agent + len(rank)
+	}
+	ao, bo := a.owners(), b.owners()
+	for item := 0; item < len(ao) && item < len(bo); item++ {
+		if ao[item] != bo[item] {
+			return rank_of(ao[item]) < rank_of(bo[item])
+		}
+	}
+	return len(ao) < len(bo)
+}
+
+// SocialWelfare is the total utility of the real agents for allocation a,
+// as a.FindTotalUtility(bs).
+func SocialWelfare(a Allocation, bs BidSet) float64 {
+	return a.FindTotalUtility(bs)
+}
+
+// IsFeasible checks that a assigns each of the items 0..m-1 to exactly one
+// agent, agent 0 holding the unsold ones, and nothing else. Agents must be
+// numbered 0..len(a)-1.
+func IsFeasible(a Allocation, m int) error {
+	for agent := range a {
+		if agent < 0 || agent >= len(a) {
+			return fmt.Errorf("agent %d out of range 0..%d", agent, len(a)-1)
+		}
+	}
+	owners := make([]int, m)
+	for item := range owners {
+		owners[item] = -1
+	}
+	for agent := 0; agent < len(a); agent++ {
+		for _, item := range a.items(agent) {
+			if item < 0 || item >= m {
+				return fmt.Errorf("agent %d holds item %d out of range 0..%d", agent, item, m-1)
+			}
+			if owners[item] >= 0 {
+				return fmt.Errorf("item %d is held by both agent %d and agent %d", item, owners[item], agent)
+			}
+			owners[item] = agent
+		}
+	}
+	for item, owner := range owners {
+		if owner < 0 {
+			return fmt.Errorf("item %d is not assigned to any agent", item)
+		}
+	}
+	return nil
+}
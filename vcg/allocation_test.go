@@ -0,0 +1,184 @@
+package vcg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// allocationOf builds an allocation of agents 0..n from the items of each
+// agent.
+func allocationOf(n int, items map[int][]int) (a Allocation) {
+	a = newAllocation(n)
+	for agent, held := range items {
+		if a[agent] == nil {
+			a[agent] = make(map[int]bool)
+		}
+		for _, item := range held {
+			a[agent][item] = true
+		}
+	}
+	return
+}
+
+func TestIsFeasible(t *testing.T) {
+	tests := []struct {
+		a   Allocation
+		err string
+	}{
+		{allocationOf(2, map[int][]int{0: {2}, 1: {0}, 2: {1}}), ""},
+		{allocationOf(2, map[int][]int{1: {0, 2}, 2: {1}}), ""},
+		{allocationOf(2, map[int][]int{1: {0, 1}, 2: {1, 2}}), "item 1 is held by both agent 1 and agent 2"},
+		{allocationOf(2, map[int][]int{0: {1}, 1: {0}}), "item 2 is not assigned to any agent"},
+		{allocationOf(2, map[int][]int{0: {1}, 1: {0, 3}, 2: {2}}), "agent 1 holds item 3 out of range 0..2"},
+		{allocationOf(2, map[int][]int{1: {0, 1}, 5: {2}}), "agent 5 out of range 0..3"},
+	}
+	for _, tt := range tests {
+		err := IsFeasible(tt.a, 3)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%v: %v", tt.a, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want %q", tt.a, err, tt.err)
+		}
+	}
+}
+
+func TestSocialWelfare(t *testing.T) {
+	bs := problem1Bids()
+	a := allocationOf(4, map[int][]int{1: {3}, 2: {0, 1}, 3: {2}})
+	if u := SocialWelfare(a, bs); u != 13 {
+		t.Errorf("welfare %v, want 13", u)
+	}
+	// agent 4 holding all items it did not bid on together is worth 0
+	a = allocationOf(4, map[int][]int{4: {0, 1, 2}, 0: {3}})
+	if u := SocialWelfare(a, bs); u != 0 {
+		t.Errorf("welfare %v, want 0", u)
+	}
+}
+
+func TestAllocationString(t *testing.T) {
+	const want = "agent1:{item0,item3,item10} agent3:{item1} unsold:{item2}"
+	for run := 0; run < 50; run++ {
+		// insert in a different order every run
+		a := newAllocation(3)
+		items := []struct{ agent, item int }{{1, 10}, {3, 1}, {1, 0}, {0, 2}, {1, 3}}
+		for i := range items {
+			it := items[(i+run)%len(items)]
+			a[it.agent][it.item] = true
+		}
+		if got := a.String(); got != want {
+			t.Fatalf("run %d: got %s, want %s", run, got, want)
+		}
+		if got := fmt.Sprint(a); got != want {
+			t.Fatalf("run %d: formatted as %s, want %s", run, got, want)
+		}
+	}
+	if got := newAllocation(2).String(); got != "{}" {
+		t.Errorf("empty allocation %s, want {}", got)
+	}
+}
+
+// TestUnassignedUtility checks generated bid sets hold an empty Bid for
+// Unassigned and that the items it holds are worth nothing, or exactly their
+// reserve price when reserves are set.
+func TestUnassignedUtility(t *testing.T) {
+	bs := GenerateBidSet(GenOptions{Agents: 2, Items: 3, Seed: 1})
+	if bs[Unassigned] == nil || len(bs[Unassigned]) != 0 {
+		t.Fatalf("bs[Unassigned] = %v, want an empty Bid", bs[Unassigned])
+	}
+	all_unsold := allocationOf(2, map[int][]int{Unassigned: {0, 1, 2}})
+	if u := all_unsold.FindTotalUtility(bs); u != 0 {
+		t.Errorf("unsold items are worth %v, want 0", u)
+	}
+	if u := all_unsold.FindTotalUtilityExceptAgent(bs, 1); u != 0 {
+		t.Errorf("unsold items are worth %v without agent 1, want 0", u)
+	}
+
+	reserves := []float64{0.5, 2, 0}
+	if u := all_unsold.ReserveUtility(reserves); u != 2.5 {
+		t.Errorf("unsold items are worth %v with reserves, want 2.5", u)
+	}
+	bs = BidSet{Bid{}, Bid{0x1: 1, 0x2: 3}}
+	s := SolveAllocationWithOptions(bs, 1, 2, Options{ReservePrices: []float64{1.5, 2}})
+	if got := s.Allocation.String(); got != "agent1:{item1} unsold:{item0}" {
+		t.Errorf("got %s", got)
+	}
+	if s.TotalUtility != 4.5 {
+		t.Errorf("total utility %v, want 3 plus the reserve 1.5 of item 0", s.TotalUtility)
+	}
+}
+
+// TestSellerBundleReserve checks the seller keeps items 0 and 1 together
+// unsold when their bundle is worth more to it than the bidders' bids on
+// them, and sells them once the bids beat it.
+func TestSellerBundleReserve(t *testing.T) {
+	bs := BidSet{Bid{0x3: 5}, Bid{0x1: 2}, Bid{0x2: 2, 0x4: 1}}
+	s := SolveAllocation(bs, 2, 3)
+	if got := s.Allocation.String(); got != "agent2:{item2} unsold:{item0,item1}" || s.TotalUtility != 6 {
+		t.Errorf("got %s worth %v, want item0 and item1 unsold, worth 6", got, s.TotalUtility)
+	}
+
+	bs[1][0x1] = 4.5
+	s = SolveAllocation(bs, 2, 3)
+	if got := s.Allocation.String(); got != "agent1:{item0} agent2:{item1} unsold:{item2}" || s.TotalUtility != 6.5 {
+		t.Errorf("got %s worth %v, want both items sold, worth 6.5", got, s.TotalUtility)
+	}
+
+	// the seller's bid is an OR bid: it keeps any disjoint bundles
+	seller := BidSet{Bid{0x1: 1, 0x2: 1, 0xc: 3, 0x3: 1.5}}
+	if u := allocationOf(0, map[int][]int{Unassigned: {0, 1, 2, 3}}).SellerUtility(seller); u != 5 {
+		t.Errorf("seller keeps everything for %v, want 5", u)
+	}
+	if u := allocationOf(0, map[int][]int{Unassigned: {0, 2}}).SellerUtility(seller); u != 1 {
+		t.Errorf("seller keeps items 0 and 2 for %v, want 1", u)
+	}
+}
+
+func TestDiffAllocations(t *testing.T) {
+	a := allocationOf(2, map[int][]int{0: {2}, 1: {0}, 2: {1}})
+	b := allocationOf(2, map[int][]int{0: {2}, 1: {0, 1}})
+	if got, want := DiffAllocations(a, b), map[int]Move{1: {2, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := DiffAllocations(a, a.Copy()); len(got) != 0 {
+		t.Errorf("got %v for equal allocations", got)
+	}
+
+	// items missing from one side, negative or far out of range
+	b = allocationOf(2, map[int][]int{1: {0, -1}, 2: {1, 1 << 40}})
+	want := map[int]Move{2: {0, -1}, -1: {-1, 1}, 1 << 40: {-1, 2}}
+	if got := DiffAllocations(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	c := allocationOf(2, map[int][]int{0: {2}, 1: {0, -1}, 2: {1}})
+	if got := c.owners(); !reflect.DeepEqual(got, []int{1, 2, 0}) {
+		t.Errorf("owners %v, want the negative item ignored", got)
+	}
+}
+
+// TestFindTotalUtilityStrict checks an item held by two agents, or an agent
+// beyond the allocation, is reported instead of credited, and that other
+// allocations are worth what FindTotalUtility says.
+func TestFindTotalUtilityStrict(t *testing.T) {
+	bs := problem1Bids()
+	a := allocationOf(4, map[int][]int{1: {3}, 2: {0, 1}, 3: {2}})
+	if u, err := a.FindTotalUtilityStrict(bs); err != nil || u != 13 {
+		t.Errorf("got %v, %v, want 13", u, err)
+	}
+	tests := []struct {
+		a   Allocation
+		err string
+	}{
+		{allocationOf(4, map[int][]int{1: {0}, 2: {0, 1}}), "item 0 is held by both agent 1 and agent 2"},
+		{allocationOf(4, map[int][]int{0: {2}, 3: {2}}), "item 2 is held by both agent 0 and agent 3"},
+		{allocationOf(1, map[int][]int{5: {0}}), "agent 5 out of range 0..2"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.a.FindTotalUtilityStrict(bs); err == nil || err.Error() != tt.err {
+			t.Errorf("%v: got error %v, want %q", tt.a, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package vcg
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// SolveBatch solves every auction of bss, auction i with len(bss[i]) - 1
+// agents and ms[i] items as LoadBidSets returns them, and returns the
+// solutions in the same order. Auctions are solved in parallel with each
+// other, each of them sequentially.
+// Like SolveAllocation, it panics if an auction is not valid, before
+// solving any, on the caller's goroutine so the caller may recover.
+func SolveBatch(bss []BidSet, ms []int) (solutions []Solution) {
+	if len(ms) != len(bss) {
+		panic(fmt.Sprintf("vcg: %d numbers of items for %d auctions", len(ms), len(bss)))
+	}
+	for i, bs := range bss {
+		if err := bs.Validate(len(bs)-1, ms[i]); err != nil {
+			panic(fmt.Sprintf("vcg: auction %d: %v", i, err))
+		}
+	}
+	solutions = make([]Solution, len(bss))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0) && w < len(bss); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				solutions[i] = SolveAllocationWithOptions(bss[i], len(bss[i])-1, ms[i], Options{Sequential: true})
+			}
+		}()
+	}
+	for i := range bss {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return
+}
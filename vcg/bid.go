@@ -0,0 +1,147 @@
+package vcg
+
+import (
+	"fmt"
+)
+
+// Agent's bid (mapping of allocation => utility)
+// index is a binary "flag", in which:
+// right-most bit is item 0, second from the right is item 1 and so on
+//
+// Bids are XOR bids: an agent receives at most one of the bundles it bids on
+// and its utility is the utility of exactly the bundle it receives. Bundles
+// which are not listed are worth 0.
+//
+// Utilities may be negative, for bundles an agent would pay to avoid. Agent
+// 0 keeps items at no loss, so such bundles are only allocated under
+// Options.ForceFullAllocation or Options.RequireSale; the optimum found may
+// then be negative, and so may the prices, the agents being paid to take
+// the items.
+type Bid map[int64]float64
+
+// Validate checks that no bundle of the bid holds an item outside 0..m-1.
+func (b Bid) Validate(m int) error {
+	if m < 0 || m > MaxFlagItems {
+		return fmt.Errorf("number of items %d out of range 0..%d", m, MaxFlagItems)
+	}
+	for flags := range b {
+		if flags < 0 || flags&^allItems(m) != 0 {
+			return fmt.Errorf("bundle %b holds items outside 0..%d", uint64(flags), m-1)
+		}
+	}
+	return nil
+}
+
+// ValueOf returns the utility of bundle, 0 if it is not listed.
+func (b Bid) ValueOf(bundle int64) float64 {
+	return b[bundle]
+}
+
+// ValueOfFreeDisposal returns the utility of bundle assuming free disposal:
+// an agent can always throw away items, so a bundle is worth at least as
+// much as any listed bundle within it (and at least 0, the empty bundle).
+func (b Bid) ValueOfFreeDisposal(bundle int64) (u float64) {
+	for flags, utility := range b {
+		if flags&^bundle == 0 && utility > u {
+			u = utility
+		}
+	}
+	return
+}
+
+// MonotoneClosure returns the bid listing every bundle of items 0..m-1 at
+// its ValueOfFreeDisposal, so no bundle is worth less than a bundle within
+// it. Bundles worth 0 are left out, as are the bundles of b holding items
+// outside 0..m-1. It takes time and memory in 2^m, and panics if m is
+// outside 0..MaxFlagItems, as Bid flags cannot hold more items.
+func (b Bid) MonotoneClosure(m int) (closure Bid) {
+	if m < 0 || m > MaxFlagItems {
+		panic(fmt.Sprintf("vcg: closure over %d items, at most %d supported", m, MaxFlagItems))
+	}
+	values := make([]float64, 1<<uint(m))
+	for bundle := range values {
+		values[bundle] = b[int64(bundle)]
+		for rest := bundle; rest != 0; rest &= rest - 1 {
+			if v := values[bundle&^(rest&-rest)]; v > values[bundle] {
+				values[bundle] = v
+			}
+		}
+	}
+	closure = make(Bid)
+	for bundle, value := range values {
+		if value > 0 {
+			closure[int64(bundle)] = value
+		}
+	}
+	return
+}
+
+// Contains bids for all agents (1..n)
+type BidSet []Bid
+
+// Validate checks that bs holds agent 0 and a bid for each of the agents
+// 1..n, and that no bid holds an item outside 0..m-1. The bid of agent 0,
+// the seller's, may be nil. Without agents, bs may also be empty.
+func (bs BidSet) Validate(n, m int) error {
+	if m < 0 || m > MaxFlagItems {
+		return fmt.Errorf("number of items %d out of range 0..%d", m, MaxFlagItems)
+	}
+	if n == 0 && len(bs) == 0 {
+		// no agents and no seller bid
+		return nil
+	}
+	if n < 0 || len(bs) != n+1 {
+		return fmt.Errorf("bid set has %d entries, want %d for agent 0 and %d agents", len(bs), n+1, n)
+	}
+	if err := bs[Unassigned].Validate(m); err != nil {
+		return fmt.Errorf("seller: %v", err)
+	}
+	for agent := 1; agent <= n; agent++ {
+		if bs[agent] == nil {
+			return fmt.Errorf("agent %d: bid is nil", agent)
+		}
+		if err := bs[agent].Validate(m); err != nil {
+			return fmt.Errorf("agent %d: %v", agent, err)
+		}
+	}
+	return nil
+}
+
+// Size returns the number of agents n (not counting agent 0) and the number
+// of items m, which is one more than the highest item any agent bids on.
+func (bs BidSet) Size() (n, m int) {
+	if len(bs) > 0 {
+		n = len(bs) - 1
+	}
+	for _, bid := range bs {
+		for flags := range bid {
+			for uint64(flags)>>uint(m) != 0 {
+				m++
+			}
+		}
+	}
+	return
+}
+
+// CopyExcludingAgent returns a deep copy of bs without the bid of agent
+// (1..n). Agents above it are renumbered one lower: for agent 2 of four,
+// agents 1, 3 and 4 become agents 1, 2 and 3 of the copy. Agent 0 keeps its
+// bid. Pricing does not use it; it leaves agents out in place, so every
+// agent keeps its number, see Solution.CalculatePricesWithOptions.
+func (bs BidSet) CopyExcludingAgent(agent int) (new_bs BidSet) {
+	new_bs = make(BidSet, len(bs)-1)
+	for a, bid := range bs {
+		if a < agent {
+			new_bs[a] = make(Bid)
+			for k, v := range bid {
+				new_bs[a][k] = v
+			}
+		} else if a > agent {
+			new_bs[a-1] = make(Bid)
+			for k, v := range bid {
+				new_bs[a-1][k] = v
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package vcg
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBidValidate(t *testing.T) {
+	tests := []struct {
+		bid Bid
+		m   int
+		ok  bool
+	}{
+		{Bid{}, 0, true},
+		{Bid{0x1: 1, 0x6: 2, 0x7: 3}, 3, true},
+		{Bid{0x8: 1}, 3, false},
+		{Bid{0x1: 1, 0x9: 2}, 3, false},
+		{Bid{-1: 1}, 3, false},
+		{Bid{0x1: 1}, -1, false},
+		{Bid{0x1: 1}, MaxFlagItems + 1, false},
+	}
+	for _, tt := range tests {
+		if err := tt.bid.Validate(tt.m); (err == nil) != tt.ok {
+			t.Errorf("%v.Validate(%d) = %v, want ok %v", tt.bid, tt.m, err, tt.ok)
+		}
+	}
+}
+
+func TestBidValueOf(t *testing.T) {
+	b := Bid{0x1: 2, 0x3: 5}
+	for bundle, want := range map[int64]float64{0x0: 0, 0x1: 2, 0x2: 0, 0x3: 5, 0x7: 0} {
+		if got := b.ValueOf(bundle); got != want {
+			t.Errorf("ValueOf(%b) = %v, want %v", bundle, got, want)
+		}
+	}
+}
+
+// TestBidIsXOR checks an agent bidding on two disjoint bundles receives
+// only one of them: the other goes unsold rather than adding up.
+func TestBidIsXOR(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 3, 0x2: 4}}
+	s := SolveAllocation(bs, 1, 2)
+	if s.TotalUtility != 4 {
+		t.Errorf("utility %v, want 4", s.TotalUtility)
+	}
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("items owned by %v, want [0 1]", got)
+	}
+}
+
+func TestBidSetValidate(t *testing.T) {
+	tests := []struct {
+		bs   BidSet
+		n, m int
+		err  string
+	}{
+		{BidSet{nil, Bid{0x1: 1}, Bid{}}, 2, 1, ""},
+		{BidSet{}, 0, 2, ""},
+		{BidSet{nil, Bid{0x1: 1}}, 2, 1, "bid set has 2 entries, want 3 for agent 0 and 2 agents"},
+		{BidSet{nil, Bid{0x1: 1}, Bid{}, Bid{}}, 2, 1, "bid set has 4 entries, want 3"},
+		{BidSet{nil, Bid{0x1: 1}, nil}, 2, 1, "agent 2: bid is nil"},
+		{BidSet{nil, Bid{0x4: 1}}, 1, 2, "agent 1: bundle 100 holds items outside 0..1"},
+		{BidSet{Bid{0x4: 1}, Bid{}}, 1, 2, "seller: bundle 100 holds items outside 0..1"},
+		{BidSet{nil}, 0, -1, "number of items -1 out of range"},
+	}
+	for _, tt := range tests {
+		err := tt.bs.Validate(tt.n, tt.m)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%v.Validate(%d, %d) = %v", tt.bs, tt.n, tt.m, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v.Validate(%d, %d) = %v, want %q", tt.bs, tt.n, tt.m, err, tt.err)
+		}
+	}
+}
+
+// TestSolveAllocationInvalid checks an invalid bid set makes the solver
+// panic with the error of Validate, or return it with a context.
+func TestSolveAllocationInvalid(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 1}}
+	if _, err := SolveAllocationContext(context.Background(), bs, 2, 1); err == nil {
+		t.Error("SolveAllocationContext: no error for a too short bid set")
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "bid set has 2 entries") {
+			t.Errorf("SolveAllocation panicked with %v", r)
+		}
+	}()
+	SolveAllocation(bs, 2, 1)
+}
+
+// TestMonotoneClosure corrects a bid in which the bundle of items 0 and 1
+// is worth less than item 0 alone and checks the result is monotone.
+func TestMonotoneClosure(t *testing.T) {
+	b := Bid{0x1: 4, 0x2: 1, 0x3: 3, 0x4: -1, 0x8: 2}
+	got := b.MonotoneClosure(3)
+	want := Bid{0x1: 4, 0x2: 1, 0x3: 4, 0x5: 4, 0x6: 1, 0x7: 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for bundle := int64(0); bundle < 8; bundle++ {
+		for sub := bundle; sub != 0; sub = (sub - 1) & bundle {
+			if got[sub] > got[bundle] {
+				t.Errorf("bundle %b is worth %v, less than %v for %b", bundle, got[bundle], got[sub], sub)
+			}
+		}
+	}
+	// monotone bids are left as they are
+	if again := got.MonotoneClosure(3); !reflect.DeepEqual(again, got) {
+		t.Errorf("closure of the closure is %v", again)
+	}
+}
+
+// TestMonotoneClosureTooManyItems checks the closure refuses more items
+// than Bid flags hold instead of allocating 2^m values.
+func TestMonotoneClosureTooManyItems(t *testing.T) {
+	for _, m := range []int{-1, MaxFlagItems + 1} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "at most 63 supported") {
+					t.Errorf("m = %d: panicked with %v", m, r)
+				}
+			}()
+			Bid{0x1: 1}.MonotoneClosure(m)
+		}()
+	}
+}
+
+// TestCopyExcludingAgent leaves agent 2 of four out and checks agents 1, 3
+// and 4 keep their bids as agents 1, 2 and 3 of a copy that shares no maps.
+func TestCopyExcludingAgent(t *testing.T) {
+	bs := BidSet{Bid{0x1: 0.5}, Bid{0x1: 1}, Bid{0x2: 2}, Bid{0x3: 3}, Bid{0x1: 4, 0x2: 4}}
+	got := bs.CopyExcludingAgent(2)
+	want := BidSet{Bid{0x1: 0.5}, Bid{0x1: 1}, Bid{0x3: 3}, Bid{0x1: 4, 0x2: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for a := range got {
+		got[a][0x4] = -1
+	}
+	if _, ok := bs[1][0x4]; ok {
+		t.Error("the copy shares bids with the original")
+	}
+	if len(bs) != 5 || bs[2][0x2] != 2 {
+		t.Errorf("original changed to %v", bs)
+	}
+}
@@ -0,0 +1,152 @@
+package vcg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// binaryVersion is the first byte of the encoding of SaveBidSetBinary.
+// Version 1 did not hold the number of items.
+const binaryVersion = 2
+
+// SaveBidSetBinary writes bs for m items compactly, in an encoding which
+// LoadBidSetBinary reads back: a version byte, m, the number of bids and,
+// for every bid, the number of its bundles plus one (0 for a nil bid)
+// followed by its bundles in increasing order. Every bundle is the varint
+// difference to the previous one followed by its value as 8 bytes, so values
+// round-trip exactly. Items nobody bids on are kept by m, as in SaveBidSet.
+func SaveBidSetBinary(w io.Writer, bs BidSet, m int) error {
+	data, err := encodeBinary(bs, m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// LoadBidSetBinary reads bids written by SaveBidSetBinary and returns them
+// together with the number of agents n and items m.
+func LoadBidSetBinary(r io.Reader) (bs BidSet, n, m int, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if bs, m, err = decodeBinary(data); err != nil {
+		return nil, 0, 0, err
+	}
+	if len(bs) > 0 {
+		n = len(bs) - 1
+	}
+	return bs, n, m, nil
+}
+
+// MarshalBinary encodes bs like SaveBidSetBinary, for the number of items
+// BidSet.Size finds in it.
+func (bs BidSet) MarshalBinary() (data []byte, err error) {
+	_, m := bs.Size()
+	return encodeBinary(bs, m)
+}
+
+// UnmarshalBinary decodes a BidSet written by MarshalBinary or
+// SaveBidSetBinary into bs, dropping the number of items.
+func (bs *BidSet) UnmarshalBinary(data []byte) error {
+	decoded, _, err := decodeBinary(data)
+	if err != nil {
+		return err
+	}
+	*bs = decoded
+	return nil
+}
+
+func encodeBinary(bs BidSet, m int) (data []byte, err error) {
+	if m < 0 || m > MaxFlagItems {
+		return nil, fmt.Errorf("vcg: number of items %d out of range 0..%d", m, MaxFlagItems)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	put := func(x uint64) {
+		data = append(data, buf[:binary.PutUvarint(buf, x)]...)
+	}
+	data = append(data, binaryVersion)
+	put(uint64(m))
+	put(uint64(len(bs)))
+	for _, bid := range bs {
+		if bid == nil {
+			put(0)
+			continue
+		}
+		put(uint64(len(bid)) + 1)
+		bundles := make([]int64, 0, len(bid))
+		for flags := range bid {
+			if flags < 0 || flags&^allItems(m) != 0 {
+				return nil, fmt.Errorf("vcg: cannot encode bundle %d of %d items", flags, m)
+			}
+			bundles = append(bundles, flags)
+		}
+		sort.Slice(bundles, func(i, j int) bool { return bundles[i] < bundles[j] })
+		var previous int64
+		for _, flags := range bundles {
+			put(uint64(flags - previous))
+			previous = flags
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(bid[flags]))
+			data = append(data, buf[:8]...)
+		}
+	}
+	return
+}
+
+// errBinary reports data which encodeBinary did not write.
+var errBinary = errors.New("vcg: invalid binary bid set")
+
+func decodeBinary(data []byte) (bs BidSet, m int, err error) {
+	if len(data) == 0 || data[0] != binaryVersion {
+		return nil, 0, errBinary
+	}
+	data = data[1:]
+	get := func() (uint64, bool) {
+		x, k := binary.Uvarint(data)
+		if k <= 0 {
+			return 0, false
+		}
+		data = data[k:]
+		return x, true
+	}
+	items, ok := get()
+	if !ok || items > MaxFlagItems {
+		return nil, 0, errBinary
+	}
+	m = int(items)
+	count, ok := get()
+	if !ok || count > uint64(len(data)) {
+		return nil, 0, errBinary
+	}
+	bs = make(BidSet, count)
+	for agent := range bs {
+		bundles, ok := get()
+		if !ok || bundles > uint64(len(data))+1 {
+			return nil, 0, errBinary
+		}
+		if bundles == 0 {
+			continue
+		}
+		bs[agent] = make(Bid, bundles-1)
+		var flags uint64
+		for i := uint64(1); i < bundles; i++ {
+			delta, ok := get()
+			if !ok || len(data) < 8 || delta > uint64(allItems(m))-flags || (i > 1 && delta == 0) {
+				return nil, 0, errBinary
+			}
+			flags += delta
+			bs[agent][int64(flags)] = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		}
+	}
+	if len(data) != 0 {
+		return nil, 0, errBinary
+	}
+	return bs, m, nil
+}
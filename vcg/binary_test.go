@@ -0,0 +1,97 @@
+package vcg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestBinaryRoundTrip encodes a random sparse bid set with a nil seller
+// bid and some awkward values, checks it decodes to exactly the same bids,
+// and logs how much smaller it is than JSON. Item 62, the highest, is not
+// bid on, but the number of items keeps it.
+func TestBinaryRoundTrip(t *testing.T) {
+	const n, m = 6, 12
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: 0.7, Seed: 1})
+	bs[Unassigned] = nil
+	bs[1][0] = math.Copysign(0, -1)
+	bs[2][1<<61] = math.Inf(-1)
+	bs[3][0x3] = math.SmallestNonzeroFloat64
+
+	var data bytes.Buffer
+	if err := SaveBidSetBinary(&data, bs, MaxFlagItems); err != nil {
+		t.Fatal(err)
+	}
+	size := data.Len()
+	got, got_n, got_m, err := LoadBidSetBinary(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, bs) || math.Signbit(got[1][0]) != true {
+		t.Errorf("decoded bids differ from the encoded ones")
+	}
+	if got_n != n || got_m != MaxFlagItems {
+		t.Errorf("decoded %d agents and %d items, want %d and %d", got_n, got_m, n, MaxFlagItems)
+	}
+
+	delete(bs[2], 1<<61)
+	var doc bytes.Buffer
+	if err := SaveBidSet(&doc, bs, m); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d bytes in binary, %d in JSON (%.0f%%)", size, doc.Len(), 100*float64(size)/float64(doc.Len()))
+	if size >= doc.Len() {
+		t.Errorf("%d bytes in binary, no fewer than %d in JSON", size, doc.Len())
+	}
+}
+
+// TestMarshalBinary checks MarshalBinary and UnmarshalBinary round-trip the
+// bids, for the items BidSet.Size finds.
+func TestMarshalBinary(t *testing.T) {
+	bs := problem1Bids()
+	data, err := bs.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got BidSet
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, bs) {
+		t.Errorf("decoded %v, want %v", got, bs)
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	data, err := problem1Bids().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{
+		nil,
+		{2},
+		{binaryVersion},
+		{binaryVersion, 200},
+		data[:len(data)-1],
+		append(append([]byte(nil), data...), 0),
+		{1, 1, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		{binaryVersion, 64, 0},
+		// a bundle holding item 1 of a single item
+		{binaryVersion, 1, 1, 2, 2, 0, 0, 0, 0, 0, 0, 0, 0},
+		// a bid of two bundles with the same flags
+		{binaryVersion, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		var bs BidSet
+		if err := bs.UnmarshalBinary(bad); err != errBinary {
+			t.Errorf("%v: got error %v", bad, err)
+		}
+	}
+	if _, err := (BidSet{Bid{-1: 1}}).MarshalBinary(); err == nil {
+		t.Error("encoded a negative bundle")
+	}
+	if err := SaveBidSetBinary(ioutil.Discard, BidSet{Bid{0x2: 1}}, 1); err == nil {
+		t.Error("encoded a bundle of item 1 for a single item")
+	}
+}
@@ -0,0 +1,63 @@
+package vcg
+
+import (
+	"fmt"
+)
+
+// BudgetError is returned by CalculatePricesWithOptions when the VCG price
+// of some agents exceeds their budget in Options.Budgets.
+type BudgetError struct {
+	Agents []int // in increasing order
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("agents %v cannot pay their prices within budget", e.Agents)
+}
+
+// BudgetViolations returns the agents whose price exceeds their budget.
+// budgets is indexed by agent like Options.Budgets. It returns nil when
+// prices have not been calculated.
+func (s *Solution) BudgetViolations(budgets []float64) (agents []int) {
+	for agent := 1; agent < len(budgets); agent++ {
+		if price, ok := s.PricePerAgent[agent]; ok && price > budgets[agent]+priceTolerance {
+			agents = append(agents, agent)
+		}
+	}
+	return
+}
+
+// SolveBudgetFeasible solves and prices the auction like
+// SolveAllocationWithOptions and CalculatePricesWithOptions, and while some
+// agent cannot afford its price, removes the bundle it won from its bid and
+// solves again. Prices are calculated on the remaining bids, so the result
+// is budget-feasible but no longer the VCG outcome of bs.
+//
+// bs is not modified. An agent which won a bundle it did not bid on (see
+// Options.DefaultValue) cannot be excluded from it, in which case the
+// *BudgetError is returned along with the solution.
+func SolveBudgetFeasible(bs BidSet, n, m int, opts Options) (s Solution, err error) {
+	for {
+		s = SolveAllocationWithOptions(bs, n, m, opts)
+		err = s.CalculatePricesWithOptions(bs, n, m, opts)
+		budget_err, ok := err.(*BudgetError)
+		if !ok {
+			return
+		}
+
+		new_bs := make(BidSet, len(bs))
+		copy(new_bs, bs)
+		for _, agent := range budget_err.Agents {
+			bundle := s.Allocation.Flags(agent)
+			if _, ok := bs[agent][bundle]; !ok {
+				return
+			}
+			new_bs[agent] = make(Bid)
+			for flags, value := range bs[agent] {
+				if flags != bundle {
+					new_bs[agent][flags] = value
+				}
+			}
+		}
+		bs = new_bs
+	}
+}
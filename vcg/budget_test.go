@@ -0,0 +1,52 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestBudgetViolation prices the problem1 example, in which agent 2 pays
+// 4, with a budget of 3 for agent 2.
+func TestBudgetViolation(t *testing.T) {
+	bs := problem1Bids()
+	opts := Options{Budgets: []float64{0, 5, 3, 5, 5}}
+	s := SolveAllocationWithOptions(bs, 4, 4, opts)
+	err := s.CalculatePricesWithOptions(bs, 4, 4, opts)
+	budget_err, ok := err.(*BudgetError)
+	if !ok {
+		t.Fatalf("got error %v, want a *BudgetError", err)
+	}
+	if !reflect.DeepEqual(budget_err.Agents, []int{2}) {
+		t.Errorf("agents %v violate their budget, want [2]", budget_err.Agents)
+	}
+	if s.PricePerAgent[2] != 4 {
+		t.Errorf("agent 2 pays %v, want 4", s.PricePerAgent[2])
+	}
+
+	// within budget, or without budgets, there is no error
+	for _, budgets := range [][]float64{nil, {0, 3, 4, 2, 0}} {
+		if err := s.CalculatePricesWithOptions(bs, 4, 4, Options{Budgets: budgets}); err != nil {
+			t.Errorf("budgets %v: %v", budgets, err)
+		}
+	}
+}
+
+// TestSolveBudgetFeasible removes the bundle agent 2 cannot afford and
+// solves again.
+func TestSolveBudgetFeasible(t *testing.T) {
+	bs := problem1Bids()
+	opts := Options{Budgets: []float64{0, 5, 3, 5, 5}}
+	s, err := SolveBudgetFeasible(bs, 4, 4, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Allocation.Flags(2) == 0x3 {
+		t.Errorf("agent 2 still wins the bundle it cannot afford: %v", s.Allocation)
+	}
+	if v := s.BudgetViolations(opts.Budgets); v != nil {
+		t.Errorf("agents %v violate their budget", v)
+	}
+	if _, ok := bs[2][0x3]; !ok {
+		t.Error("bs was modified")
+	}
+}
@@ -0,0 +1,296 @@
+package vcg
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxFlagItems is the number of items which fit into the int64 flags used as
+// Bid keys. Instances with more items need Bundle and WideBid.
+const MaxFlagItems = 63
+
+// Bundle is a set of items of any size.
+// Item i is bit i%64 of word i/64.
+type Bundle []uint64
+
+func NewBundle(items ...int) (b Bundle) {
+	for _, item := range items {
+		b = b.Add(item)
+	}
+	return
+}
+
+// Add returns the bundle with item added, growing it if needed.
+func (b Bundle) Add(item int) Bundle {
+	for len(b) <= item/64 {
+		b = append(b, 0)
+	}
+	b[item/64] |= 1 << uint(item%64)
+	return b
+}
+
+func (b Bundle) Has(item int) bool {
+	return item/64 < len(b) && b[item/64]&(1<<uint(item%64)) != 0
+}
+
+// Items returns the items of the bundle in increasing order.
+func (b Bundle) Items() (items []int) {
+	for w, word := range b {
+		for bit := 0; bit < 64; bit++ {
+			if word&(1<<uint(bit)) != 0 {
+				items = append(items, w*64+bit)
+			}
+		}
+	}
+	return
+}
+
+// Flags returns the bundle as Bid flags, if all its items are below
+// MaxFlagItems.
+func (b Bundle) Flags() (flags int64, ok bool) {
+	for w := 1; w < len(b); w++ {
+		if b[w] != 0 {
+			return 0, false
+		}
+	}
+	if len(b) == 0 {
+		return 0, true
+	}
+	if b[0]>>MaxFlagItems != 0 {
+		return 0, false
+	}
+	return int64(b[0]), true
+}
+
+// Key returns a canonical string for the bundle, usable as a map key.
+// Bundles holding the same items have the same key regardless of their length.
+func (b Bundle) Key() string {
+	words := len(b)
+	for words > 0 && b[words-1] == 0 {
+		words--
+	}
+	buf := make([]byte, 8*words)
+	for w := 0; w < words; w++ {
+		binary.LittleEndian.PutUint64(buf[8*w:], b[w])
+	}
+	return string(buf)
+}
+
+// Agent's bid for instances with more than MaxFlagItems items
+// (mapping of Bundle.Key() => utility)
+type WideBid map[string]float64
+
+func (b WideBid) Set(bundle Bundle, utility float64) {
+	b[bundle.Key()] = utility
+}
+
+func (b WideBid) Get(bundle Bundle) float64 {
+	return b[bundle.Key()]
+}
+
+// bundleOfKey returns the bundle whose Key is key.
+func bundleOfKey(key string) (b Bundle) {
+	for w := 0; w+8 <= len(key); w += 8 {
+		b = append(b, binary.LittleEndian.Uint64([]byte(key[w:w+8])))
+	}
+	return
+}
+
+// Contains wide bids for all agents (1..n)
+type WideBidSet []WideBid
+
+// Wide returns the bids of bs as wide bids.
+func (bs BidSet) Wide() (wide WideBidSet) {
+	wide = make(WideBidSet, len(bs))
+	for agent, bid := range bs {
+		if bid == nil {
+			continue
+		}
+		wide[agent] = make(WideBid)
+		for flags, utility := range bid {
+			var b Bundle
+			for item := range flagsToItems(flags) {
+				b = b.Add(item)
+			}
+			wide[agent].Set(b, utility)
+		}
+	}
+	return
+}
+
+// Validate checks that bs holds agent 0 and a bid for each of the agents
+// 1..n, that no bid holds an item outside 0..m-1, and that
+// SolveAllocationWide can solve it: the seller bids nothing, and the search
+// is left with at most MaxFlagItems items, see SearchedItems. Without
+// agents, bs may also be empty.
+func (bs WideBidSet) Validate(n, m int) error {
+	if m < 0 {
+		return fmt.Errorf("number of items %d is negative", m)
+	}
+	if n == 0 && len(bs) == 0 {
+		// no agents and no seller bid
+		return nil
+	}
+	if n < 0 || len(bs) != n+1 {
+		return fmt.Errorf("bid set has %d entries, want %d for agent 0 and %d agents", len(bs), n+1, n)
+	}
+	if len(bs[Unassigned]) != 0 {
+		return fmt.Errorf("seller: bids are not supported with wide bids")
+	}
+	for agent := 1; agent <= n; agent++ {
+		if bs[agent] == nil {
+			return fmt.Errorf("agent %d: bid is nil", agent)
+		}
+		for key := range bs[agent] {
+			if items := bundleOfKey(key).Items(); len(items) > 0 && items[len(items)-1] >= m {
+				return fmt.Errorf("agent %d: bundle %v holds items outside 0..%d", agent, items, m-1)
+			}
+		}
+	}
+	if k := len(bs.SearchedItems(n, m)); k > MaxFlagItems {
+		return fmt.Errorf("%d items to search, at most %d supported", k, MaxFlagItems)
+	}
+	return nil
+}
+
+// SearchedItems returns the items of 0..m-1 SolveAllocationWide searches,
+// in increasing order: those any of agents 1..n bids on, plus one item
+// nobody bids on for every agent with a negative bid, if there are that
+// many. Such an item makes any bundle worth 0, as bundles which are not bid
+// on are, so it only matters to an agent which may be stuck with a negative
+// bundle otherwise, and one is enough. The other items are left unsold.
+func (bs WideBidSet) SearchedItems(n, m int) (items []int) {
+	bidden, spares := bs.biddenItems(n), 0
+	for agent := 1; agent <= n && agent < len(bs); agent++ {
+		for _, utility := range bs[agent] {
+			if utility < 0 {
+				spares++
+				break
+			}
+		}
+	}
+	for item := 0; item < m; item++ {
+		if bidden[item] {
+			items = append(items, item)
+		} else if spares > 0 {
+			items = append(items, item)
+			spares--
+		}
+	}
+	return
+}
+
+// biddenItems returns the items any of agents 1..n bids on.
+func (bs WideBidSet) biddenItems(n int) (bidden map[int]bool) {
+	bidden = make(map[int]bool)
+	for agent := 1; agent <= n && agent < len(bs); agent++ {
+		for key := range bs[agent] {
+			for _, item := range bundleOfKey(key).Items() {
+				bidden[item] = true
+			}
+		}
+	}
+	return
+}
+
+// narrow returns bs as bids on items only, numbered 0..len(items)-1 in
+// their order, and the index of every item in items.
+func (bs WideBidSet) narrow(items []int, n int) (narrow BidSet, index map[int]uint) {
+	index = make(map[int]uint)
+	for i, item := range items {
+		index[item] = uint(i)
+	}
+	narrow = make(BidSet, n+1)
+	narrow[Unassigned] = make(Bid)
+	for agent := 1; agent <= n; agent++ {
+		narrow[agent] = make(Bid)
+		for key, utility := range bs[agent] {
+			var flags int64
+			for _, item := range bundleOfKey(key).Items() {
+				flags |= 1 << index[item]
+			}
+			narrow[agent][flags] = utility
+		}
+	}
+	return
+}
+
+// SolveAllocationWide is SolveAllocation for instances with more than
+// MaxFlagItems items.
+//
+// The exhaustive search takes (n+1)^m steps, far too many for m above
+// MaxFlagItems, so it only searches the SearchedItems of bs, k of them, in
+// (n+1)^k steps as SolveAllocation does, and leaves the other items unsold.
+// That takes seconds for k around 10 with a few agents. It does not take
+// Options: those which refer to items hold them as Bid flags. Like
+// SolveAllocation, it panics if bs is not valid, see WideBidSet.Validate.
+func SolveAllocationWide(bs WideBidSet, n, m int) (s Solution) {
+	if err := bs.Validate(n, m); err != nil {
+		panic("vcg: " + err.Error())
+	}
+	items := bs.SearchedItems(n, m)
+	narrow, index := bs.narrow(items, n)
+	s = SolveAllocation(narrow, n, len(items))
+	a := newAllocation(n)
+	for agent, held := range s.Allocation {
+		for i := range held {
+			a[agent][items[i]] = true
+		}
+	}
+	for item := 0; item < m; item++ {
+		if _, ok := index[item]; !ok {
+			a[Unassigned][item] = true
+		}
+	}
+	s.Allocation = a
+	return
+}
+
+// CalculatePricesWide is CalculatePrices for a solution found by
+// SolveAllocationWide. It prices the allocation of the SearchedItems of bs
+// with CalculatePrices, so the prices are those of the whole auction.
+func (s *Solution) CalculatePricesWide(bs WideBidSet, n, m int) error {
+	if err := bs.Validate(n, m); err != nil {
+		return err
+	}
+	items := bs.SearchedItems(n, m)
+	narrow, index := bs.narrow(items, n)
+
+	// items nobody bids on are worth the same to everyone, so an agent
+	// holding one may hold any searched item nobody bids on instead
+	bidden := bs.biddenItems(n)
+	var spares []uint
+	for _, item := range items {
+		if !bidden[item] {
+			spares = append(spares, index[item])
+		}
+	}
+	ns := *s
+	ns.Allocation = newAllocation(len(s.Allocation) - 1)
+	for agent, held := range s.Allocation {
+		spare := false
+		for item := range held {
+			i, ok := index[item]
+			switch {
+			case ok:
+				ns.Allocation[agent][int(i)] = true
+			case agent == Unassigned || spare:
+				// another item nobody bids on changes nothing
+			case len(spares) == 0:
+				return fmt.Errorf("allocation gives items nobody bids on to more agents than SolveAllocationWide does")
+			default:
+				spare = true
+				ns.Allocation[agent][int(spares[0])] = true
+				spares = spares[1:]
+			}
+		}
+	}
+	for _, i := range spares {
+		ns.Allocation[Unassigned][int(i)] = true
+	}
+	if err := ns.CalculatePrices(narrow, n, len(items)); err != nil {
+		return err
+	}
+	s.PricePerAgent, s.Breakdown = ns.PricePerAgent, ns.Breakdown
+	return nil
+}
@@ -0,0 +1,47 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBundleBeyondOneWord(t *testing.T) {
+	b := NewBundle(3, 64, 80)
+	for _, item := range []int{3, 64, 80} {
+		if !b.Has(item) {
+			t.Errorf("bundle %v does not hold item %d", b, item)
+		}
+	}
+	for _, item := range []int{0, 63, 79, 81, 200} {
+		if b.Has(item) {
+			t.Errorf("bundle %v holds item %d", b, item)
+		}
+	}
+	if got, want := b.Items(), []int{3, 64, 80}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Items() = %v, want %v", got, want)
+	}
+	if _, ok := b.Flags(); ok {
+		t.Errorf("Flags() of %v is ok, want not ok", b)
+	}
+	if got := bundleOfKey(b.Key()).Items(); !reflect.DeepEqual(got, []int{3, 64, 80}) {
+		t.Errorf("bundleOfKey(Key()) holds %v, want [3 64 80]", got)
+	}
+}
+
+// TestAllocationItem80 gives item 80 to an agent and checks its bid on the
+// bundle is found, however long the bundle it bid on.
+func TestAllocationItem80(t *testing.T) {
+	bid := make(WideBid)
+	bid.Set(NewBundle(80), 7)
+	bid.Set(Bundle{0, 1 << 16, 0, 0}, 9) // item 80 again, with trailing words
+	if got := bid.Get(NewBundle(80)); got != 9 {
+		t.Errorf("Get(item 80) = %v, want 9", got)
+	}
+	a := newAllocation(2)
+	a[1][80] = true
+	a[2][5] = true
+	bs := WideBidSet{nil, bid, make(WideBid)}
+	if got := a.FindTotalUtilityWide(bs); got != 9 {
+		t.Errorf("FindTotalUtilityWide = %v, want 9", got)
+	}
+}
@@ -0,0 +1,122 @@
+package vcg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// checkpointVersion is the version of the format written by SaveCheckpoint.
+const checkpointVersion = 1
+
+// checkpoint is the state of an interrupted iterative search: the frames of
+// its stack, the topmost one not entered yet, and the best allocation found
+// so far. The frames of a search which was never interrupted are nil.
+type checkpoint struct {
+	agents, items int
+	stack         []frame
+	best          Solution // Allocation is nil if nothing was offered yet
+}
+
+// jsonCheckpoint is the JSON document written by SaveCheckpoint. Stack holds
+// the depth and agent of every frame, Owners the agent holding each item in
+// the best allocation, if any.
+type jsonCheckpoint struct {
+	Version   int      `json:"version"`
+	Agents    int      `json:"agents"`
+	Items     int      `json:"items"`
+	Stack     [][2]int `json:"stack"`
+	Owners    []int    `json:"owners,omitempty"`
+	Utility   float64  `json:"utility"`
+	Evaluated int64    `json:"evaluated"`
+	Nodes     int64    `json:"nodes"`
+	RunnerUp  float64  `json:"runner_up"`
+}
+
+// errNoCheckpoint is returned by SaveCheckpoint when there is nothing to
+// save.
+var errNoCheckpoint = errors.New("vcg: no interrupted search to checkpoint")
+
+// SaveCheckpoint writes the state of the search interrupted by the last
+// SolveContext as JSON, so LoadCheckpoint can resume it later, possibly in
+// another process. Only the exhaustive search run sequentially with
+// Options.Iterative can be checkpointed; otherwise, or if the last solve
+// completed, there is nothing to save and an error is returned.
+func (sv *Solver) SaveCheckpoint(w io.Writer) error {
+	cp := sv.cp
+	if cp == nil || cp.stack == nil {
+		return errNoCheckpoint
+	}
+	out := jsonCheckpoint{
+		Version:   checkpointVersion,
+		Agents:    cp.agents,
+		Items:     cp.items,
+		Utility:   cp.best.TotalUtility,
+		Evaluated: cp.best.Evaluated,
+		Nodes:     cp.best.Nodes,
+		RunnerUp:  cp.best.RunnerUpUtility,
+	}
+	for _, f := range cp.stack {
+		out.Stack = append(out.Stack, [2]int{f.depth, f.agent})
+	}
+	if cp.best.Allocation != nil {
+		out.Owners = cp.best.Allocation.owners()
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint. The next
+// Solve or SolveContext resumes the interrupted search instead of starting
+// over, sequentially with Options.Iterative whatever the options. It must
+// be given the same bids, and the Solver the same options, as the solve
+// which was interrupted, or the result is meaningless.
+func (sv *Solver) LoadCheckpoint(r io.Reader) error {
+	var in jsonCheckpoint
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return err
+	}
+	if in.Version != checkpointVersion {
+		return fmt.Errorf("vcg: unsupported checkpoint version %d", in.Version)
+	}
+	if in.Agents < 0 || in.Items < 0 || len(in.Stack) == 0 || len(in.Stack) > in.Items+1 {
+		return errors.New("vcg: invalid checkpoint")
+	}
+	cp := &checkpoint{agents: in.Agents, items: in.Items}
+	for i, f := range in.Stack {
+		if f[0] != i || f[1] < -1 || f[1] > in.Agents {
+			return fmt.Errorf("vcg: invalid checkpoint frame %d", i)
+		}
+		cp.stack = append(cp.stack, frame{f[0], f[1]})
+	}
+	if in.Owners != nil {
+		if len(in.Owners) > in.Items {
+			return errors.New("vcg: invalid checkpoint allocation")
+		}
+		cp.best.Allocation = newAllocation(in.Agents)
+		for item, agent := range in.Owners {
+			if agent < 0 || agent > in.Agents {
+				return fmt.Errorf("vcg: invalid owner of item %d in checkpoint", item)
+			}
+			cp.best.Allocation[agent][item] = true
+		}
+		cp.best.TotalUtility = in.Utility
+	}
+	cp.best.Evaluated = in.Evaluated
+	cp.best.Nodes = in.Nodes
+	cp.best.RunnerUpUtility = in.RunnerUp
+	sv.cp = cp
+	return nil
+}
+
+// resumes reports whether the search should continue from cp, which must
+// be for n agents and m items.
+func (cp *checkpoint) resumes(n, m int) (bool, error) {
+	if cp == nil || cp.stack == nil {
+		return false, nil
+	}
+	if cp.agents != n || cp.items != m {
+		return false, fmt.Errorf("checkpoint is for %d agents and %d items, not %d and %d", cp.agents, cp.items, n, m)
+	}
+	return true, nil
+}
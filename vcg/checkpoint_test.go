@@ -0,0 +1,91 @@
+package vcg
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCheckpointResume interrupts an iterative search every time it reports
+// progress twice, saves a checkpoint, and resumes it with a fresh Solver
+// until it completes. The result must be that of an uninterrupted run.
+func TestCheckpointResume(t *testing.T) {
+	const n, m = 3, 10
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: 0.3, Seed: 7})
+	opts := Options{Iterative: true, Sequential: true}
+	want := SolveAllocationWithOptions(bs, n, m, opts)
+
+	var saved []byte
+	interruptions := 0
+	for {
+		sv := &Solver{Options: opts}
+		if saved != nil {
+			if err := sv.LoadCheckpoint(bytes.NewReader(saved)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		reports := 0
+		sv.OnProgress = func(visited, total int64) {
+			if reports++; reports == 2 {
+				cancel()
+			}
+		}
+		got, err := sv.SolveContext(ctx, bs)
+		cancel()
+		if err == nil {
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("after %d interruptions: got %+v, want %+v", interruptions, got, want)
+			}
+			break
+		}
+		if err != context.Canceled {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := sv.SaveCheckpoint(&buf); err != nil {
+			t.Fatal(err)
+		}
+		saved = buf.Bytes()
+		if interruptions++; interruptions > 100 {
+			t.Fatal("the search makes no progress")
+		}
+	}
+	if interruptions < 2 {
+		t.Errorf("interrupted only %d times", interruptions)
+	}
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	sv := &Solver{Options: Options{Iterative: true, Sequential: true}}
+	if err := sv.SaveCheckpoint(new(bytes.Buffer)); err != errNoCheckpoint {
+		t.Errorf("saving before any solve: got error %v", err)
+	}
+	sv.Solve(problem1Bids())
+	if err := sv.SaveCheckpoint(new(bytes.Buffer)); err != errNoCheckpoint {
+		t.Errorf("saving after a complete solve: got error %v", err)
+	}
+
+	for _, test := range []struct {
+		doc, err string
+	}{
+		{`{"version": 2}`, "vcg: unsupported checkpoint version 2"},
+		{`{"version": 1, "agents": 2, "items": 2, "stack": []}`, "vcg: invalid checkpoint"},
+		{`{"version": 1, "agents": 2, "items": 2, "stack": [[0, 1], [2, 0]]}`, "vcg: invalid checkpoint frame 1"},
+		{`{"version": 1, "agents": 2, "items": 2, "stack": [[0, 1]], "owners": [0, 3]}`, "vcg: invalid owner of item 1 in checkpoint"},
+	} {
+		if err := sv.LoadCheckpoint(strings.NewReader(test.doc)); err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %q", test.doc, err, test.err)
+		}
+	}
+
+	// a checkpoint only resumes a search of the same size
+	if err := sv.LoadCheckpoint(strings.NewReader(`{"version": 1, "agents": 2, "items": 2, "stack": [[0, 1]]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sv.SolveContext(context.Background(), problem1Bids()); err == nil || err.Error() != "vcg: checkpoint is for 2 agents and 2 items, not 4 and 4" {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package vcg
+
+// RunClockAuction simulates a simultaneous ascending clock auction of
+// len(startPrices) items between the agents 1..n of vs. In every round
+// each agent demands the bundle maximizing its value minus the prices of
+// its items, and the price of every item demanded by more than one agent
+// rises by increment. Once no item is over-demanded, each agent receives
+// its demanded bundle and agent 0 keeps the rest; the prices of that round
+// are returned.
+//
+// Ties between bundles go to the one with the smaller Bid flags, so
+// agents prefer fewer items. It panics if increment is not positive.
+func RunClockAuction(vs Valuations, startPrices []float64, increment float64) (a Allocation, prices []float64) {
+	if increment <= 0 {
+		panic("vcg: clock auction increment must be positive")
+	}
+	m := len(startPrices)
+	prices = make([]float64, m)
+	copy(prices, startPrices)
+
+	demand := make([]int64, len(vs))
+	demanders := make([]int, m)
+	for {
+		for item := range demanders {
+			demanders[item] = 0
+		}
+		for agent := 1; agent < len(vs); agent++ {
+			demand[agent] = demandedBundle(vs[agent], prices)
+			for item := 0; item < m; item++ {
+				if demand[agent]&(1<<uint(item)) != 0 {
+					demanders[item]++
+				}
+			}
+		}
+
+		over_demanded := false
+		for item := 0; item < m; item++ {
+			if demanders[item] > 1 {
+				prices[item] += increment
+				over_demanded = true
+			}
+		}
+		if !over_demanded {
+			break
+		}
+	}
+
+	a = newAllocation(len(vs) - 1)
+	unsold := allItems(m)
+	for agent := 1; agent < len(vs); agent++ {
+		a[agent] = flagsToItems(demand[agent])
+		unsold &^= demand[agent]
+	}
+	a[Unassigned] = flagsToItems(unsold)
+	return
+}
+
+// demandedBundle returns the bundle maximizing v minus its price.
+func demandedBundle(v Valuation, prices []float64) (demand int64) {
+	best := v.Value(0)
+	for bundle := int64(1); bundle <= allItems(len(prices)); bundle++ {
+		u := v.Value(bundle)
+		for item, price := range prices {
+			if bundle&(1<<uint(item)) != 0 {
+				u -= price
+			}
+		}
+		if u > best {
+			demand, best = bundle, u
+		}
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestClockAuctionConverges runs the clock auction on additive valuations:
+// agent 1 values items 0 and 1 at 5 and 2, agent 2 at 3 and 4. Both want
+// both items until the prices reach 3 and 2, the lower values, where each
+// agent demands exactly the item it values most: a competitive equilibrium,
+// with the optimal allocation.
+func TestClockAuctionConverges(t *testing.T) {
+	var calls int
+	vs := Valuations{nil, additive([]float64{5, 2}, &calls), additive([]float64{3, 4}, &calls)}
+	a, prices := RunClockAuction(vs, []float64{0, 0}, 0.5)
+	if got := a.owners(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("allocation %v, want [1 2]", got)
+	}
+	if want := []float64{3, 2}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("prices %v, want %v", prices, want)
+	}
+
+	// at the clearing prices, every agent demands what it receives
+	for agent := 1; agent < len(vs); agent++ {
+		if got := demandedBundle(vs[agent], prices); got != a.Flags(agent) {
+			t.Errorf("agent %d demands %b at the clearing prices, receives %b", agent, got, a.Flags(agent))
+		}
+	}
+	if opt := SolveAllocationValuations(vs, 2, 2); a.FindTotalUtilityValuations(vs) != opt.TotalUtility {
+		t.Errorf("clock auction reaches %v, the optimum is %v", a.FindTotalUtilityValuations(vs), opt.TotalUtility)
+	}
+}
+
+// TestClockAuctionUnsold checks an item nobody wants at its start price
+// stays with agent 0 at that price.
+func TestClockAuctionUnsold(t *testing.T) {
+	var calls int
+	vs := Valuations{nil, additive([]float64{5, 2}, &calls)}
+	a, prices := RunClockAuction(vs, []float64{1, 3}, 1)
+	if got := a.owners(); !reflect.DeepEqual(got, []int{1, 0}) {
+		t.Errorf("allocation %v, want [1 0]", got)
+	}
+	if want := []float64{1, 3}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("prices %v, want the start prices %v", prices, want)
+	}
+}
+
+func TestClockAuctionIncrement(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a zero increment")
+		}
+	}()
+	RunClockAuction(Valuations{nil, Bid{0x1: 1}}, []float64{0}, 0)
+}
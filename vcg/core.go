@@ -0,0 +1,254 @@
+package vcg
+
+import (
+	"math"
+	"sort"
+)
+
+// coreTolerance is how much a coalition may block the prices before a
+// constraint is added for it.
+const coreTolerance = 1e-6
+
+// CalculateCorePrices returns bidder-Pareto-optimal core prices for sol,
+// indexed by agent (index 0 is unused): among the prices at which no
+// coalition of agents could offer the seller more than the winners pay,
+// those of minimum revenue which are closest to the VCG prices.
+//
+// Core constraints are generated one blocking coalition at a time (Day and
+// Raghavan): the most blocking coalition is found by solving the auction
+// with the bids of every winner lowered by its surplus at the current
+// prices. It returns nil when sol cannot be priced.
+func CalculateCorePrices(bs BidSet, sol Solution, n, m int) []float64 {
+	if err := sol.CalculatePrices(bs, n, m); err != nil {
+		return nil
+	}
+	prices := make([]float64, n+1)
+	var winners []int
+	var values []float64
+	for agent := 1; agent < len(sol.Allocation) && agent <= n; agent++ {
+		if bundle := sol.Allocation.Flags(agent); bundle != 0 {
+			winners = append(winners, agent)
+			values = append(values, bs[agent].ValueOf(bundle))
+			prices[agent] = sol.PricePerAgent[agent]
+		}
+	}
+	if len(winners) == 0 {
+		return prices
+	}
+
+	// The constraints are on q, the prices above the VCG prices, as rows of
+	// a·q >= r. No agent pays more than its bid.
+	var a [][]float64
+	var r []float64
+	for i, agent := range winners {
+		row := make([]float64, len(winners))
+		row[i] = -1
+		a = append(a, row)
+		r = append(r, prices[agent]-values[i])
+	}
+	q := make([]float64, len(winners))
+	for {
+		reduced := make(BidSet, len(bs))
+		copy(reduced, bs)
+		revenue := 0.0
+		for i, agent := range winners {
+			price := prices[agent] + q[i]
+			revenue += price
+			surplus := values[i] - price
+			reduced[agent] = make(Bid)
+			for flags, utility := range bs[agent] {
+				if utility > surplus {
+					reduced[agent][flags] = utility - surplus
+				}
+			}
+		}
+		blocking := SolveAllocation(reduced, n, m)
+		if blocking.TotalUtility <= revenue+coreTolerance {
+			break
+		}
+
+		// The coalition could pay the seller its welfare, minus what its
+		// winners would give up, and the other winners' prices.
+		coalition := make(map[int]bool)
+		var welfare float64
+		for agent := 1; agent < len(blocking.Allocation); agent++ {
+			bundle := blocking.Allocation.Flags(agent)
+			if reduced[agent][bundle] > 0 {
+				coalition[agent] = true
+				welfare += bs[agent][bundle]
+			}
+		}
+		row := make([]float64, len(winners))
+		for i, agent := range winners {
+			if coalition[agent] {
+				welfare -= values[i]
+			} else {
+				row[i] = 1
+				welfare -= prices[agent]
+			}
+		}
+		if hasConstraint(a, r, row, welfare) {
+			break
+		}
+		a = append(a, row)
+		r = append(r, welfare)
+
+		min_revenue, ok := minimizeSum(a, r)
+		if !ok {
+			return nil
+		}
+		q = nearestPoint(a, r, min_revenue)
+	}
+	for i, agent := range winners {
+		prices[agent] += q[i]
+	}
+	return prices
+}
+
+// Coalition is a set of agents which, together with the seller, blocks the
+// prices of a solution: allocating the items among themselves, they reach a
+// higher Welfare than the seller's revenue and reservation value plus the
+// surplus its members enjoy at the prices. Surplus is the difference, what
+// the coalition could still gain by deviating.
+type Coalition struct {
+	Agents  []int
+	Welfare float64
+	Surplus float64
+}
+
+// CoreViolations returns every coalition blocking the VCG prices of sol, so
+// an empty result means the VCG outcome is in the core. It is a diagnostic:
+// with complementary bids, such as those of a global bidder against local
+// ones, VCG revenue can be too low for the seller to accept. The coalitions
+// come in decreasing order of surplus, agents in increasing order.
+//
+// Like ShapleyValues, it solves the auction for each of the 2^n coalitions,
+// so it is only meant for a handful of agents. It returns nil when sol
+// cannot be priced.
+func CoreViolations(bs BidSet, sol Solution, n, m int) (violations []Coalition) {
+	if err := sol.CalculatePrices(bs, n, m); err != nil {
+		return nil
+	}
+	seller_payoff := sol.Revenue() + sol.Allocation.SellerUtility(bs)
+	for coalition := 0; coalition < 1<<uint(n); coalition++ {
+		members := make(BidSet, len(bs))
+		if len(bs) > Unassigned {
+			members[Unassigned] = bs[Unassigned]
+		}
+		var agents []int
+		payoff := seller_payoff
+		for agent := 1; agent <= n; agent++ {
+			if coalition&(1<<uint(agent-1)) != 0 {
+				members[agent] = bs[agent]
+				agents = append(agents, agent)
+				payoff += bs[agent][sol.Allocation.Flags(agent)] - sol.PricePerAgent[agent]
+			} else {
+				members[agent] = make(Bid)
+			}
+		}
+		welfare := SolveAllocation(members, n, m).TotalUtility
+		if welfare > payoff+coreTolerance {
+			violations = append(violations, Coalition{agents, welfare, welfare - payoff})
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Surplus > violations[j].Surplus
+	})
+	return
+}
+
+// hasConstraint reports whether row·q >= rhs is already among the rows of
+// a·q >= r, in which case adding it again would not change the prices.
+func hasConstraint(a [][]float64, r []float64, row []float64, rhs float64) bool {
+	for i := range a {
+		if math.Abs(r[i]-rhs) > coreTolerance {
+			continue
+		}
+		same := true
+		for j := range row {
+			if a[i][j] != row[j] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// minimizeSum returns the smallest sum of q >= 0 subject to a·q >= r. It
+// solves the dual linear program, maximizing r·y subject to y·a <= 1 and
+// y >= 0. ok is false when the constraints are infeasible.
+func minimizeSum(a [][]float64, r []float64) (min float64, ok bool) {
+	k := len(a[0])
+	dual := make([][]float64, k)
+	ones := make([]float64, k)
+	for j := range dual {
+		dual[j] = make([]float64, len(a))
+		for i := range a {
+			dual[j][i] = a[i][j]
+		}
+		ones[j] = 1
+	}
+	return maximizeLP(dual, ones, r)
+}
+
+// nearestPoint returns the q closest to the origin subject to q >= 0,
+// a·q >= r and a sum of q equal to total, by Dykstra's alternating
+// projections onto each of these sets.
+func nearestPoint(a [][]float64, r []float64, total float64) (q []float64) {
+	k := len(a[0])
+	q = make([]float64, k)
+
+	// Set i < len(a) is row i, then q_j >= 0 for every j, then the sum.
+	sets := len(a) + k + 1
+	increments := make([][]float64, sets)
+	for i := range increments {
+		increments[i] = make([]float64, k)
+	}
+	y := make([]float64, k)
+	for cycle := 0; cycle < 100000; cycle++ {
+		change := 0.0
+		for i := 0; i < sets; i++ {
+			for j := range y {
+				y[j] = q[j] + increments[i][j]
+			}
+			switch {
+			case i < len(a):
+				dot, norm := 0.0, 0.0
+				for j := range y {
+					dot += a[i][j] * y[j]
+					norm += a[i][j] * a[i][j]
+				}
+				if dot < r[i] && norm > 0 {
+					for j := range y {
+						y[j] += (r[i] - dot) / norm * a[i][j]
+					}
+				}
+			case i < len(a)+k:
+				if y[i-len(a)] < 0 {
+					y[i-len(a)] = 0
+				}
+			default:
+				sum := 0.0
+				for j := range y {
+					sum += y[j]
+				}
+				for j := range y {
+					y[j] += (total - sum) / float64(k)
+				}
+			}
+			for j := range y {
+				increments[i][j] = q[j] + increments[i][j] - y[j]
+				change += math.Abs(y[j] - q[j])
+				q[j] = y[j]
+			}
+		}
+		if change < coreTolerance*coreTolerance {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package vcg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// llgBids returns the local-local-global instance: local agents 1 and 2
+// want items 0 and 1 for 8 and 4, global agent 3 wants both for 10.
+func llgBids() BidSet {
+	return BidSet{nil, Bid{0x1: 8}, Bid{0x2: 4}, Bid{0x3: 10}}
+}
+
+// TestCorePricesLLG prices the LLG instance, where the locals win. VCG
+// charges them 10 - 4 = 6 and 10 - 8 = 2, a revenue of 8, which the global
+// agent blocks by offering 10. The minimum-revenue core prices nearest to
+// VCG share the missing 2 equally: 7 and 3.
+func TestCorePricesLLG(t *testing.T) {
+	bs := llgBids()
+	s := SolveAllocation(bs, 3, 2)
+	if err := s.CalculatePrices(bs, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if s.PricePerAgent[1] != 6 || s.PricePerAgent[2] != 2 {
+		t.Fatalf("VCG prices %v, want 6 and 2", s.PricePerAgent)
+	}
+
+	prices := CalculateCorePrices(bs, s, 3, 2)
+	want := []float64{0, 7, 3, 0}
+	if len(prices) != len(want) {
+		t.Fatalf("core prices %v, want %v", prices, want)
+	}
+	for agent := range want {
+		if math.Abs(prices[agent]-want[agent]) > 1e-6 {
+			t.Errorf("core prices %v, want %v", prices, want)
+			break
+		}
+	}
+}
+
+// TestCoreViolations checks the global agent of LLG alone blocks the VCG
+// revenue of 8 by 2, while it and either local agent, or all three, cannot
+// do better than the payoffs they have. A single item sold at the second
+// price is in the core.
+func TestCoreViolations(t *testing.T) {
+	bs := llgBids()
+	got := CoreViolations(bs, SolveAllocation(bs, 3, 2), 3, 2)
+	if want := []Coalition{{[]int{3}, 10, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	bs = BidSet{nil, Bid{0x1: 5}, Bid{0x1: 3}}
+	if got := CoreViolations(bs, SolveAllocation(bs, 2, 1), 2, 1); len(got) != 0 {
+		t.Errorf("single item: got %v", got)
+	}
+}
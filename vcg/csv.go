@@ -0,0 +1,132 @@
+package vcg
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxCSVAgent is the highest agent number LoadBidSetCSV accepts, far more
+// agents than can be solved.
+const maxCSVAgent = 1 << 16
+
+// LoadBidSetCSV reads bids from a CSV table with one bundle bid per row:
+//
+//	agent,items,value
+//	1,0;1,5
+//	1,2,1
+//	2,1;2;3,7
+//
+// Agents are numbered from 1, items are a semicolon-separated list of item
+// indices (empty for the empty bundle). The header row is optional. Agents
+// need not be numbered consecutively: the number of agents n is the highest
+// agent, those without a row bidding nothing, and the number of items m is
+// one more than the highest item. No agent may be numbered above
+// maxCSVAgent, so a stray agent number cannot make the bid set huge.
+func LoadBidSetCSV(r io.Reader) (bs BidSet, n, m int, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	first_line := 1
+	if len(rows) > 0 && strings.EqualFold(rows[0][0], "agent") {
+		rows = rows[1:]
+		first_line++
+	}
+
+	bids := make(map[int]Bid)
+	for i, row := range rows {
+		line := first_line + i
+		agent, err := strconv.Atoi(row[0])
+		if err != nil || agent < 1 || agent > maxCSVAgent {
+			return nil, 0, 0, fmt.Errorf("line %d: agent must be an integer in 1..%d, got %q", line, maxCSVAgent, row[0])
+		}
+		var flags int64
+		if row[1] != "" {
+			for _, field := range strings.Split(row[1], ";") {
+				item, err := strconv.Atoi(strings.TrimSpace(field))
+				if err != nil || item < 0 || item >= MaxFlagItems {
+					return nil, 0, 0, fmt.Errorf("line %d: item %q is not an index in 0..%d", line, field, MaxFlagItems-1)
+				}
+				flags = flags | 1<<uint(item)
+				if item >= m {
+					m = item + 1
+				}
+			}
+		}
+		value, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("line %d: value %q is not a number", line, row[2])
+		}
+
+		if bids[agent] == nil {
+			bids[agent] = make(Bid)
+		}
+		if _, ok := bids[agent][flags]; ok {
+			return nil, 0, 0, fmt.Errorf("line %d: agent %d bids on bundle %q twice", line, agent, row[1])
+		}
+		bids[agent][flags] = value
+		if agent > n {
+			n = agent
+		}
+	}
+
+	bs = make(BidSet, n+1)
+	bs[Unassigned] = make(Bid)
+	for agent := 1; agent <= n; agent++ {
+		bs[agent] = bids[agent]
+		if bs[agent] == nil {
+			bs[agent] = make(Bid)
+		}
+	}
+	return
+}
+
+// LoadReservePrices reads the reserve prices of m items from a CSV table
+// with one item per row:
+//
+//	item,reserve
+//	0,2.5
+//	3,1
+//
+// Items are numbered from 0 and may be listed in any order, at most once.
+// Items which are not listed have a reserve of 0. The header row is
+// optional. The result is meant for Options.ReservePrices.
+func LoadReservePrices(r io.Reader, m int) (reserves []float64, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	first_line := 1
+	if len(rows) > 0 && strings.EqualFold(rows[0][0], "item") {
+		rows = rows[1:]
+		first_line++
+	}
+
+	reserves = make([]float64, m)
+	listed := make([]bool, m)
+	for i, row := range rows {
+		line := first_line + i
+		item, err := strconv.Atoi(row[0])
+		if err != nil || item < 0 || item >= m {
+			return nil, fmt.Errorf("line %d: item %q is not an index in 0..%d", line, row[0], m-1)
+		}
+		if listed[item] {
+			return nil, fmt.Errorf("line %d: item %d is listed twice", line, item)
+		}
+		reserve, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: reserve %q is not a number", line, row[1])
+		}
+		reserves[item], listed[item] = reserve, true
+	}
+	return
+}
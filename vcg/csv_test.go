@@ -0,0 +1,74 @@
+package vcg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadBidSetCSV(t *testing.T) {
+	bs, n, m, err := LoadBidSetCSV(strings.NewReader("agent,items,value\n1,0;1,5\n1,2,1\n3, 1; 2 ;3,7\n3,,0.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || m != 4 {
+		t.Errorf("n = %d, m = %d, want 3 and 4", n, m)
+	}
+	want := BidSet{{}, {0x3: 5, 0x4: 1}, {}, {0xe: 7, 0x0: 0.5}}
+	if !reflect.DeepEqual(bs, want) {
+		t.Errorf("got %v, want %v", bs, want)
+	}
+}
+
+// TestLoadBidSetCSVSparseAgents reads a single row for agent 5: agents 1..4
+// are there too, bidding nothing.
+func TestLoadBidSetCSVSparseAgents(t *testing.T) {
+	bs, n, m, err := LoadBidSetCSV(strings.NewReader("5,0;1,2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 || m != 2 {
+		t.Errorf("n = %d, m = %d, want 5 and 2", n, m)
+	}
+	want := BidSet{{}, {}, {}, {}, {}, {0x3: 2}}
+	if !reflect.DeepEqual(bs, want) {
+		t.Errorf("got %v, want %v", bs, want)
+	}
+}
+
+func TestLoadBidSetCSVErrors(t *testing.T) {
+	tests := []struct {
+		input, err string
+	}{
+		{"1,0,1\n1,0\n", "wrong number of fields"},
+		{"agent,items,value\nx,0,1\n", "line 2: agent must be an integer in 1..65536"},
+		{"0,0,1\n", "line 1: agent must be an integer in 1..65536"},
+		{"1,0,1\n1000000000,1,2\n", "line 2: agent must be an integer in 1..65536"},
+		{"1,0;x,1\n", `line 1: item "x" is not an index`},
+		{"1,-1,1\n", `line 1: item "-1" is not an index`},
+		{"1,63,1\n", `line 1: item "63" is not an index`},
+		{"1,0,abc\n", `line 1: value "abc" is not a number`},
+		{"1,0;1,1\n2,0,1\n1,1;0,2\n", "line 3: agent 1 bids on bundle \"1;0\" twice"},
+	}
+	for _, tt := range tests {
+		_, _, _, err := LoadBidSetCSV(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got error %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestLoadReservePrices(t *testing.T) {
+	reserves, err := LoadReservePrices(strings.NewReader("item,reserve\n2,2.5\n0,1\n"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{1, 0, 2.5, 0}; !reflect.DeepEqual(reserves, want) {
+		t.Errorf("got %v, want %v", reserves, want)
+	}
+	for _, input := range []string{"4,1\n", "0,1\n0,2\n", "0,x\n"} {
+		if _, err := LoadReservePrices(strings.NewReader(input), 4); err == nil {
+			t.Errorf("%q: no error", input)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package vcg implements winner determination and Vickrey–Clarke–Groves
+// pricing for combinatorial auctions with n agents and m items.
+//
+// Agents are numbered 1..n; agent 0 (Unassigned) is "nobody" and holds the
+// items that are not sold. Allocations always include it, and a BidSet has
+// an entry for it holding the seller's reservation values for bundles, see
+// Allocation.SellerUtility. Items are numbered 0..m-1.
+package vcg
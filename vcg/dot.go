@@ -0,0 +1,71 @@
+package vcg
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// ToDOT renders the allocation as a Graphviz graph, agents on the left and
+// items on the right, with an edge from every agent to each item it won
+// and dashed edges from "unsold" to the unsold items. Agents are labeled
+// with their price once prices are calculated.
+//
+// Names are indexed as returned by NamedBidSet.Compile: agentNames[i] is
+// agent i+1 and itemNames[j] is item j. Missing names default to "agent1",
+// "item0" and so on, as in Allocation.String.
+func (s Solution) ToDOT(agentNames, itemNames []string) string {
+	agent_label := func(agent int) string {
+		if agent-1 < len(agentNames) {
+			return agentNames[agent-1]
+		}
+		return fmt.Sprintf("agent%d", agent)
+	}
+	item_label := func(item int) string {
+		if item < len(itemNames) {
+			return itemNames[item]
+		}
+		return fmt.Sprintf("item%d", item)
+	}
+	// the named items and those held, however high
+	items := make([]int, len(itemNames))
+	for item := range items {
+		items[item] = item
+	}
+	for item := range s.Allocation.holders() {
+		if item >= len(itemNames) {
+			items = append(items, item)
+		}
+	}
+	sort.Ints(items)
+
+	var b bytes.Buffer
+	b.WriteString("graph allocation {\n\trankdir=LR;\n")
+	b.WriteString("\tsubgraph agents {\n\t\trank=same;\n")
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		label := agent_label(agent)
+		if price, ok := s.PricePerAgent[agent]; ok {
+			label += fmt.Sprintf("\nprice %g", price)
+		}
+		fmt.Fprintf(&b, "\t\tagent%d [shape=box, label=%q];\n", agent, label)
+	}
+	unsold := s.Allocation.items(Unassigned)
+	if len(unsold) > 0 {
+		b.WriteString("\t\tunsold [shape=box, style=dashed];\n")
+	}
+	b.WriteString("\t}\n\tsubgraph items {\n\t\trank=same;\n")
+	for _, item := range items {
+		fmt.Fprintf(&b, "\t\titem%d [label=%q];\n", item, item_label(item))
+	}
+	b.WriteString("\t}\n")
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		for _, item := range s.Allocation.items(agent) {
+			fmt.Fprintf(&b, "\tagent%d -- item%d;\n", agent, item)
+		}
+	}
+	for _, item := range unsold {
+		fmt.Fprintf(&b, "\tunsold -- item%d [style=dashed];\n", item)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package vcg
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestToDOT renders the priced solution of problem1 with some names and
+// checks the nodes, their labels and the edges of the allocation.
+func TestToDOT(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if err := s.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	dot := s.ToDOT([]string{"alice", "bob"}, []string{"apple"})
+	if !strings.HasPrefix(dot, "graph allocation {\n") {
+		t.Errorf("got\n%s\nwant a graph", dot)
+	}
+	for _, line := range []string{
+		`agent1 [shape=box, label="alice\nprice 3"];`,
+		`agent2 [shape=box, label="bob\nprice 4"];`,
+		`agent3 [shape=box, label="agent3\nprice 2"];`,
+		`agent4 [shape=box, label="agent4\nprice 0"];`,
+		`item0 [label="apple"];`,
+		`item3 [label="item3"];`,
+		"agent1 -- item3;",
+		"agent2 -- item0;",
+		"agent2 -- item1;",
+		"agent3 -- item2;",
+	} {
+		if !strings.Contains(dot, "\t"+line+"\n") {
+			t.Errorf("got\n%s\nwant %q", dot, line)
+		}
+	}
+	if strings.Contains(dot, "unsold") || strings.Count(dot, " -- ") != 4 {
+		t.Errorf("got\n%s\nwant exactly the 4 edges of the allocation", dot)
+	}
+
+	// unsold items hang off a dashed node
+	s = SolveAllocationWithOptions(BidSet{Bid{}, Bid{0x1: 1}}, 1, 2, Options{})
+	dot = s.ToDOT(nil, nil)
+	for _, line := range []string{
+		"unsold [shape=box, style=dashed];",
+		"agent1 -- item0;",
+		"unsold -- item1 [style=dashed];",
+	} {
+		if !strings.Contains(dot, "\t"+line+"\n") {
+			t.Errorf("got\n%s\nwant %q", dot, line)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package vcg
+
+// Candidate is an allocation considered by the search, with its total
+// utility.
+type Candidate struct {
+	Allocation
+	Utility float64
+}
+
+// EnumerateAllocations sends every allocation of m items to agents 0..n,
+// (n+1)^m in total, with its total utility on the returned channel, in the
+// order of the sequential search, and closes it afterwards. Each allocation
+// is a fresh copy. The caller must receive until the channel is closed.
+// It is meant for inspecting small instances.
+func EnumerateAllocations(bs BidSet, n, m int) <-chan Candidate {
+	candidates := make(chan Candidate)
+	go func() {
+		enumerateAllocations(bs, newAllocation(n), 0, m, candidates)
+		close(candidates)
+	}()
+	return candidates
+}
+
+func enumerateAllocations(bs BidSet, a Allocation, current_item, m int, candidates chan<- Candidate) {
+	if current_item == m {
+		candidates <- Candidate{a.Copy(), a.FindTotalUtility(bs)}
+		return
+	}
+	for agent := 0; agent < len(a); agent++ {
+		a[agent][current_item] = true
+		enumerateAllocations(bs, a, current_item+1, m, candidates)
+		delete(a[agent], current_item)
+	}
+}
@@ -0,0 +1,37 @@
+package vcg
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestEnumerateAllocations counts the 3^4 allocations of 4 items to agents
+// 0..2, all distinct and feasible, the best of which is the optimum.
+func TestEnumerateAllocations(t *testing.T) {
+	const n, m = 2, 4
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: 1})
+	seen := make(map[string]bool)
+	best := Candidate{Utility: -1}
+	for c := range EnumerateAllocations(bs, n, m) {
+		if !feasible(c.Allocation, m) {
+			t.Errorf("infeasible allocation %v", c.Allocation)
+		}
+		key := fmt.Sprint(c.owners())
+		if seen[key] {
+			t.Errorf("%v sent twice", c.Allocation)
+		}
+		seen[key] = true
+		if u := c.FindTotalUtility(bs); u != c.Utility {
+			t.Errorf("%v worth %v, sent with %v", c.Allocation, u, c.Utility)
+		}
+		if c.Utility > best.Utility {
+			best = c
+		}
+	}
+	if len(seen) != 81 {
+		t.Errorf("%d allocations, want 81", len(seen))
+	}
+	if s := SolveAllocation(bs, n, m); best.Utility != s.TotalUtility {
+		t.Errorf("best allocation worth %v, the optimum is %v", best.Utility, s.TotalUtility)
+	}
+}
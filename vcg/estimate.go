@@ -0,0 +1,58 @@
+package vcg
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// calibrationAgents and calibrationItems are the size of the instance
+// solved to measure the cost of one allocation.
+const (
+	calibrationAgents = 3
+	calibrationItems  = 7
+)
+
+var (
+	calibrateOnce  sync.Once
+	allocationCost float64 // nanoseconds per allocation of the calibration instance
+)
+
+// EstimateComplexity returns the number of allocations the exhaustive
+// search enumerates for n agents and m items, (n+1)^m (at most
+// math.MaxInt64), and roughly how long SolveAllocation takes to do so on
+// this machine. The time per allocation is measured by solving a small
+// instance the first time EstimateComplexity is called, and scaled with
+// n+m, since evaluating an allocation visits every agent and item.
+// Pruning and pricing are not accounted for; pricing repeats the search
+// once per agent.
+func EstimateComplexity(n, m int) (nodes int64, approxDuration time.Duration) {
+	calibrateOnce.Do(calibrate)
+
+	nodes = allocations(int64(n+1), m)
+	ns := float64(nodes) * allocationCost * float64(n+m) / (calibrationAgents + calibrationItems)
+	if (Options{}).parallel(n, m) {
+		ns /= float64(runtime.GOMAXPROCS(0))
+	}
+	if ns >= math.MaxInt64 {
+		return nodes, time.Duration(math.MaxInt64)
+	}
+	return nodes, time.Duration(ns)
+}
+
+// calibrate measures allocationCost with a sequential search, which does
+// not depend on the number of CPUs.
+func calibrate() {
+	bs := make(BidSet, calibrationAgents+1)
+	for agent := 1; agent <= calibrationAgents; agent++ {
+		bs[agent] = make(Bid)
+		for flags := int64(1); flags <= allItems(calibrationItems); flags++ {
+			bs[agent][flags] = float64(flags % int64(agent+4))
+		}
+	}
+	start := time.Now()
+	SolveAllocationSequential(bs, calibrationAgents, calibrationItems)
+	nodes := allocations(calibrationAgents+1, calibrationItems)
+	allocationCost = float64(time.Since(start).Nanoseconds()) / float64(nodes)
+}
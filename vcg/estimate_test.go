@@ -0,0 +1,38 @@
+package vcg
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestEstimateComplexity checks the number of allocations is (n+1)^m and
+// the duration grows with n and m. All sizes but the first are large enough
+// to be searched in parallel, so they are scaled alike.
+func TestEstimateComplexity(t *testing.T) {
+	if nodes, _ := EstimateComplexity(1, 3); nodes != 8 {
+		t.Errorf("1 agent, 3 items: %d allocations, want 8", nodes)
+	}
+	nodes, d := EstimateComplexity(3, 6)
+	if nodes != 4096 || d <= 0 {
+		t.Fatalf("3 agents, 6 items: %d allocations in %s, want 4096", nodes, d)
+	}
+	for m := 7; m <= 10; m++ {
+		more_nodes, more_d := EstimateComplexity(3, m)
+		if more_nodes != 4*nodes || more_d <= 3*d {
+			t.Errorf("3 agents, %d items: %d allocations in %s, after %d in %s", m, more_nodes, more_d, nodes, d)
+		}
+		nodes, d = more_nodes, more_d
+	}
+	_, d = EstimateComplexity(3, 6)
+	for n := 4; n <= 6; n++ {
+		_, more_d := EstimateComplexity(n, 6)
+		if more_d <= d {
+			t.Errorf("%d agents: %s, not more than %s with one agent less", n, more_d, d)
+		}
+		d = more_d
+	}
+	if nodes, d := EstimateComplexity(20, 63); nodes != math.MaxInt64 || d != time.Duration(math.MaxInt64) {
+		t.Errorf("20 agents, 63 items: %d allocations in %s, want the maximum", nodes, d)
+	}
+}
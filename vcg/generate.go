@@ -0,0 +1,255 @@
+package vcg
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Distribution is the model GenerateBidSet draws bundle values from.
+type Distribution int
+
+const (
+	// Uniform values a bundle of k items uniformly in [0, k), so values
+	// grow with the size of the bundle on average.
+	Uniform Distribution = iota
+
+	// Normal values a bundle of k items normally with mean k/2 and
+	// standard deviation k/4, cut off at 0.
+	Normal
+
+	// Superadditive draws a value in [0, 1) for every item and values a
+	// bundle of k items at their sum times 1+(k-1)/2, so a bundle is worth
+	// at least as much as any partition of it.
+	Superadditive
+
+	// Subadditive draws a value in [0, 1) for every item and values a
+	// bundle of k items at their sum divided by √k, so a bundle is worth
+	// at most as much as any partition of it.
+	Subadditive
+
+	// Complements draws a value in [0, 1) for every item and a synergy in
+	// [0, 1) for every pair of items, and values a bundle at the sum of its
+	// items plus the synergies of all pairs within it. Two disjoint
+	// bundles together are worth more than apart by the synergies of the
+	// pairs across them.
+	Complements
+
+	// Substitutes draws a value in [0, 1) for every item and values a
+	// bundle at its most valuable item: the agent needs just one item, so
+	// two disjoint bundles together are worth less than apart.
+	Substitutes
+)
+
+// GenOptions configure GenerateBidSet.
+type GenOptions struct {
+	Agents int // n
+	Items  int // m, at most MaxFlagItems but for GenerateWideBidSet
+
+	Distribution Distribution
+
+	// Sparsity is the fraction of the non-empty bundles each agent bids
+	// on, each bundle being picked independently. 0 means all of them.
+	Sparsity float64
+
+	// BundlesPerAgent, if positive, is the number of distinct non-empty
+	// bundles each agent bids on, or all of them if there are fewer.
+	// The bundles are drawn directly instead of enumerating all 2^m, so
+	// this works for any number of items. It takes precedence over
+	// Sparsity.
+	BundlesPerAgent int
+
+	// MaxBundleSize, if positive, limits the bundles drawn for
+	// BundlesPerAgent to that many items, of sizes drawn uniformly, so
+	// that few items are bid on in total.
+	MaxBundleSize int
+
+	// Seed seeds the random numbers, so the same options always generate
+	// the same bids.
+	Seed int64
+}
+
+// GenerateBidSet returns random bids of opts.Agents agents on bundles of
+// opts.Items items, for experimenting with the solvers. Unless
+// opts.BundlesPerAgent is set, it enumerates all 2^m bundles of every
+// agent. It panics if the options are out of range.
+func GenerateBidSet(opts GenOptions) (bs BidSet) {
+	if opts.Agents < 0 || opts.Items < 0 || opts.Items > MaxFlagItems {
+		panic(fmt.Sprintf("vcg: cannot generate bids of %d agents on %d items", opts.Agents, opts.Items))
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	bs = make(BidSet, opts.Agents+1)
+	bs[Unassigned] = make(Bid)
+	for agent := 1; agent <= opts.Agents; agent++ {
+		bs[agent] = make(Bid)
+		item_values := make([]float64, opts.Items)
+		for item := range item_values {
+			item_values[item] = r.Float64()
+		}
+		var synergies [][]float64
+		if opts.Distribution == Complements {
+			synergies = make([][]float64, opts.Items)
+			for item := range synergies {
+				synergies[item] = make([]float64, item)
+				for other := range synergies[item] {
+					synergies[item][other] = r.Float64()
+				}
+			}
+		}
+		if opts.BundlesPerAgent > 0 {
+			bundles := int64(opts.BundlesPerAgent)
+			if bundles > allItems(opts.Items) {
+				bundles = allItems(opts.Items)
+			}
+			if opts.MaxBundleSize > 0 {
+				bundles = int64(countBundles(opts.Items, opts.MaxBundleSize, opts.BundlesPerAgent))
+			}
+			for int64(len(bs[agent])) < bundles {
+				var flags int64
+				if opts.MaxBundleSize > 0 {
+					for _, item := range drawItems(r, opts.Items, opts.MaxBundleSize) {
+						flags |= 1 << uint(item)
+					}
+				} else {
+					flags = r.Int63n(allItems(opts.Items)) + 1
+				}
+				if _, ok := bs[agent][flags]; !ok {
+					bs[agent][flags] = opts.Distribution.value(r, flagsHas(flags), item_values, synergies)
+				}
+			}
+			continue
+		}
+		for flags := int64(1); flags <= allItems(opts.Items); flags++ {
+			if opts.Sparsity > 0 && r.Float64() >= opts.Sparsity {
+				continue
+			}
+			bs[agent][flags] = opts.Distribution.value(r, flagsHas(flags), item_values, synergies)
+		}
+	}
+	return
+}
+
+// GenerateWideBidSet is GenerateBidSet for any number of items. Above
+// MaxFlagItems items, opts.BundlesPerAgent must be set, since there are too
+// many bundles to enumerate, and opts.MaxBundleSize too, for
+// SolveAllocationWide to search few enough items.
+func GenerateWideBidSet(opts GenOptions) (bs WideBidSet) {
+	if opts.Items <= MaxFlagItems {
+		return GenerateBidSet(opts).Wide()
+	}
+	if opts.Agents < 0 || opts.BundlesPerAgent <= 0 {
+		panic(fmt.Sprintf("vcg: cannot generate bids of %d agents on all bundles of %d items", opts.Agents, opts.Items))
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	bs = make(WideBidSet, opts.Agents+1)
+	bs[Unassigned] = make(WideBid)
+	for agent := 1; agent <= opts.Agents; agent++ {
+		bs[agent] = make(WideBid)
+		item_values := make([]float64, opts.Items)
+		for item := range item_values {
+			item_values[item] = r.Float64()
+		}
+		var synergies [][]float64
+		if opts.Distribution == Complements {
+			synergies = make([][]float64, opts.Items)
+			for item := range synergies {
+				synergies[item] = make([]float64, item)
+				for other := range synergies[item] {
+					synergies[item][other] = r.Float64()
+				}
+			}
+		}
+		bundles := countBundles(opts.Items, opts.MaxBundleSize, opts.BundlesPerAgent)
+		for len(bs[agent]) < bundles {
+			var b Bundle
+			if opts.MaxBundleSize > 0 {
+				b = NewBundle(drawItems(r, opts.Items, opts.MaxBundleSize)...)
+			} else {
+				for item := 0; item < opts.Items; item++ {
+					if r.Intn(2) == 1 {
+						b = b.Add(item)
+					}
+				}
+			}
+			if _, ok := bs[agent][b.Key()]; !ok && len(b.Items()) > 0 {
+				bs[agent].Set(b, opts.Distribution.value(r, b.Has, item_values, synergies))
+			}
+		}
+	}
+	return
+}
+
+// countBundles returns the number of non-empty bundles of at most max_size
+// of m items (of any size if max_size is 0), or limit if there are more.
+func countBundles(m, max_size, limit int) (count int) {
+	if max_size <= 0 || max_size > m {
+		max_size = m
+	}
+	// choose is m choose size
+	choose := 1
+	for size := 1; size <= max_size && count < limit; size++ {
+		if choose = choose * (m - size + 1) / size; choose >= limit {
+			return limit
+		}
+		count += choose
+	}
+	if count > limit {
+		count = limit
+	}
+	return
+}
+
+// drawItems returns a uniformly drawn size of 1..max_size, or 1..m if fewer,
+// of distinct items of 0..m-1.
+func drawItems(r *rand.Rand, m, max_size int) []int {
+	if max_size > m {
+		max_size = m
+	}
+	return r.Perm(m)[:1+r.Intn(max_size)]
+}
+
+// flagsHas returns whether the bundle of Bid flags holds an item.
+func flagsHas(flags int64) func(item int) bool {
+	return func(item int) bool {
+		return flags&(1<<uint(item)) != 0
+	}
+}
+
+// value draws the value of the bundle holding the items has reports, given
+// the values of single items and, for Complements, the synergy of items
+// i > j as synergies[i][j].
+func (d Distribution) value(r *rand.Rand, has func(item int) bool, item_values []float64, synergies [][]float64) (u float64) {
+	size := 0
+	highest := 0.0
+	for item, value := range item_values {
+		if !has(item) {
+			continue
+		}
+		size++
+		u += value
+		if value > highest {
+			highest = value
+		}
+		if synergies != nil {
+			for other := range synergies[item] {
+				if has(other) {
+					u += synergies[item][other]
+				}
+			}
+		}
+	}
+	switch d {
+	case Normal:
+		return math.Max(0, float64(size)/2+r.NormFloat64()*float64(size)/4)
+	case Superadditive:
+		return u * (1 + float64(size-1)/2)
+	case Subadditive:
+		return u / math.Sqrt(float64(size))
+	case Complements:
+		return u
+	case Substitutes:
+		return highest
+	default:
+		return float64(size) * r.Float64()
+	}
+}
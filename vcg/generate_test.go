@@ -0,0 +1,166 @@
+package vcg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestGenerateBidSetSeed checks the same options generate identical bids,
+// and another seed different ones.
+func TestGenerateBidSetSeed(t *testing.T) {
+	for _, opts := range []GenOptions{
+		{Agents: 3, Items: 4, Seed: 7},
+		{Agents: 2, Items: 5, Distribution: Superadditive, Sparsity: 0.5, Seed: 7},
+		{Agents: 4, Items: 30, BundlesPerAgent: 5, Seed: 7},
+	} {
+		a, b := GenerateBidSet(opts), GenerateBidSet(opts)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%+v: bids differ between calls", opts)
+		}
+		opts.Seed++
+		if c := GenerateBidSet(opts); reflect.DeepEqual(a, c) {
+			t.Errorf("%+v: seeds %d and %d generate the same bids", opts, opts.Seed-1, opts.Seed)
+		}
+	}
+}
+
+func TestGenerateBidSetSize(t *testing.T) {
+	bs := GenerateBidSet(GenOptions{Agents: 3, Items: 4, Seed: 1})
+	if err := bs.Validate(3, 4); err != nil {
+		t.Fatal(err)
+	}
+	for agent := 1; agent <= 3; agent++ {
+		if len(bs[agent]) != 15 {
+			t.Errorf("agent %d bids on %d bundles, want all 15", agent, len(bs[agent]))
+		}
+	}
+}
+
+// disjointPairs calls f for every pair of disjoint non-empty bundles of m
+// items.
+func disjointPairs(m int, f func(a, b int64)) {
+	for a := int64(1); a <= allItems(m); a++ {
+		for b := int64(1); b <= allItems(m); b++ {
+			if a&b == 0 {
+				f(a, b)
+			}
+		}
+	}
+}
+
+func TestGenerateBidSetSparsity(t *testing.T) {
+	const m = 10
+	bs := GenerateBidSet(GenOptions{Agents: 4, Items: m, Sparsity: 0.3, Seed: 1})
+	for agent := 1; agent <= 4; agent++ {
+		fraction := float64(len(bs[agent])) / float64(allItems(m))
+		if fraction < 0.25 || fraction > 0.35 {
+			t.Errorf("agent %d bids on %.2f of the bundles, want about 0.3", agent, fraction)
+		}
+	}
+}
+
+func TestGenerateBidSetDistributions(t *testing.T) {
+	const m = 6
+	gen := func(d Distribution) Bid {
+		return GenerateBidSet(GenOptions{Agents: 1, Items: m, Distribution: d, Seed: 1})[1]
+	}
+
+	for flags, u := range gen(Uniform) {
+		if size := float64(bundleSize(flags)); u < 0 || u >= size {
+			t.Errorf("Uniform: bundle %b of %v items worth %v", flags, size, u)
+		}
+	}
+
+	var sum, count float64
+	for flags, u := range gen(Normal) {
+		if u < 0 {
+			t.Errorf("Normal: bundle %b worth %v", flags, u)
+		}
+		sum += u - float64(bundleSize(flags))/2
+		count++
+	}
+	if mean := sum / count; math.Abs(mean) > 0.25 {
+		t.Errorf("Normal: bundles worth %v more than half their size on average", mean)
+	}
+
+	super, sub := gen(Superadditive), gen(Subadditive)
+	disjointPairs(m, func(a, b int64) {
+		if super[a|b] < super[a]+super[b] {
+			t.Errorf("Superadditive: %b worth %v, less than %b and %b worth %v and %v", a|b, super[a|b], a, b, super[a], super[b])
+		}
+		if sub[a|b] > sub[a]+sub[b] {
+			t.Errorf("Subadditive: %b worth %v, more than %b and %b worth %v and %v", a|b, sub[a|b], a, b, sub[a], sub[b])
+		}
+	})
+}
+
+// TestGenerateBidSetBundlesPerAgent draws k bundles of many items, or all
+// bundles when there are fewer than k.
+func TestGenerateBidSetBundlesPerAgent(t *testing.T) {
+	bs := GenerateBidSet(GenOptions{Agents: 3, Items: 50, BundlesPerAgent: 7, Seed: 1})
+	if err := bs.Validate(3, 50); err != nil {
+		t.Fatal(err)
+	}
+	for agent := 1; agent <= 3; agent++ {
+		if len(bs[agent]) != 7 {
+			t.Errorf("agent %d bids on %d bundles, want 7", agent, len(bs[agent]))
+		}
+		for flags, u := range bs[agent] {
+			if flags == 0 || u == 0 {
+				t.Errorf("agent %d bids %v on bundle %b", agent, u, flags)
+			}
+		}
+	}
+
+	bs = GenerateBidSet(GenOptions{Agents: 1, Items: 2, BundlesPerAgent: 7, Seed: 1})
+	if len(bs[1]) != 3 {
+		t.Errorf("bids on %d bundles of 2 items, want all 3", len(bs[1]))
+	}
+}
+
+// TestGenerateWideBidSet generates bids on small bundles of 120 items, few
+// enough to be solved.
+func TestGenerateWideBidSet(t *testing.T) {
+	opts := GenOptions{Agents: 3, Items: 120, BundlesPerAgent: 4, MaxBundleSize: 3, Seed: 1}
+	bs := GenerateWideBidSet(opts)
+	if err := bs.Validate(3, 120); err != nil {
+		t.Fatal(err)
+	}
+	for agent := 1; agent <= 3; agent++ {
+		if len(bs[agent]) != 4 {
+			t.Errorf("agent %d bids on %d bundles, want 4", agent, len(bs[agent]))
+		}
+		for key, u := range bs[agent] {
+			if items := bundleOfKey(key).Items(); len(items) == 0 || len(items) > 3 || u == 0 {
+				t.Errorf("agent %d bids %v on bundle %v", agent, u, items)
+			}
+		}
+	}
+	if !reflect.DeepEqual(GenerateWideBidSet(opts), bs) {
+		t.Error("the same seed generated different bids")
+	}
+	if got, want := GenerateWideBidSet(GenOptions{Agents: 2, Items: 4, Seed: 1}), GenerateBidSet(GenOptions{Agents: 2, Items: 4, Seed: 1}).Wide(); !reflect.DeepEqual(got, want) {
+		t.Error("wide bids on 4 items differ from GenerateBidSet")
+	}
+}
+
+// TestGenerateBidSetComplementsSubstitutes checks two disjoint bundles are
+// worth more together than apart with Complements, and less with
+// Substitutes.
+func TestGenerateBidSetComplementsSubstitutes(t *testing.T) {
+	const m = 5
+	complements := GenerateBidSet(GenOptions{Agents: 1, Items: m, Distribution: Complements, Seed: 1})[1]
+	substitutes := GenerateBidSet(GenOptions{Agents: 1, Items: m, Distribution: Substitutes, Seed: 1})[1]
+	disjointPairs(m, func(a, b int64) {
+		if complements[a|b] <= complements[a]+complements[b] {
+			t.Errorf("Complements: %b worth %v, not more than %b and %b worth %v and %v", a|b, complements[a|b], a, b, complements[a], complements[b])
+		}
+		if substitutes[a|b] >= substitutes[a]+substitutes[b] {
+			t.Errorf("Substitutes: %b worth %v, not less than %b and %b worth %v and %v", a|b, substitutes[a|b], a, b, substitutes[a], substitutes[b])
+		}
+		if substitutes[a|b] != math.Max(substitutes[a], substitutes[b]) {
+			t.Errorf("Substitutes: %b worth %v, not its most valuable part", a|b, substitutes[a|b])
+		}
+	})
+}
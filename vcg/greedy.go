@@ -0,0 +1,53 @@
+package vcg
+
+import (
+	"math"
+	"sort"
+)
+
+// greedyBid is a bundle bid ranked by the greedy solver.
+type greedyBid struct {
+	agent   int
+	bundle  int64
+	density float64
+}
+
+// SolveAllocationGreedy approximates SolveAllocation for instances too large
+// to search. It ranks all bundle bids by value/√|bundle| and accepts them in
+// that order whenever the agent has no bundle yet and none of the items is
+// taken. The result is never marked Optimal.
+func SolveAllocationGreedy(bs BidSet, n, m int) (s Solution) {
+	var bids []greedyBid
+	for agent := 1; agent <= n; agent++ {
+		for bundle, utility := range bs[agent] {
+			if bundle != 0 && bundle&^allItems(m) == 0 && utility > 0 {
+				size := len(flagsToItems(bundle))
+				bids = append(bids, greedyBid{agent, bundle, utility / math.Sqrt(float64(size))})
+			}
+		}
+	}
+	sort.Slice(bids, func(i, j int) bool {
+		if bids[i].density != bids[j].density {
+			return bids[i].density > bids[j].density
+		}
+		if bids[i].agent != bids[j].agent {
+			return bids[i].agent < bids[j].agent
+		}
+		return bids[i].bundle < bids[j].bundle
+	})
+
+	s.Allocation = make(Allocation)
+	for agent := 1; agent <= n; agent++ {
+		s.Allocation[agent] = make(map[int]bool)
+	}
+	taken := int64(0)
+	for _, bid := range bids {
+		if len(s.Allocation[bid.agent]) == 0 && bid.bundle&taken == 0 {
+			s.Allocation[bid.agent] = flagsToItems(bid.bundle)
+			taken = taken | bid.bundle
+		}
+	}
+	s.Allocation[Unassigned] = flagsToItems(allItems(m) &^ taken)
+	s.TotalUtility = Options{}.utility(s.Allocation, bs)
+	return
+}
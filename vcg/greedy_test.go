@@ -0,0 +1,65 @@
+package vcg
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestGreedyRatio compares the greedy utility to the optimum on small
+// random instances. Ranking by value/√|bundle| guarantees at least 1/√m of
+// the optimum; the worst ratio measured is logged.
+func TestGreedyRatio(t *testing.T) {
+	worst, total := 1.0, 0.0
+	const instances = 100
+	for seed := int64(0); seed < instances; seed++ {
+		n, m := 2+int(seed%3), 2+int(seed%5)
+		bs := randomBidSet(n, m, seed)
+		for bundle := range bs[1] {
+			if bundle%3 == 0 {
+				delete(bs[1], bundle)
+			}
+		}
+		opt := SolveAllocation(bs, n, m)
+		s := SolveAllocationGreedy(bs, n, m)
+		if s.Optimal {
+			t.Errorf("seed %d: greedy solution reported optimal", seed)
+		}
+		if !feasible(s.Allocation, m) {
+			t.Errorf("seed %d: infeasible allocation %v", seed, s.Allocation)
+		}
+		if u := s.Allocation.FindTotalUtility(bs); math.Abs(u-s.TotalUtility) > priceTolerance {
+			t.Errorf("seed %d: allocation worth %v, not %v", seed, u, s.TotalUtility)
+		}
+		ratio := 1.0
+		if opt.TotalUtility > 0 {
+			ratio = s.TotalUtility / opt.TotalUtility
+		}
+		if ratio < 1/math.Sqrt(float64(m))-priceTolerance || ratio > 1+priceTolerance {
+			t.Errorf("seed %d (n = %d, m = %d): greedy %v, optimum %v", seed, n, m, s.TotalUtility, opt.TotalUtility)
+		}
+		worst = math.Min(worst, ratio)
+		total += ratio
+	}
+	t.Logf("greedy reaches %.3f of the optimum on average, %.3f at worst", total/instances, worst)
+}
+
+// TestGreedyLarge solves an instance far too large to search.
+func TestGreedyLarge(t *testing.T) {
+	const n, m = 20, 40
+	r := rand.New(rand.NewSource(1))
+	bs := make(BidSet, n+1)
+	for agent := 1; agent <= n; agent++ {
+		bs[agent] = make(Bid)
+		for i := 0; i < 10; i++ {
+			bs[agent][1+r.Int63n(1<<m-1)] = 100 * r.Float64()
+		}
+	}
+	s := SolveAllocationGreedy(bs, n, m)
+	if !feasible(s.Allocation, m) {
+		t.Fatalf("infeasible allocation %v", s.Allocation)
+	}
+	if s.TotalUtility <= 0 {
+		t.Errorf("utility %v, want some bundles sold", s.TotalUtility)
+	}
+}
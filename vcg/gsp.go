@@ -0,0 +1,36 @@
+package vcg
+
+import (
+	"fmt"
+)
+
+// CalculatePricesGSP prices the solution by the generalized second price
+// rule instead of VCG: each agent pays the highest bid any other agent
+// placed on exactly the bundle it won, or 0 if nobody else bid on it.
+// Agents which won nothing pay 0.
+//
+// With a single item this is the second price, like VCG. With more items
+// it ignores how the other agents would have used the items otherwise, so
+// the prices generally differ from CalculatePrices.
+func (s *Solution) CalculatePricesGSP(bs BidSet, n, m int) error {
+	if n < 0 {
+		return fmt.Errorf("cannot price an auction with %d agents", n)
+	}
+	s.PricePerAgent = make(map[int]float64)
+	s.Breakdown = nil
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		bundle := s.Allocation.Flags(agent)
+		if bundle == 0 {
+			s.PricePerAgent[agent] = 0
+			continue
+		}
+		var price float64
+		for other := 1; other < len(bs); other++ {
+			if other != agent && bs[other].ValueOf(bundle) > price {
+				price = bs[other].ValueOf(bundle)
+			}
+		}
+		s.PricePerAgent[agent] = price
+	}
+	return nil
+}
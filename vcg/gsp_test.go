@@ -0,0 +1,35 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGSPAndVCG(t *testing.T) {
+	// with a single item both charge the second-highest bid
+	bs := BidSet{nil, Bid{0x1: 5}, Bid{0x1: 3}}
+	vcg, gsp := SolveAllocation(bs, 2, 1), SolveAllocation(bs, 2, 1)
+	if err := vcg.CalculatePrices(bs, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := gsp.CalculatePricesGSP(bs, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 3, 2: 0}; !reflect.DeepEqual(vcg.PricePerAgent, want) || !reflect.DeepEqual(gsp.PricePerAgent, want) {
+		t.Errorf("VCG prices %v and GSP prices %v, want both %v", vcg.PricePerAgent, gsp.PricePerAgent, want)
+	}
+
+	// in problem1 nobody else bids on items 0 and 1 together, so agent 2
+	// pays nothing under GSP but 4 under VCG; agents 1 and 3 pay the same
+	bs = problem1Bids()
+	gsp = SolveAllocation(bs, 4, 4)
+	if err := gsp.CalculatePricesGSP(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 3, 2: 0, 3: 2, 4: 0}; !reflect.DeepEqual(gsp.PricePerAgent, want) {
+		t.Errorf("GSP prices %v, want %v", gsp.PricePerAgent, want)
+	}
+	if gsp.Breakdown != nil {
+		t.Errorf("GSP prices have a VCG breakdown %v", gsp.Breakdown)
+	}
+}
@@ -0,0 +1,339 @@
+package vcg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// jsonAuction is the JSON document read by LoadBidSet:
+//
+//	{
+//	  "items": 4,
+//	  "agents": [
+//	    {"bids": [{"items": [0, 1], "value": 5}, {"items": [2], "value": 1}]},
+//	    {"bids": [{"items": [1, 2, 3], "value": 7}]}
+//	  ],
+//	  "seller": {"bids": [{"items": [2, 3], "value": 3}]}
+//	}
+//
+// The first listed agent is agent 1. "items" is optional and defaults to one
+// more than the highest item index found in the bids. "seller" is optional
+// and holds the bid of agent 0, see Allocation.SellerUtility.
+type jsonAuction struct {
+	Items  *int        `json:"items"`
+	Agents []jsonAgent `json:"agents"`
+	Seller *jsonAgent  `json:"seller,omitempty"`
+}
+
+type jsonAgent struct {
+	Bids []jsonBid `json:"bids"`
+}
+
+type jsonBid struct {
+	Items []int   `json:"items"`
+	Value float64 `json:"value"`
+}
+
+// LoadBidSet reads bids from a JSON document and returns them together with
+// the number of agents n and items m. The document is checked with
+// ValidateInputJSON first, for precise errors.
+func LoadBidSet(r io.Reader) (bs BidSet, n, m int, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if err = ValidateInputJSON(data); err != nil {
+		return nil, 0, 0, err
+	}
+	var doc jsonAuction
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, 0, err
+	}
+	return doc.bidSet()
+}
+
+// LoadBidSets reads a JSON array of auctions, each in the format of
+// LoadBidSet and checked with ValidateInputJSON like it, and returns the
+// bids of each together with its number of items, from "items" or else
+// from the highest item bid on as LoadBidSet does. The number of agents of
+// auction i is len(bss[i]) - 1. Errors name the index of the failing
+// auction.
+func LoadBidSets(r io.Reader) (bss []BidSet, ms []int, err error) {
+	var docs []json.RawMessage
+	if err = json.NewDecoder(r).Decode(&docs); err != nil {
+		return nil, nil, err
+	}
+	bss = make([]BidSet, len(docs))
+	ms = make([]int, len(docs))
+	for i, data := range docs {
+		if err = ValidateInputJSON(data); err != nil {
+			return nil, nil, fmt.Errorf("auction %d: %s", i, err)
+		}
+		var doc jsonAuction
+		if err = json.Unmarshal(data, &doc); err != nil {
+			return nil, nil, fmt.Errorf("auction %d: %s", i, err)
+		}
+		if bss[i], _, ms[i], err = doc.bidSet(); err != nil {
+			return nil, nil, fmt.Errorf("auction %d: %s", i, err)
+		}
+	}
+	return
+}
+
+// maxWideItems is the most items LoadWideBidSet accepts, so a stray item
+// index cannot make an allocation huge.
+const maxWideItems = 1 << 12
+
+// LoadWideBidSet is LoadBidSet for any number of items, up to 4096. The
+// document is checked like by ValidateInputJSON, but for that many items.
+func LoadWideBidSet(r io.Reader) (bs WideBidSet, n, m int, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if err = validateInput(data, maxWideItems); err != nil {
+		return nil, 0, 0, err
+	}
+	var doc jsonAuction
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, 0, err
+	}
+	return doc.wideBidSet()
+}
+
+// SaveWideBidSet is SaveBidSet for wide bids, which LoadWideBidSet reads
+// back.
+func SaveWideBidSet(w io.Writer, bs WideBidSet, m int) error {
+	doc := jsonAuction{Items: &m, Agents: []jsonAgent{}}
+	for agent := 1; agent < len(bs); agent++ {
+		doc.Agents = append(doc.Agents, newWideJSONAgent(bs[agent]))
+	}
+	if len(bs) > 0 && len(bs[Unassigned]) > 0 {
+		seller := newWideJSONAgent(bs[Unassigned])
+		doc.Seller = &seller
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// newWideJSONAgent is newJSONAgent for a wide bid. Bundles are listed in
+// increasing order of their items.
+func newWideJSONAgent(bid WideBid) (a jsonAgent) {
+	a.Bids = []jsonBid{}
+	for key, utility := range bid {
+		items := bundleOfKey(key).Items()
+		if items == nil {
+			items = []int{}
+		}
+		a.Bids = append(a.Bids, jsonBid{Items: items, Value: utility})
+	}
+	sort.Slice(a.Bids, func(i, j int) bool {
+		x, y := a.Bids[i].Items, a.Bids[j].Items
+		for k := 0; k < len(x) && k < len(y); k++ {
+			if x[k] != y[k] {
+				return x[k] < y[k]
+			}
+		}
+		return len(x) < len(y)
+	})
+	return
+}
+
+// SaveBidSet writes bs for m items as a JSON document which LoadBidSet
+// reads back. Bundles are listed in increasing order of their Bid flags.
+func SaveBidSet(w io.Writer, bs BidSet, m int) error {
+	doc := jsonAuction{Items: &m, Agents: []jsonAgent{}}
+	for agent := 1; agent < len(bs); agent++ {
+		doc.Agents = append(doc.Agents, newJSONAgent(bs[agent]))
+	}
+	if len(bs) > 0 && len(bs[Unassigned]) > 0 {
+		seller := newJSONAgent(bs[Unassigned])
+		doc.Seller = &seller
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func newJSONAgent(bid Bid) (a jsonAgent) {
+	bundles := make([]int64, 0, len(bid))
+	for flags := range bid {
+		bundles = append(bundles, flags)
+	}
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i] < bundles[j] })
+	a.Bids = []jsonBid{}
+	for _, flags := range bundles {
+		items := []int{}
+		for item := 0; uint64(flags)>>uint(item) != 0; item++ {
+			if flags&(1<<uint(item)) != 0 {
+				items = append(items, item)
+			}
+		}
+		a.Bids = append(a.Bids, jsonBid{Items: items, Value: bid[flags]})
+	}
+	return
+}
+
+// items returns "items", or else one more than the highest item bid on.
+func (doc jsonAuction) items() (m int) {
+	if doc.Items != nil {
+		return *doc.Items
+	}
+	agents := doc.Agents
+	if doc.Seller != nil {
+		agents = append([]jsonAgent{*doc.Seller}, agents...)
+	}
+	for _, agent := range agents {
+		for _, bid := range agent.Bids {
+			for _, item := range bid.Items {
+				if item >= m {
+					m = item + 1
+				}
+			}
+		}
+	}
+	return
+}
+
+func (doc jsonAuction) bidSet() (bs BidSet, n, m int, err error) {
+	n, m = len(doc.Agents), doc.items()
+	if m < 0 || m > MaxFlagItems {
+		return nil, 0, 0, fmt.Errorf("number of items %d out of range 0..%d", m, MaxFlagItems)
+	}
+
+	bs = make(BidSet, n+1)
+	bs[Unassigned] = make(Bid)
+	if doc.Seller != nil {
+		if bs[Unassigned], err = doc.Seller.bid(Unassigned, m); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	for i, agent := range doc.Agents {
+		if bs[i+1], err = agent.bid(i+1, m); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	return
+}
+
+// wideBidSet is bidSet for wide bids.
+func (doc jsonAuction) wideBidSet() (bs WideBidSet, n, m int, err error) {
+	n, m = len(doc.Agents), doc.items()
+	bs = make(WideBidSet, n+1)
+	bs[Unassigned] = make(WideBid)
+	if doc.Seller != nil {
+		if bs[Unassigned], err = doc.Seller.wideBid(Unassigned, m); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	for i, agent := range doc.Agents {
+		if bs[i+1], err = agent.wideBid(i+1, m); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	return
+}
+
+// wideBid is bid for wide bids.
+func (a jsonAgent) wideBid(agent, m int) (bid WideBid, err error) {
+	bid = make(WideBid)
+	for _, b := range a.Bids {
+		bundle := Bundle{}
+		for _, item := range b.Items {
+			if item < 0 || item >= m {
+				return nil, fmt.Errorf("%s: item %d out of range 0..%d", agentName(agent), item, m-1)
+			}
+			bundle = bundle.Add(item)
+		}
+		if _, ok := bid[bundle.Key()]; ok {
+			return nil, fmt.Errorf("%s: bundle %v listed twice", agentName(agent), b.Items)
+		}
+		bid.Set(bundle, b.Value)
+	}
+	return
+}
+
+// bid converts the bids of agent a into a Bid over m items.
+func (a jsonAgent) bid(agent, m int) (bid Bid, err error) {
+	bid = make(Bid)
+	for _, b := range a.Bids {
+		var flags int64
+		for _, item := range b.Items {
+			if item < 0 || item >= m {
+				return nil, fmt.Errorf("%s: item %d out of range 0..%d", agentName(agent), item, m-1)
+			}
+			flags = flags | 1<<uint(item)
+		}
+		if _, ok := bid[flags]; ok {
+			return nil, fmt.Errorf("%s: bundle %v listed twice", agentName(agent), b.Items)
+		}
+		bid[flags] = b.Value
+	}
+	return
+}
+
+// agentName names agent in error messages.
+func agentName(agent int) string {
+	if agent == Unassigned {
+		return "seller"
+	}
+	return fmt.Sprintf("agent %d", agent)
+}
+
+// jsonSolution is the JSON document written by Solution.MarshalJSON.
+type jsonSolution struct {
+	TotalUtility float64            `json:"total_utility"`
+	Optimal      bool               `json:"optimal"`
+	RunnerUp     *float64           `json:"runner_up_utility,omitempty"`
+	Margin       *float64           `json:"margin,omitempty"`
+	Agents       []jsonAgentOutcome `json:"agents"`
+	Unsold       []int              `json:"unsold"`
+}
+
+type jsonAgentOutcome struct {
+	Agent     int                   `json:"agent"`
+	Items     []int                 `json:"items"`
+	Price     *float64              `json:"price,omitempty"`
+	Breakdown *jsonPaymentBreakdown `json:"breakdown,omitempty"`
+}
+
+type jsonPaymentBreakdown struct {
+	WelfareWithoutAgent float64 `json:"welfare_without_agent"`
+	WelfareOfOthers     float64 `json:"welfare_of_others"`
+	Externality         float64 `json:"externality"`
+}
+
+// MarshalJSON writes the solution with every real agent's items as a sorted
+// list of item indices, its price and its PaymentBreakdown if prices were
+// calculated, and the items left with agent 0 as "unsold". The runner-up
+// utility and margin are written when known and finite.
+func (s Solution) MarshalJSON() ([]byte, error) {
+	doc := jsonSolution{
+		TotalUtility: s.TotalUtility,
+		Optimal:      s.Optimal,
+		Agents:       []jsonAgentOutcome{},
+		Unsold:       s.Allocation.items(0),
+	}
+	if s.Evaluated >= 2 && !math.IsInf(s.RunnerUpUtility, 0) && !math.IsInf(s.Margin, 0) && !math.IsNaN(s.Margin) {
+		doc.RunnerUp, doc.Margin = &s.RunnerUpUtility, &s.Margin
+	}
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		outcome := jsonAgentOutcome{
+			Agent: agent,
+			Items: s.Allocation.items(agent),
+		}
+		if price, ok := s.PricePerAgent[agent]; ok {
+			outcome.Price = &price
+		}
+		if b, ok := s.Breakdown[agent]; ok {
+			outcome.Breakdown = &jsonPaymentBreakdown{b.WelfareWithoutAgent, b.WelfareOfOthers, b.Externality}
+		}
+		doc.Agents = append(doc.Agents, outcome)
+	}
+	return json.Marshal(doc)
+}
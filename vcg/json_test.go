@@ -0,0 +1,204 @@
+package vcg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadBidSet(t *testing.T) {
+	bs, n, m, err := LoadBidSet(strings.NewReader(`{
+		"agents": [
+			{"bids": [{"items": [0, 1], "value": 5}, {"items": [2], "value": 1}]},
+			{"bids": [{"items": [1, 2, 3], "value": 7}]}
+		],
+		"seller": {"bids": [{"items": [3], "value": 0.5}]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || m != 4 {
+		t.Errorf("got n = %d and m = %d, want 2 and 4", n, m)
+	}
+	want := BidSet{
+		Bid{0x8: 0.5},
+		Bid{0x3: 5, 0x4: 1},
+		Bid{0xe: 7},
+	}
+	if !reflect.DeepEqual(bs, want) {
+		t.Errorf("got %v, want %v", bs, want)
+	}
+}
+
+func TestLoadBidSetErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, doc, err string
+	}{
+		{"item out of range", `{"items": 2, "agents": [{"bids": [{"items": [2], "value": 1}]}]}`, "agents[0].bids[0].items[0]: 2 out of range 0..1"},
+		{"bundle twice", `{"agents": [{"bids": []}, {"bids": [{"items": [0, 1], "value": 1}, {"items": [1, 0], "value": 2}]}]}`, "agent 2: bundle [1 0] listed twice"},
+		{"seller item out of range", `{"items": 1, "agents": [], "seller": {"bids": [{"items": [1], "value": 1}]}}`, "seller.bids[0].items[0]: 1 out of range 0..0"},
+		{"not JSON", `{"agents": [`, ""},
+	} {
+		_, _, _, err := LoadBidSet(strings.NewReader(test.doc))
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %q, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+// TestSolutionJSONRoundTrip loads the bids of examples/problem1.json,
+// solves and prices them, and reads the JSON written for the solution back.
+// TestWideBidSetJSONRoundTrip saves wide bids on 120 items, the highest of
+// which nobody bids on, and loads them back.
+func TestWideBidSetJSONRoundTrip(t *testing.T) {
+	bs := GenerateWideBidSet(GenOptions{Agents: 3, Items: 120, BundlesPerAgent: 5, MaxBundleSize: 4, Seed: 2})
+	bs[1].Set(NewBundle(), -1)
+	bs[2].Set(NewBundle(118), 2)
+	var doc bytes.Buffer
+	if err := SaveWideBidSet(&doc, bs, 120); err != nil {
+		t.Fatal(err)
+	}
+	got, n, m, err := LoadWideBidSet(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || m != 120 || !reflect.DeepEqual(got, bs) {
+		t.Errorf("loaded %d agents and %d items with bids %v, want 3, 120 and %v", n, m, got, bs)
+	}
+	if _, _, _, err := LoadWideBidSet(strings.NewReader(`{"items": 5000, "agents": []}`)); err == nil || err.Error() != "items: 5000 out of range 0..4096" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestSolutionJSONRoundTrip(t *testing.T) {
+	f, err := os.Open("../examples/problem1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bs, n, m, err := LoadBidSet(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := SolveAllocation(bs, n, m)
+	s.CalculatePrices(bs, n, m)
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		TotalUtility float64 `json:"total_utility"`
+		Optimal      bool    `json:"optimal"`
+		Agents       []struct {
+			Agent int      `json:"agent"`
+			Items []int    `json:"items"`
+			Price *float64 `json:"price"`
+		} `json:"agents"`
+		Unsold []int `json:"unsold"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("%s: %s", data, err)
+	}
+	if got.TotalUtility != s.TotalUtility || !got.Optimal || len(got.Unsold) != 0 {
+		t.Errorf("got %s", data)
+	}
+	if len(got.Agents) != n {
+		t.Fatalf("got %d agents, want %d: %s", len(got.Agents), n, data)
+	}
+	for i, outcome := range got.Agents {
+		if outcome.Agent != i+1 {
+			t.Errorf("agent %d listed as %d", i+1, outcome.Agent)
+		}
+		if want := s.Allocation.items(i + 1); !reflect.DeepEqual(outcome.Items, want) {
+			t.Errorf("agent %d: items %v, want %v", i+1, outcome.Items, want)
+		}
+		if outcome.Price == nil || *outcome.Price != s.PricePerAgent[i+1] {
+			t.Errorf("agent %d: price %v, want %v", i+1, outcome.Price, s.PricePerAgent[i+1])
+		}
+	}
+
+	// the bids themselves round-trip through SaveBidSet
+	var buf bytes.Buffer
+	if err := SaveBidSet(&buf, bs, m); err != nil {
+		t.Fatal(err)
+	}
+	again, n2, m2, err := LoadBidSet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n2 != n || m2 != m || !reflect.DeepEqual(again, bs) {
+		t.Errorf("SaveBidSet and LoadBidSet gave %v for %d agents and %d items, want %v for %d and %d", again, n2, m2, bs, n, m)
+	}
+}
+
+// TestLoadBidSetsBatch reads four auctions from one document and checks
+// SolveBatch solves them in order, with the items of "items" even if nobody
+// bids on them.
+func TestLoadBidSetsBatch(t *testing.T) {
+	bss, ms, err := LoadBidSets(strings.NewReader(`[
+		{"agents": [{"bids": [{"items": [0], "value": 1}]}, {"bids": [{"items": [0], "value": 2}]}]},
+		{"agents": [{"bids": [{"items": [0, 1], "value": 3}]}, {"bids": [{"items": [0], "value": 2}, {"items": [0, 1], "value": 4}]}]},
+		{"agents": [{"bids": [{"items": [1], "value": 5}]}]},
+		{"items": 3, "agents": [{"bids": [{"items": [0], "value": 1}]}, {"bids": []}]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 2, 3}; !reflect.DeepEqual(ms, want) {
+		t.Errorf("got %v items, want %v", ms, want)
+	}
+	solutions := SolveBatch(bss, ms)
+	if len(solutions) != 4 {
+		t.Fatalf("got %d solutions, want 4", len(solutions))
+	}
+	for i, want := range []struct {
+		allocation string
+		utility    float64
+	}{
+		{"agent2:{item0}", 2},
+		{"agent2:{item0,item1}", 4},
+		{"agent1:{item1} unsold:{item0}", 5},
+		{"agent1:{item0} unsold:{item1,item2}", 1},
+	} {
+		if got := solutions[i].Allocation.String(); got != want.allocation || solutions[i].TotalUtility != want.utility {
+			t.Errorf("auction %d: got %s worth %v, want %s worth %v", i, got, solutions[i].TotalUtility, want.allocation, want.utility)
+		}
+	}
+}
+
+// TestSolveBatchInvalid checks an invalid auction makes SolveBatch panic on
+// the caller's goroutine, where the panic can be recovered.
+func TestSolveBatchInvalid(t *testing.T) {
+	bss := []BidSet{{nil, Bid{0x1: 1}}, {nil, Bid{0x4: 1}}}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "auction 1: agent 1: bundle 100 holds items outside 0..1") {
+			t.Errorf("SolveBatch panicked with %v", r)
+		}
+	}()
+	SolveBatch(bss, []int{1, 2})
+}
+
+func TestLoadBidSetsErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, doc, err string
+	}{
+		{"item out of range", `[{"agents": []}, {"items": 2, "agents": [{"bids": [{"items": [2], "value": 1}]}]}]`, "auction 1: agents[0].bids[0].items[0]: 2 out of range 0..1"},
+		{"negative item", `[{"agents": [{"bids": [{"items": [-1], "value": 1}]}]}]`, "auction 0: agents[0].bids[0].items[0]"},
+		{"no agents", `[{"agents": []}, {"agents": []}, {}]`, "auction 2: "},
+		{"not an array", `{"agents": []}`, ""},
+	} {
+		_, _, err := LoadBidSets(strings.NewReader(test.doc))
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %q, want %q", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package vcg
+
+// lpTolerance is the smallest coefficient the simplex method treats as
+// non-zero.
+const lpTolerance = 1e-9
+
+// maximizeLP returns the maximum of c·x subject to a·x <= b and x >= 0,
+// where b >= 0 so that the origin is a feasible start. It uses the simplex
+// method with Bland's rule, which cannot cycle. ok is false when c·x is
+// unbounded.
+func maximizeLP(a [][]float64, b, c []float64) (max float64, ok bool) {
+	max, _, ok = maximizeLPDual(a, b, c)
+	return
+}
+
+// maximizeLPDual is maximizeLP which also returns an optimal solution y of
+// the dual problem, minimizing b·y subject to aᵀ·y >= c and y >= 0: the
+// shadow price of every constraint.
+func maximizeLPDual(a [][]float64, b, c []float64) (max float64, dual []float64, ok bool) {
+	rows, vars := len(a), len(c)
+	cols := vars + rows
+
+	// Tableau row j is constraint j with slack variable vars+j, the last
+	// row holds the reduced costs and the objective value.
+	t := make([][]float64, rows+1)
+	basis := make([]int, rows)
+	for j := 0; j < rows; j++ {
+		t[j] = make([]float64, cols+1)
+		copy(t[j], a[j])
+		t[j][vars+j] = 1
+		t[j][cols] = b[j]
+		basis[j] = vars + j
+	}
+	t[rows] = make([]float64, cols+1)
+	for i := 0; i < vars; i++ {
+		t[rows][i] = -c[i]
+	}
+
+	for {
+		enter := -1
+		for col := 0; col < cols; col++ {
+			if t[rows][col] < -lpTolerance {
+				enter = col
+				break
+			}
+		}
+		if enter < 0 {
+			dual = make([]float64, rows)
+			for j := range dual {
+				dual[j] = t[rows][vars+j]
+			}
+			return t[rows][cols], dual, true
+		}
+		leave := -1
+		var best_ratio float64
+		for j := 0; j < rows; j++ {
+			if t[j][enter] <= lpTolerance {
+				continue
+			}
+			ratio := t[j][cols] / t[j][enter]
+			// ratios within lpTolerance are ties, or round-off could
+			// defeat Bland's rule and cycle on degenerate pivots
+			if leave < 0 || ratio < best_ratio-lpTolerance || (ratio <= best_ratio+lpTolerance && basis[j] < basis[leave]) {
+				leave, best_ratio = j, ratio
+			}
+		}
+		if leave < 0 {
+			return 0, nil, false
+		}
+
+		pivot := t[leave][enter]
+		for col := range t[leave] {
+			t[leave][col] /= pivot
+		}
+		for j := range t {
+			if j == leave || t[j][enter] == 0 {
+				continue
+			}
+			factor := t[j][enter]
+			for col := range t[j] {
+				t[j][col] -= factor * t[leave][col]
+			}
+		}
+		basis[leave] = enter
+	}
+}
+
+// LPUpperBound returns the optimum of the linear relaxation of the
+// allocation problem, in which each agent may win fractions of its bundles
+// adding up to at most 1 and each item may be split between bundles
+// adding up to at most 1. It is never below the total utility of any
+// allocation of bs, so it bounds how far a solution can be from optimal.
+// The bundles of the seller's bid are only limited by their items, since
+// the seller may keep any number of them, see Allocation.SellerUtility.
+func LPUpperBound(bs BidSet, n, m int) float64 {
+	// one variable per positive bid, one constraint per agent and item
+	var a [][]float64
+	var c []float64
+	constraints := n + m
+	for agent := 0; agent <= n; agent++ {
+		if agent == Unassigned && len(bs) <= Unassigned {
+			// no seller bid
+			continue
+		}
+		for flags, utility := range bs[agent] {
+			if utility <= 0 || flags == 0 {
+				continue
+			}
+			c = append(c, utility)
+			column := make([]float64, constraints)
+			if agent != Unassigned {
+				column[agent-1] = 1
+			}
+			for item := 0; item < m; item++ {
+				if flags&(1<<uint(item)) != 0 {
+					column[n+item] = 1
+				}
+			}
+			a = append(a, column)
+		}
+	}
+	if len(c) == 0 {
+		return 0
+	}
+
+	rows := make([][]float64, constraints)
+	b := make([]float64, constraints)
+	for j := range rows {
+		rows[j] = make([]float64, len(c))
+		for i := range c {
+			rows[j][i] = a[i][j]
+		}
+		b[j] = 1
+	}
+	max, _ := maximizeLP(rows, b, c)
+	return max
+}
+
+// GapBound is how much better than s the optimum of bs can be at most,
+// using LPUpperBound.
+func (s Solution) GapBound(bs BidSet) float64 {
+	n, m := bs.Size()
+	return LPUpperBound(bs, n, m) - s.TotalUtility
+}
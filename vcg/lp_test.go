@@ -0,0 +1,49 @@
+package vcg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMaximizeLP(t *testing.T) {
+	// maximize 3x + 2y subject to x + y <= 4, x + 3y <= 6, x <= 3
+	max, ok := maximizeLP([][]float64{{1, 1}, {1, 3}, {1, 0}}, []float64{4, 6, 3}, []float64{3, 2})
+	if !ok || math.Abs(max-11) > priceTolerance {
+		t.Errorf("got %v, %v, want 11, true", max, ok)
+	}
+}
+
+// TestLPUpperBound checks the LP relaxation is never below the integer
+// optimum on 100 random instances, and that GapBound is never negative.
+func TestLPUpperBound(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		n, m := 1+int(seed%4), 1+int(seed%6)
+		bs := GenerateBidSet(GenOptions{
+			Agents:       n,
+			Items:        m,
+			Distribution: Distribution(seed % 6),
+			Seed:         seed,
+		})
+		s := SolveAllocation(bs, n, m)
+		bound := LPUpperBound(bs, n, m)
+		if bound < s.TotalUtility-priceTolerance {
+			t.Errorf("seed %d (n = %d, m = %d): LP bound %v below optimum %v", seed, n, m, bound, s.TotalUtility)
+		}
+		if gap := s.GapBound(bs); gap < -priceTolerance {
+			t.Errorf("seed %d: gap %v is negative", seed, gap)
+		}
+	}
+}
+
+// TestLPUpperBoundFractional checks the bound on an instance whose LP
+// optimum is fractional: three agents each want a different pair of three
+// items, so only one can win but the relaxation gives each half a bundle.
+func TestLPUpperBoundFractional(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x3: 2}, Bid{0x6: 2}, Bid{0x5: 2}}
+	if got := LPUpperBound(bs, 3, 3); math.Abs(got-3) > priceTolerance {
+		t.Errorf("got %v, want 3", got)
+	}
+	if got := SolveAllocation(bs, 3, 3).TotalUtility; got != 2 {
+		t.Errorf("optimum %v, want 2", got)
+	}
+}
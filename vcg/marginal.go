@@ -0,0 +1,31 @@
+package vcg
+
+// ItemMarginalValues returns, for each item (indexed by item), how much the
+// total utility of the optimal allocation drops when the item is removed
+// from the auction. Like the leave-one-out instances of CalculatePrices,
+// the item keeps its number: Options.Eligibility keeps it from every agent,
+// so it stays with the seller, whose bids count as if it did not hold it.
+// Like SolveAllocation, it panics if bs is not valid.
+func ItemMarginalValues(bs BidSet, n, m int) (marginals []float64) {
+	welfare := SolveAllocation(bs, n, m).TotalUtility
+	marginals = make([]float64, m)
+	all := int64(1)<<uint(m) - 1
+	for item := 0; item < m; item++ {
+		bit := int64(1) << uint(item)
+		opts := Options{Eligibility: make(map[int]int64)}
+		for agent := 1; agent <= n; agent++ {
+			opts.Eligibility[agent] = all &^ bit
+		}
+		without := bs
+		if len(bs) > 0 && bs[Unassigned] != nil {
+			without = append(BidSet{make(Bid)}, bs[1:]...)
+			for flags, utility := range bs[Unassigned] {
+				if flags&bit == 0 {
+					without[Unassigned][flags|bit] = utility
+				}
+			}
+		}
+		marginals[item] = welfare - SolveAllocationWithOptions(without, n, m, opts).TotalUtility
+	}
+	return
+}
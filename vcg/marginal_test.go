@@ -0,0 +1,30 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestItemMarginalValues checks the marginals of an instance small enough
+// to compute by hand: agent 1 wins item 0 for 3 and agent 2 item 1 for 4,
+// beating agent 1's bid of 5 on both. Without item 0 only agent 2 can win,
+// without item 1 only agent 1's bid of 3 is left, and nobody bids on item 2.
+func TestItemMarginalValues(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 3, 0x3: 5}, Bid{0x2: 4}}
+	want := []float64{3, 4, 0}
+	if got := ItemMarginalValues(bs, 2, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestItemMarginalValuesNegativeEmptyBundle checks a removed item is not
+// sold: agent 1 bids -2 on winning nothing and 3 on item 0, so without
+// item 0 it is left with -2, not the 0 of holding the item it did not bid
+// on. The seller's reserve of 1 on item 0 goes with the item.
+func TestItemMarginalValuesNegativeEmptyBundle(t *testing.T) {
+	bs := BidSet{Bid{0x1: 1}, Bid{0x0: -2, 0x1: 3}, Bid{0x1: 1}}
+	want := []float64{5}
+	if got := ItemMarginalValues(bs, 2, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
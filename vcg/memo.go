@@ -0,0 +1,174 @@
+package vcg
+
+import (
+	"context"
+	"math"
+)
+
+// memoKey identifies a subproblem of the memoized search: the items not yet
+// allocated, the first agent still eligible to receive them, the agent
+// left out of the auction when pricing and the exclusive groups which
+// already have a member holding items. Once the excluded agent has been
+// passed it no longer matters, so it is stored as 0 and such subproblems
+// are shared between all leave-one-out instances.
+type memoKey struct {
+	remaining int64
+	agent     int
+	excluded  int
+	taken     uint64
+}
+
+// memoEntry is the solution of a subproblem: the best total utility and the
+// bundle taken by memoKey.agent to achieve it.
+type memoEntry struct {
+	utility float64
+	bundle  int64
+}
+
+// memoSearch solves the allocation agent by agent instead of item by item:
+// agent k takes a bundle out of the remaining items, agents k+1..n share the
+// rest and agent 0 keeps whatever is left. Every subproblem is solved once and
+// kept in table, which is shared by a solve and the leave-one-out solves
+// of its pricing.
+type memoSearch struct {
+	ctx   context.Context
+	bs    BidSet
+	n     int
+	opts  Options
+	table map[memoKey]memoEntry
+	masks []uint64 // exclusive groups of every agent, see Options.groupMasks
+	all   int64    // Bid flags of all items
+}
+
+func newMemoSearch(ctx context.Context, bs BidSet, n, m int, opts Options) *memoSearch {
+	return &memoSearch{
+		ctx:   ctx,
+		bs:    bs,
+		n:     n,
+		all:   allItems(m),
+		opts:  opts,
+		table: make(map[memoKey]memoEntry),
+		masks: opts.groupMasks(n),
+	}
+}
+
+// solveMemoized solves the allocation with a memoSearch. Unlike the
+// exhaustive search it has no best-so-far solution, so when ctx is done it
+// returns an empty Solution and ctx.Err().
+func solveMemoized(ctx context.Context, bs BidSet, n, m int, opts Options) (s Solution, err error) {
+	return newMemoSearch(ctx, bs, n, m, opts).solve(m)
+}
+
+// solve allocates m items using and extending the table of ms.
+func (ms *memoSearch) solve(m int) (s Solution, err error) {
+	remaining := allItems(m)
+	s.TotalUtility = ms.best(remaining, 1, 0, 0)
+	if err = ms.ctx.Err(); err != nil {
+		return Solution{}, err
+	}
+	if math.IsInf(s.TotalUtility, -1) {
+		// no feasible allocation, same as the exhaustive search
+		return Solution{}, nil
+	}
+
+	s.Allocation = make(Allocation)
+	var taken uint64
+	for agent := 1; agent <= ms.n; agent++ {
+		bundle := ms.table[memoKey{remaining, agent, 0, taken}].bundle
+		s.Allocation[agent] = flagsToItems(bundle)
+		remaining = remaining &^ bundle
+		if bundle != 0 {
+			taken |= ms.mask(agent)
+		}
+	}
+	s.Allocation[Unassigned] = flagsToItems(remaining)
+	s.Optimal = true
+	return
+}
+
+// best returns the highest total utility of allocating remaining items to
+// agents agent..n, leaving out excluded (0 to leave out nobody), when the
+// exclusive groups of taken already have a member holding items.
+// It is -Inf if the items cannot be allocated or ms.ctx is done.
+func (ms *memoSearch) best(remaining int64, agent, excluded int, taken uint64) float64 {
+	if agent == excluded {
+		agent++
+	}
+	if excluded < agent {
+		excluded = 0
+	}
+	if agent > ms.n {
+		if ms.opts.ForceFullAllocation && remaining != 0 || ms.opts.RequireSale && remaining == ms.all {
+			return math.Inf(-1)
+		}
+		unsold := Allocation{Unassigned: flagsToItems(remaining)}
+		return unsold.ReserveUtility(ms.opts.ReservePrices) + unsold.SellerUtility(ms.bs)
+	}
+	key := memoKey{remaining, agent, excluded, taken}
+	if e, ok := ms.table[key]; ok {
+		return e.utility
+	}
+	if ms.ctx.Err() != nil {
+		return math.Inf(-1)
+	}
+
+	limits := ms.opts.limitsSizes()
+	e := memoEntry{utility: math.Inf(-1)}
+	if !limits || ms.opts.fitsSize(agent, 0) {
+		e.utility = ms.opts.value(ms.bs, agent, 0) + ms.best(remaining, agent+1, excluded, taken)
+	}
+	choices := remaining & ms.opts.eligibleItems(agent)
+	if ms.mask(agent)&taken != 0 {
+		// another member of a group of agent holds items
+		choices = 0
+	}
+	for bundle := choices; bundle > 0; bundle = (bundle - 1) & choices {
+		if limits && !ms.opts.fitsSize(agent, bundle) {
+			continue
+		}
+		if u := ms.opts.value(ms.bs, agent, bundle) + ms.best(remaining&^bundle, agent+1, excluded, taken|ms.mask(agent)); u > e.utility {
+			e = memoEntry{u, bundle}
+		}
+	}
+	ms.table[key] = e
+	return e.utility
+}
+
+// mask returns the exclusive groups of agent as bits.
+func (ms *memoSearch) mask(agent int) uint64 {
+	if ms.masks == nil {
+		return 0
+	}
+	return ms.masks[agent]
+}
+
+// invalidate removes the solutions of all subproblems which may change when
+// agent changes its bid on bundle: those in which agent or an agent before
+// it is next to take items, agent is not left out and bundle is among the
+// remaining items. The seller's bid on bundle matters to every subproblem
+// in which bundle is among the remaining items.
+func (ms *memoSearch) invalidate(agent int, bundle int64) {
+	for key := range ms.table {
+		if agent == Unassigned && bundle&^key.remaining == 0 {
+			delete(ms.table, key)
+		} else if key.agent <= agent && key.excluded != agent && bundle&^key.remaining == 0 {
+			delete(ms.table, key)
+		}
+	}
+}
+
+// allItems returns the Bid flags of a bundle holding all m items.
+func allItems(m int) int64 {
+	return int64(1)<<uint(m) - 1
+}
+
+// flagsToItems converts Bid flags into a set of items.
+func flagsToItems(flags int64) map[int]bool {
+	items := make(map[int]bool)
+	for item := 0; flags>>uint(item) != 0; item++ {
+		if flags&(1<<uint(item)) != 0 {
+			items[item] = true
+		}
+	}
+	return items
+}
@@ -0,0 +1,74 @@
+package vcg
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// randomBidSet gives each of n agents a random bid on every nonempty bundle
+// of m items.
+func randomBidSet(n, m int, seed int64) (bs BidSet) {
+	r := rand.New(rand.NewSource(seed))
+	bs = make(BidSet, n+1)
+	for agent := 1; agent <= n; agent++ {
+		bs[agent] = make(Bid)
+		for bundle := int64(1); bundle < 1<<uint(m); bundle++ {
+			bs[agent][bundle] = 100 * r.Float64()
+		}
+	}
+	return
+}
+
+// feasible reports whether a holds each of m items exactly once.
+func feasible(a Allocation, m int) bool {
+	held := make(map[int]bool)
+	for agent := range a {
+		for item := range a[agent] {
+			if held[item] || item < 0 || item >= m {
+				return false
+			}
+			held[item] = true
+		}
+	}
+	return len(held) == m
+}
+
+// TestMemoizeMatchesExhaustive solves random instances of up to 10 items
+// with and without Options.Memoize, and logs how much faster memoization is
+// for each number of agents. Instances whose exhaustive search would take
+// too long for a test are skipped.
+func TestMemoizeMatchesExhaustive(t *testing.T) {
+	for n := 1; n <= 5; n++ {
+		var memoized, exhaustive time.Duration
+		for m := 1; m <= 10; m++ {
+			if math.Pow(float64(n+1), float64(m)) > 1<<17 {
+				break
+			}
+			bs := randomBidSet(n, m, int64(10*n+m))
+
+			start := time.Now()
+			want := SolveAllocation(bs, n, m)
+			exhaustive += time.Since(start)
+			start = time.Now()
+			got := SolveAllocationWithOptions(bs, n, m, Options{Memoize: true})
+			memoized += time.Since(start)
+
+			if math.Abs(got.TotalUtility-want.TotalUtility) > 1e-9 {
+				t.Errorf("n = %d, m = %d: memoized utility %v, exhaustive %v", n, m, got.TotalUtility, want.TotalUtility)
+			}
+			held := 0
+			for agent := range got.Allocation {
+				held += len(got.Allocation[agent])
+			}
+			if held != m {
+				t.Errorf("n = %d, m = %d: memoized allocation holds %d items", n, m, held)
+			}
+			if u := got.Allocation.FindTotalUtility(bs); math.Abs(u-got.TotalUtility) > 1e-9 {
+				t.Errorf("n = %d, m = %d: memoized allocation is worth %v, not %v", n, m, u, got.TotalUtility)
+			}
+		}
+		t.Logf("%d agents: memoized search took %s, exhaustive %s, %.1fx faster", n, memoized, exhaustive, exhaustive.Seconds()/memoized.Seconds())
+	}
+}
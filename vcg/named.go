@@ -0,0 +1,101 @@
+package vcg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NamedBundleBid is a bid on a bundle of items referred to by name.
+type NamedBundleBid struct {
+	Items []string
+	Value float64
+}
+
+// NamedBidSet contains the bundle bids of every agent, keyed by agent name.
+type NamedBidSet map[string][]NamedBundleBid
+
+// Items returns the distinct names of the items bid on, sorted.
+func (nbs NamedBidSet) Items() (items []string) {
+	seen := make(map[string]bool)
+	for _, bids := range nbs {
+		for _, bid := range bids {
+			for _, item := range bid.Items {
+				if !seen[item] {
+					seen[item] = true
+					items = append(items, item)
+				}
+			}
+		}
+	}
+	sort.Strings(items)
+	return
+}
+
+// Compile numbers agents and items in sorted order of their names and
+// returns the bids the solver needs: agent agents[i] becomes agent i+1 and
+// item items[j] becomes item j. A bundle listed twice keeps the last value.
+// Compile panics if more than MaxFlagItems distinct items are named, which
+// callers can check with Items first.
+func (nbs NamedBidSet) Compile() (bs BidSet, agents []string, items []string) {
+	items = nbs.Items()
+	if len(items) > MaxFlagItems {
+		panic(fmt.Sprintf("vcg: %d items named, at most %d supported", len(items), MaxFlagItems))
+	}
+	for agent := range nbs {
+		agents = append(agents, agent)
+	}
+	sort.Strings(agents)
+	item_index := make(map[string]int)
+	for i, item := range items {
+		item_index[item] = i
+	}
+
+	bs = make(BidSet, len(agents)+1)
+	bs[Unassigned] = make(Bid)
+	for i, agent := range agents {
+		bs[i+1] = make(Bid)
+		for _, bid := range nbs[agent] {
+			var flags int64
+			for _, item := range bid.Items {
+				flags = flags | 1<<uint(item_index[item])
+			}
+			bs[i+1][flags] = bid.Value
+		}
+	}
+	return
+}
+
+// NamedSolution is a Solution with agents and items referred to by name.
+type NamedSolution struct {
+	// Allocation lists the items of every agent, sorted by name.
+	Allocation   map[string][]string
+	Unsold       []string
+	TotalUtility float64
+	// Prices is nil if the solution was not priced.
+	Prices map[string]float64
+}
+
+// Decode maps the solution back to the names returned by NamedBidSet.Compile.
+func (s Solution) Decode(agents, items []string) (ns NamedSolution) {
+	ns.Allocation = make(map[string][]string)
+	ns.TotalUtility = s.TotalUtility
+	names := func(agent int) (bundle []string) {
+		bundle = []string{}
+		for _, item := range s.Allocation.items(agent) {
+			bundle = append(bundle, items[item])
+		}
+		sort.Strings(bundle)
+		return
+	}
+	for i, agent := range agents {
+		ns.Allocation[agent] = names(i + 1)
+	}
+	ns.Unsold = names(0)
+	if s.PricePerAgent != nil {
+		ns.Prices = make(map[string]float64)
+		for i, agent := range agents {
+			ns.Prices[agent] = s.PricePerAgent[i+1]
+		}
+	}
+	return
+}
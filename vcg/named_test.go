@@ -0,0 +1,56 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamedRoundTrip(t *testing.T) {
+	nbs := NamedBidSet{
+		"BidderCorp": {
+			{Items: []string{"block-A", "block-B"}, Value: 6},
+		},
+		"AirCo": {
+			{Items: []string{"block-A"}, Value: 4},
+		},
+		"Comms": {
+			{Items: []string{"block-B"}, Value: 3},
+			{Items: []string{"block-C"}, Value: 1},
+		},
+	}
+	bs, agents, items := nbs.Compile()
+	if want := []string{"AirCo", "BidderCorp", "Comms"}; !reflect.DeepEqual(agents, want) {
+		t.Errorf("agents %v, want %v", agents, want)
+	}
+	if want := []string{"block-A", "block-B", "block-C"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("items %v, want %v", items, want)
+	}
+	if bs[2][0x3] != 6 || bs[3][0x4] != 1 {
+		t.Errorf("compiled bids %v", bs)
+	}
+
+	s := SolveAllocation(bs, len(agents), len(items))
+	if err := s.CalculatePrices(bs, len(agents), len(items)); err != nil {
+		t.Fatal(err)
+	}
+	ns := s.Decode(agents, items)
+	want := NamedSolution{
+		Allocation: map[string][]string{
+			"AirCo":      {"block-A"},
+			"BidderCorp": {},
+			"Comms":      {"block-B"},
+		},
+		Unsold:       []string{"block-C"},
+		TotalUtility: 7,
+		Prices:       map[string]float64{"AirCo": 4, "BidderCorp": 0, "Comms": 2},
+	}
+	if !reflect.DeepEqual(ns, want) {
+		t.Errorf("decoded %+v, want %+v", ns, want)
+	}
+
+	// an unpriced solution decodes without prices
+	s.PricePerAgent = nil
+	if ns := s.Decode(agents, items); ns.Prices != nil {
+		t.Errorf("unpriced solution decoded with prices %v", ns.Prices)
+	}
+}
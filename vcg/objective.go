@@ -0,0 +1,71 @@
+package vcg
+
+import (
+	"context"
+	"math"
+)
+
+// Objective is what the solver maximizes when choosing the allocation.
+type Objective int
+
+const (
+	// MaxWelfare maximizes the total utility of the agents, as VCG does.
+	MaxWelfare Objective = iota
+
+	// MaxRevenue maximizes the revenue of the VCG pricing rule applied to
+	// the allocation: each winner pays the total utility of the others when
+	// it is left out, minus the utility of the others in the allocation,
+	// and everybody else pays nothing. Only allocations in which no winner
+	// pays more than its bid, i.e. which are worth at least as much as the
+	// auction without any winner, are considered. Solution.TotalUtility
+	// stays the total utility of the chosen allocation.
+	//
+	// It always uses the exhaustive search, without pruning or
+	// memoization, and with weights the revenue is in units of the bids.
+	MaxRevenue
+)
+
+// paysNothing reports whether agent pays nothing for a under the objective
+// of o.
+func (o Options) paysNothing(a Allocation, agent int) bool {
+	return o.Objective == MaxRevenue && len(a[agent]) == 0
+}
+
+// solveRevenue finds the allocation of highest revenue, see MaxRevenue.
+func solveRevenue(ctx context.Context, bs BidSet, n, m int, opts Options) (s Solution, err error) {
+	welfare_opts := opts
+	welfare_opts.Objective = MaxWelfare
+	welfare_opts.Memoize, welfare_opts.Prune = false, false
+	// the total utility without each agent does not depend on the allocation
+	without := make([]float64, n+1)
+	for agent := 1; agent <= n; agent++ {
+		alternative_solution, err := searchWithout(ctx, bs, n, m, welfare_opts, agent)
+		if err != nil {
+			return Solution{}, err
+		}
+		without[agent] = alternative_solution.TotalUtility
+	}
+
+	s, err = solve(ctx, n, m, 0, welfare_opts, func(a Allocation, flags []int64) (revenue float64) {
+		total_utility := welfare_opts.utilityOfFlags(flags, bs, 0)
+		for agent := 1; agent < len(flags); agent++ {
+			if flags[agent] == 0 {
+				continue
+			}
+			if without[agent] > total_utility+priceTolerance {
+				// the agent would pay more than its bid
+				return math.Inf(-1)
+			}
+			if w := opts.weight(agent); w > 0 {
+				revenue += (without[agent] - total_utility + opts.value(bs, agent, flags[agent])) / w
+			}
+		}
+		return
+	}, nil)
+	if s.Allocation != nil {
+		s.TotalUtility = welfare_opts.utility(s.Allocation, bs)
+	}
+	// the runner-up was ranked by revenue
+	s.RunnerUpUtility, s.Margin = 0, 0
+	return
+}
@@ -0,0 +1,337 @@
+package vcg
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+)
+
+// Options tune the solver. The zero value solves the plain auction.
+type Options struct {
+	// ReservePrices is the minimum utility for each item (indexed by item).
+	// Agent 0 earns the reserve price of every item it holds, so an item
+	// stays unsold unless some bid beats its reserve.
+	ReservePrices []float64
+
+	// Memoize solves the allocation with dynamic programming over
+	// (remaining items, eligible agents) instead of enumerating all
+	// (n+1)^m allocations. Among allocations of equal utility it may pick a
+	// different one than the exhaustive search.
+	Memoize bool
+
+	// Prune skips branches of the search whose optimistic upper bound
+	// cannot beat the best allocation found so far. The optimum found is
+	// the same as without pruning.
+	Prune bool
+
+	// RequireSale forbids the allocation leaving every item with agent 0,
+	// so at least one real agent receives an item even if all bids are
+	// negative. The leave-one-out instances of the pricing must sell too;
+	// when they cannot, their welfare counts as 0. When no real agent can
+	// receive an item, no allocation is found.
+	RequireSale bool
+
+	// ForceFullAllocation forbids leaving items with agent 0, so every item
+	// is sold to a real agent even if nobody bids on it. When no real agent
+	// is left to receive the items, no allocation is found.
+	ForceFullAllocation bool
+
+	// Sequential runs the exhaustive search in a single goroutine.
+	// Instances with fewer than ParallelThreshold allocations are always
+	// searched sequentially, since goroutines would only add overhead there.
+	Sequential bool
+
+	// ParallelThreshold is the number of allocations, (n+1)^m, from which
+	// the exhaustive search runs in parallel. When 0, the default
+	// defaultParallelThreshold is used; 1 searches in parallel whenever
+	// there are enough items to split the search.
+	ParallelThreshold int64
+
+	// Parallelism is the number of workers of a parallel search. When 0,
+	// runtime.GOMAXPROCS(0) workers are used; 1 searches sequentially.
+	Parallelism int
+
+	// Iterative runs the exhaustive search with an explicit stack instead of
+	// recursion, one frame per item. It finds the same solution.
+	Iterative bool
+
+	// Deterministic makes the solution depend only on the bids and the
+	// options, bit for bit, whatever the machine: the search runs
+	// sequentially, like Sequential, and ties are still broken by TieBreak,
+	// or by agent number without it. Memoize is sequential and
+	// deterministic already, so Deterministic changes nothing there; it
+	// still ignores TieBreak, and on a tie may pick another allocation than
+	// the exhaustive search. Only OnProgress may still see a different
+	// sequence of calls.
+	Deterministic bool
+
+	// OnProgress is called every so often by the exhaustive search with the
+	// number of allocations visited or pruned so far and the (n+1)^m
+	// allocations of the whole search, and once more with visited equal to
+	// total when the search completes. The parallel search may call it from
+	// several goroutines at once. The leave-one-out searches of the pricing
+	// report too, each starting from 0.
+	OnProgress func(visited, total int64)
+
+	// Logger receives a line for every node of the search and for every
+	// leave-one-out solve of the pricing. When nil, nothing is logged.
+	// This is meant for debugging tiny instances only.
+	Logger *log.Logger
+
+	// Budgets is the most each agent can pay (indexed by agent, index 0 is
+	// unused). When the VCG price of an agent exceeds its budget, pricing
+	// returns a *BudgetError. Agents beyond its length have no budget.
+	Budgets []float64
+
+	// Eligibility holds the Bid flags of the items each agent may receive,
+	// keyed by agent. Agents which are not in it may receive any item.
+	// Pricing solves the leave-one-out instances under the same constraint.
+	Eligibility map[int]int64
+
+	// Weights multiplies the utility of each agent (indexed by agent, index
+	// 0 is unused), so the solver maximizes weighted welfare. Agents beyond
+	// its length have weight 1. Weights must not be negative. Prices are
+	// divided by the agent's weight to be in units of its bids; an agent
+	// of weight 0 pays nothing.
+	Weights []float64
+
+	// DefaultValue is the utility of a bundle the agent did not bid on.
+	// When nil, such bundles are worth 0.
+	DefaultValue func(agent int, bundle int64) float64
+
+	// Objective selects what the allocation maximizes, total utility by
+	// default. See MaxRevenue.
+	Objective Objective
+
+	// MinBundleSize and MaxBundleSize bound the number of items every real
+	// agent receives, MaxBundleSize 0 meaning no limit. BundleSizes
+	// overrides both for the agents in it. The agent left out by pricing is
+	// exempt. When no allocation respects the limits, none is found.
+	MinBundleSize int
+	MaxBundleSize int
+	BundleSizes   map[int]SizeLimit
+
+	// TieBreak lists agents from most to least preferred. Among
+	// allocations of equal utility, the search picks the one giving item 0
+	// to the most preferred agent, then item 1 and so on. Agents not
+	// listed, agent 0 included unless listed, come after all listed ones
+	// in order of their numbers. When empty, lower numbers are preferred.
+	// Options.Memoize ignores it.
+	TieBreak []int
+
+	// ExclusiveGroups lists groups of agents, e.g. bidders of the same
+	// company, of which at most one may receive items. An agent may belong
+	// to several groups; agent 0 belongs to none. Pricing solves the
+	// leave-one-out instances under the same constraint. Options.Memoize
+	// supports at most 64 groups.
+	ExclusiveGroups [][]int
+
+	// ItemOrder is the order in which the exhaustive search assigns the
+	// items, a permutation of 0..m-1. It finds the same optimum whatever
+	// the order, but pruning may cut more or fewer branches. When nil, items
+	// are assigned in increasing order, or with Prune, the items whose
+	// single-item bids differ most from each other and from their reserve
+	// first. Options.Memoize ignores it.
+	ItemOrder []int
+
+	// checkpoint is set by Solver.SolveContext to record or resume an
+	// interrupted search, see Solver.SaveCheckpoint.
+	checkpoint *checkpoint
+}
+
+// SizeLimit bounds the number of items of an agent, see
+// Options.BundleSizes. Max 0 means no limit.
+type SizeLimit struct {
+	Min, Max int
+}
+
+// value is the weighted utility of bundle for agent.
+func (o Options) value(bs BidSet, agent int, bundle int64) float64 {
+	if utility, ok := bs[agent][bundle]; ok || o.DefaultValue == nil {
+		return utility * o.weight(agent)
+	}
+	return o.DefaultValue(agent, bundle) * o.weight(agent)
+}
+
+// weight is the weight of agent's utility.
+func (o Options) weight(agent int) float64 {
+	if agent < len(o.Weights) {
+		return o.Weights[agent]
+	}
+	return 1
+}
+
+// eligible reports whether agent may receive item. Agent 0 may receive
+// every item.
+func (o Options) eligible(agent, item int) bool {
+	mask, ok := o.Eligibility[agent]
+	return !ok || agent == Unassigned || (item < MaxFlagItems && mask&(1<<uint(item)) != 0)
+}
+
+// eligibleItems returns the Bid flags of the items agent may receive.
+func (o Options) eligibleItems(agent int) int64 {
+	if mask, ok := o.Eligibility[agent]; ok && agent != Unassigned {
+		return mask
+	}
+	return -1
+}
+
+// ranks returns the rank of every agent 0..n under TieBreak, indexed by
+// agent, or nil to rank agents by number.
+func (o Options) ranks(n int) (rank []int) {
+	if len(o.TieBreak) == 0 {
+		return nil
+	}
+	rank = make([]int, n+1)
+	for agent := range rank {
+		rank[agent] = len(o.TieBreak) + agent
+	}
+	for i := len(o.TieBreak) - 1; i >= 0; i-- {
+		if agent := o.TieBreak[i]; agent >= 0 && agent <= n {
+			rank[agent] = i
+		}
+	}
+	return
+}
+
+// rivals returns, indexed by agent 0..n, the other agents of the exclusive
+// groups of each agent, or nil without groups.
+func (o Options) rivals(n int) (rivals [][]int) {
+	if len(o.ExclusiveGroups) == 0 {
+		return nil
+	}
+	rivals = make([][]int, n+1)
+	for _, group := range o.ExclusiveGroups {
+		for _, agent := range group {
+			if agent <= Unassigned || agent > n {
+				continue
+			}
+			for _, rival := range group {
+				if rival != agent && rival > Unassigned && rival <= n {
+					rivals[agent] = append(rivals[agent], rival)
+				}
+			}
+		}
+	}
+	return
+}
+
+// groupMasks returns, indexed by agent 0..n, the exclusive groups of each
+// agent as bits, or nil without groups. It panics with more than 64 groups.
+func (o Options) groupMasks(n int) (masks []uint64) {
+	if len(o.ExclusiveGroups) == 0 {
+		return nil
+	}
+	if len(o.ExclusiveGroups) > 64 {
+		panic(fmt.Sprintf("vcg: %d exclusive groups, at most 64 supported with Memoize", len(o.ExclusiveGroups)))
+	}
+	masks = make([]uint64, n+1)
+	for i, group := range o.ExclusiveGroups {
+		for _, agent := range group {
+			if agent > Unassigned && agent <= n {
+				masks[agent] |= 1 << uint(i)
+			}
+		}
+	}
+	return
+}
+
+// validateOrder checks that ItemOrder, if set, is a permutation of 0..m-1.
+func (o Options) validateOrder(m int) error {
+	if o.ItemOrder == nil {
+		return nil
+	}
+	if len(o.ItemOrder) != m {
+		return fmt.Errorf("item order lists %d items, want %d", len(o.ItemOrder), m)
+	}
+	seen := make([]bool, m)
+	for _, item := range o.ItemOrder {
+		if item < 0 || item >= m || seen[item] {
+			return fmt.Errorf("item order %v is not a permutation of 0..%d", o.ItemOrder, m-1)
+		}
+		seen[item] = true
+	}
+	return nil
+}
+
+// parallel reports whether the (n+1)^m allocations of n agents and m items
+// reach ParallelThreshold.
+func (o Options) parallel(n, m int) bool {
+	threshold := o.ParallelThreshold
+	if threshold <= 0 {
+		threshold = defaultParallelThreshold
+	}
+	return allocations(int64(n+1), m) >= threshold
+}
+
+// Workers returns the number of goroutines the exhaustive search of n
+// agents and m items runs in with these options, 1 when it is sequential.
+func (o Options) Workers(n, m int) int {
+	if o.Sequential || o.Deterministic || !o.parallel(n, m) || m <= parallelSplitItem {
+		return 1
+	}
+	if o.Parallelism > 0 {
+		return o.Parallelism
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// limitsSizes reports whether any bundle size limit is set.
+func (o Options) limitsSizes() bool {
+	return o.MinBundleSize > 0 || o.MaxBundleSize > 0 || len(o.BundleSizes) > 0
+}
+
+// sizeLimit returns the fewest and most items agent may receive, max being
+// -1 without a limit.
+func (o Options) sizeLimit(agent int) (min, max int) {
+	limit := SizeLimit{o.MinBundleSize, o.MaxBundleSize}
+	if l, ok := o.BundleSizes[agent]; ok {
+		limit = l
+	}
+	if limit.Max <= 0 {
+		return limit.Min, -1
+	}
+	return limit.Min, limit.Max
+}
+
+// fitsSize reports whether bundle respects the size limits of agent.
+func (o Options) fitsSize(agent int, bundle int64) bool {
+	min, max := o.sizeLimit(agent)
+	size := bundleSize(bundle)
+	return size >= min && (max < 0 || size <= max)
+}
+
+// bundleSize is the number of items of bundle.
+func bundleSize(bundle int64) (size int) {
+	for ; bundle != 0; bundle &= bundle - 1 {
+		size++
+	}
+	return
+}
+
+// utility is the total utility of allocation a, including reserves.
+func (o Options) utility(a Allocation, bs BidSet) float64 {
+	return o.utilityExceptAgent(a, bs, 0)
+}
+
+// utilityExceptAgent is the utility of everyone but excluded_agent, the
+// seller's reserves included. Agents are summed in order, so equal
+// allocations always have bit-for-bit equal utility.
+func (o Options) utilityExceptAgent(a Allocation, bs BidSet, excluded_agent int) (u float64) {
+	flags := make([]int64, len(a))
+	for agent := range flags {
+		flags[agent] = a.Flags(agent)
+	}
+	return o.utilityOfFlags(flags, bs, excluded_agent)
+}
+
+// utilityOfFlags is utilityExceptAgent for the allocation giving each agent
+// the items of flags, indexed by agent.
+func (o Options) utilityOfFlags(flags []int64, bs BidSet, excluded_agent int) (u float64) {
+	for agent := 1; agent < len(flags); agent++ {
+		if agent != excluded_agent {
+			u += o.value(bs, agent, flags[agent])
+		}
+	}
+	return u + reserveValue(o.ReservePrices, flags[Unassigned]) + sellerValue(bs, flags[Unassigned])
+}
@@ -0,0 +1,344 @@
+package vcg
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestDefaultValue checks the DefaultValue hook is asked for the bundles
+// an agent did not bid on, never for the listed ones, and that its
+// utilities count like bids.
+func TestDefaultValue(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 1}, Bid{0x2: 1}}
+	asked := make(map[int]map[int64]bool)
+	var mu sync.Mutex
+	opts := Options{
+		DefaultValue: func(agent int, bundle int64) float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			if asked[agent] == nil {
+				asked[agent] = make(map[int64]bool)
+			}
+			asked[agent][bundle] = true
+			if agent == 1 && bundle == 0x3 {
+				return 3
+			}
+			return 0
+		},
+	}
+
+	s := SolveAllocationWithOptions(bs, 2, 2, opts)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{1, 1}) || s.TotalUtility != 3 {
+		t.Errorf("items owned by %v with utility %v, want [1 1] with utility 3", got, s.TotalUtility)
+	}
+	if !asked[1][0x3] || !asked[2][0x3] || !asked[1][0x2] {
+		t.Errorf("hook not asked for every absent bundle: %v", asked)
+	}
+	if asked[1][0x1] || asked[2][0x2] {
+		t.Errorf("hook asked for a listed bundle: %v", asked)
+	}
+
+	// without the hook, absent bundles are worth 0
+	s = SolveAllocation(bs, 2, 2)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{1, 2}) || s.TotalUtility != 2 {
+		t.Errorf("without hook: items owned by %v with utility %v", got, s.TotalUtility)
+	}
+}
+
+// TestLoggerSilentByDefault captures standard output, standard error and
+// the standard logger while solving and pricing, which must print nothing
+// without Options.Logger, and logs through the Logger when set.
+func TestLoggerSilentByDefault(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 2, 0x3: 3}, Bid{0x2: 2}}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	s := SolveAllocation(bs, 2, 2)
+	perr := s.CalculatePrices(bs, 2, 2)
+	os.Stdout, os.Stderr = stdout, stderr
+	log.SetOutput(os.Stderr)
+	w.Close()
+	printed, _ := ioutil.ReadAll(r)
+	r.Close()
+
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	if len(printed) != 0 || std.Len() != 0 {
+		t.Errorf("printed %q and logged %q at the default level", printed, std.String())
+	}
+
+	var buf bytes.Buffer
+	opts := Options{Logger: log.New(&buf, "", 0)}
+	s = SolveAllocationWithOptions(bs, 2, 2, opts)
+	if err := s.CalculatePricesWithOptions(bs, 2, 2, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Agent 2") {
+		t.Errorf("Logger received %q, want a line for pricing agent 2", buf.String())
+	}
+}
+
+// TestEligibility bars agent 2 from item 1, which it values most. Pricing
+// must respect the constraint too: without agent 1, agent 2 still cannot
+// take item 1, so agent 1 pays nothing.
+func TestEligibility(t *testing.T) {
+	bs := BidSet{nil, Bid{0x2: 3}, Bid{0x2: 5, 0x1: 2}, Bid{0x1: 1}}
+	eligibility := map[int]int64{2: 0x1}
+	for _, opts := range []Options{
+		{Eligibility: eligibility},
+		{Eligibility: eligibility, Memoize: true},
+		{Eligibility: eligibility, Prune: true},
+		{Eligibility: eligibility, Parallelism: 2, ParallelThreshold: 1},
+	} {
+		s := SolveAllocationWithOptions(bs, 3, 2, opts)
+		if s.Allocation[2][1] {
+			t.Errorf("%+v: agent 2 receives item 1 in %v", opts, s.Allocation)
+		}
+		if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{2, 1}) || s.TotalUtility != 5 {
+			t.Errorf("%+v: items owned by %v with utility %v, want [2 1] with utility 5", opts, got, s.TotalUtility)
+		}
+		if err := s.CalculatePricesWithOptions(bs, 3, 2, opts); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[int]float64{1: 0, 2: 1, 3: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+			t.Errorf("%+v: prices %v, want %v", opts, s.PricePerAgent, want)
+		}
+	}
+}
+
+// TestWeights doubles the weight of agent 2, whose bid of 3 on both items
+// then beats agent 1's 5. Agent 2 pays the 5 it displaces divided by its
+// weight.
+func TestWeights(t *testing.T) {
+	bs := BidSet{nil, Bid{0x3: 5}, Bid{0x3: 3}}
+	for _, weights := range [][]float64{nil, {0, 1, 1}, {0, 1}} {
+		opts := Options{Weights: weights}
+		s := SolveAllocationWithOptions(bs, 2, 2, opts)
+		if err := s.CalculatePricesWithOptions(bs, 2, 2, opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{1, 1}) || s.PricePerAgent[1] != 3 {
+			t.Errorf("weights %v: got %v with prices %v, want [1 1] paying 3", weights, got, s.PricePerAgent)
+		}
+	}
+
+	for _, opts := range []Options{{Weights: []float64{0, 1, 2}}, {Weights: []float64{0, 1, 2}, Memoize: true}} {
+		s := SolveAllocationWithOptions(bs, 2, 2, opts)
+		if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{2, 2}) || s.TotalUtility != 6 {
+			t.Errorf("%+v: got %v with weighted utility %v, want [2 2] with 6", opts, got, s.TotalUtility)
+		}
+		if err := s.CalculatePricesWithOptions(bs, 2, 2, opts); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[int]float64{1: 0, 2: 2.5}; !reflect.DeepEqual(s.PricePerAgent, want) {
+			t.Errorf("%+v: prices %v, want %v", opts, s.PricePerAgent, want)
+		}
+	}
+}
+
+// TestDeterministic solves and prices an instance with many ties 50 times
+// with Options.Deterministic, asking for parallelism too, and checks every
+// Solution is identical.
+func TestDeterministic(t *testing.T) {
+	bs := GenerateBidSet(GenOptions{Agents: 4, Items: 6, Seed: 3})
+	// ties between agents 1 and 2 on every bundle
+	for flags, value := range bs[1] {
+		bs[2][flags] = value
+	}
+	opts := Options{Deterministic: true, Parallelism: 4, ParallelThreshold: 1}
+	var first Solution
+	for run := 0; run < 50; run++ {
+		s := SolveAllocationWithOptions(bs, 4, 6, opts)
+		if err := s.CalculatePricesWithOptions(bs, 4, 6, opts); err != nil {
+			t.Fatal(err)
+		}
+		if run == 0 {
+			first = s
+		} else if !reflect.DeepEqual(s, first) {
+			t.Fatalf("run %d: got %+v, want %+v", run, s, first)
+		}
+	}
+	if !first.Optimal {
+		t.Errorf("got %+v, want an optimal solution", first)
+	}
+}
+
+// TestDeterministicTieBreak checks Options.Deterministic keeps the order of
+// Options.TieBreak in the exhaustive search, and leaves Memoize, which
+// ignores it, as it is.
+func TestDeterministicTieBreak(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 1}, Bid{0x1: 1}}
+	for _, test := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{TieBreak: []int{1}}, "agent1:{item0}"},
+		{Options{TieBreak: []int{2}}, "agent2:{item0}"},
+		// Memoize lets the last agent take the item on a tie
+		{Options{TieBreak: []int{1}, Memoize: true}, "agent2:{item0}"},
+	} {
+		test.opts.Deterministic = true
+		if got := SolveAllocationWithOptions(bs, 2, 1, test.opts).Allocation.String(); got != test.want {
+			t.Errorf("%+v: got %s, want %s", test.opts, got, test.want)
+		}
+	}
+}
+
+// TestMaxBundleSize checks that with a MaxBundleSize of 2 agent 1 no longer
+// wins all three items it values most together, and that on random
+// instances the limited optimum is the best allocation respecting it.
+func TestMaxBundleSize(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x7: 10, 0x3: 4, 0x1: 1}, Bid{0x4: 1, 0x1: 1.5}}
+	s := SolveAllocationWithOptions(bs, 2, 3, Options{MaxBundleSize: 2})
+	if got := s.Allocation.String(); got != "agent1:{item0,item1} agent2:{item2}" || s.TotalUtility != 5 {
+		t.Errorf("got %s worth %v, want agent1:{item0,item1} agent2:{item2} worth 5", got, s.TotalUtility)
+	}
+	// without agent 2, agent 1 still wins only two items
+	if err := s.CalculatePricesWithOptions(bs, 2, 3, Options{MaxBundleSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 0.5, 2: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("got prices %v, want %v", s.PricePerAgent, want)
+	}
+
+	for seed := int64(0); seed < 30; seed++ {
+		const n, m = 3, 5
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: seed})
+		best := math.Inf(-1)
+		for c := range EnumerateAllocations(bs, n, m) {
+			fits := true
+			for agent := 1; agent <= n; agent++ {
+				fits = fits && len(c.Allocation[agent]) <= 2
+			}
+			if fits && c.Utility > best {
+				best = c.Utility
+			}
+		}
+		for _, opts := range []Options{{MaxBundleSize: 2}, {MaxBundleSize: 2, Prune: true}, {MaxBundleSize: 2, Memoize: true}} {
+			s := SolveAllocationWithOptions(bs, n, m, opts)
+			for agent := 1; agent <= n; agent++ {
+				if len(s.Allocation[agent]) > 2 {
+					t.Errorf("seed %d, %+v: agent %d receives %d items", seed, opts, agent, len(s.Allocation[agent]))
+				}
+			}
+			if math.Abs(s.TotalUtility-best) > priceTolerance {
+				t.Errorf("seed %d, %+v: got utility %v, want %v", seed, opts, s.TotalUtility, best)
+			}
+		}
+	}
+}
+
+func TestBundleSizes(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 3, 0x3: 4}, Bid{0x1: 2, 0x2: 2}}
+	opts := Options{MinBundleSize: 1, BundleSizes: map[int]SizeLimit{1: {Max: 1}}}
+	s := SolveAllocationWithOptions(bs, 2, 2, opts)
+	if got := s.Allocation.String(); got != "agent1:{item0} agent2:{item1}" {
+		t.Errorf("got %s", got)
+	}
+	// every agent must win an item, but there is only one
+	s = SolveAllocationWithOptions(BidSet{Bid{}, Bid{0x1: 1}, Bid{0x1: 2}}, 2, 1, Options{MinBundleSize: 1})
+	if s.Allocation != nil {
+		t.Errorf("got %s, want no allocation", s.Allocation)
+	}
+}
+
+// TestExclusiveGroups puts agents 1 and 2, who want different items, in a
+// group, so only one of them may win: the better one, agent 1, does, and
+// item 1 goes to agent 3 instead.
+func TestExclusiveGroups(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 5}, Bid{0x2: 4}, Bid{0x2: 1}}
+	if got := SolveAllocation(bs, 3, 2).Allocation.String(); got != "agent1:{item0} agent2:{item1}" {
+		t.Fatalf("without groups got %s", got)
+	}
+	opts := Options{ExclusiveGroups: [][]int{{1, 2}}}
+	for _, extra := range []Options{{}, {Sequential: true}, {Prune: true}, {Memoize: true}, {Parallelism: 2, ParallelThreshold: 1}} {
+		extra.ExclusiveGroups = opts.ExclusiveGroups
+		s := SolveAllocationWithOptions(bs, 3, 2, extra)
+		if got := s.Allocation.String(); got != "agent1:{item0} agent3:{item1}" || s.TotalUtility != 6 {
+			t.Errorf("%+v: got %s worth %v, want agent1:{item0} agent3:{item1} worth 6", extra, got, s.TotalUtility)
+		}
+	}
+
+	// without agent 1, agent 2 wins item 1 for 4
+	s := SolveAllocationWithOptions(bs, 3, 2, opts)
+	if err := s.CalculatePricesWithOptions(bs, 3, 2, opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 3, 2: 0, 3: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("got prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+// TestItemOrder searches random instances with pruning in increasing and
+// in reverse item order: the optimum must not change, but the number of
+// allocations evaluated does for some of them.
+func TestItemOrder(t *testing.T) {
+	differ := 0
+	for seed := int64(0); seed < 20; seed++ {
+		const n, m = 3, 6
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: seed})
+		increasing := SolveAllocationWithOptions(bs, n, m, Options{Prune: true, Sequential: true, ItemOrder: []int{0, 1, 2, 3, 4, 5}})
+		reverse := SolveAllocationWithOptions(bs, n, m, Options{Prune: true, Sequential: true, ItemOrder: []int{5, 4, 3, 2, 1, 0}})
+		if reverse.Allocation.String() != increasing.Allocation.String() || reverse.TotalUtility != increasing.TotalUtility {
+			t.Errorf("seed %d: reverse order found %s worth %v, increasing %s worth %v", seed, reverse.Allocation, reverse.TotalUtility, increasing.Allocation, increasing.TotalUtility)
+		}
+		if reverse.Evaluated != increasing.Evaluated {
+			differ++
+		}
+	}
+	if differ == 0 {
+		t.Error("the order never changed the number of allocations evaluated")
+	}
+
+	for _, order := range [][]int{{0, 1, 2}, {0, 1, 1, 2}, {0, 1, 2, 4}} {
+		_, err := SolveAllocationContextWithOptions(context.Background(), problem1Bids(), 4, 4, Options{ItemOrder: order})
+		if err == nil {
+			t.Errorf("order %v: no error", order)
+		}
+	}
+}
+
+// TestRequireSale checks that with all bids negative the solver leaves
+// everything unsold unless RequireSale is set, and then sells the least bad
+// item. Without agent 1, agent 2 must buy item 0 for -2, so agent 1 is paid 2.
+func TestRequireSale(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: -1, 0x2: -5, 0x3: -4.5}, Bid{0x1: -2, 0x2: -3, 0x3: -6}}
+	s := SolveAllocation(bs, 2, 2)
+	if got := s.Allocation.String(); got != "unsold:{item0,item1}" || s.TotalUtility != 0 {
+		t.Errorf("got %s worth %v, want nothing sold", got, s.TotalUtility)
+	}
+	for _, opts := range []Options{{RequireSale: true}, {RequireSale: true, Sequential: true}, {RequireSale: true, Prune: true}, {RequireSale: true, Memoize: true}, {RequireSale: true, Iterative: true}} {
+		s := SolveAllocationWithOptions(bs, 2, 2, opts)
+		if got := s.Allocation.String(); got != "agent1:{item0} unsold:{item1}" || s.TotalUtility != -1 {
+			t.Errorf("%+v: got %s worth %v, want agent1:{item0} unsold:{item1} worth -1", opts, got, s.TotalUtility)
+		}
+		if err := s.CalculatePricesWithOptions(bs, 2, 2, opts); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[int]float64{1: -2, 2: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+			t.Errorf("%+v: prices %v, want %v", opts, s.PricePerAgent, want)
+		}
+	}
+
+	// a single item goes to the less negative bid
+	bs = BidSet{Bid{}, Bid{0x1: -3}, Bid{0x1: -2}}
+	s = SolveAllocationWithOptions(bs, 2, 1, Options{RequireSale: true})
+	if got := s.Allocation.String(); got != "agent2:{item0}" || s.TotalUtility != -2 {
+		t.Errorf("got %s worth %v, want agent2:{item0} worth -2", got, s.TotalUtility)
+	}
+}
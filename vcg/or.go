@@ -0,0 +1,89 @@
+package vcg
+
+import (
+	"context"
+)
+
+// ORBid is a bid in the OR language (mapping of atom bundle => utility).
+// Unlike Bid, an agent may win any number of its atoms as long as they do not
+// overlap, so its utility for a bundle is the best total utility of disjoint
+// atoms within it.
+type ORBid map[int64]float64
+
+// Contains OR bids for all agents (1..n)
+type ORBidSet []ORBid
+
+// ValueOf returns the utility of bundle: the maximum-weight packing of atoms
+// within it.
+func (b ORBid) ValueOf(bundle int64) float64 {
+	return b.pack(bundle, make(map[int64]float64))
+}
+
+// pack finds the best packing of bundle by either leaving its lowest item
+// unused or covering it with one of the atoms holding it.
+func (b ORBid) pack(bundle int64, memo map[int64]float64) (u float64) {
+	if bundle == 0 {
+		return 0
+	}
+	if u, ok := memo[bundle]; ok {
+		return u
+	}
+	lowest := bundle & -bundle
+	u = b.pack(bundle&^lowest, memo)
+	for atom, utility := range b {
+		if atom&lowest != 0 && atom&^bundle == 0 {
+			if v := utility + b.pack(bundle&^atom, memo); v > u {
+				u = v
+			}
+		}
+	}
+	memo[bundle] = u
+	return
+}
+
+// XOR returns the equivalent XOR bid over m items, listing the value of
+// every bundle worth something. It holds 2^m bundles at most.
+func (b ORBid) XOR(m int) (x Bid) {
+	x = make(Bid)
+	memo := make(map[int64]float64)
+	for bundle := int64(0); bundle <= allItems(m); bundle++ {
+		if u := b.pack(bundle, memo); u != 0 {
+			x[bundle] = u
+		}
+	}
+	return
+}
+
+// XOR converts all OR bids into equivalent XOR bids over m items, so they can
+// be solved and priced like any BidSet.
+func (bs ORBidSet) XOR(m int) (x BidSet) {
+	x = make(BidSet, len(bs))
+	for agent, bid := range bs {
+		if bid != nil {
+			x[agent] = bid.XOR(m)
+		}
+	}
+	return
+}
+
+func (a Allocation) FindTotalUtilityOR(bs ORBidSet) (u float64) {
+	for agent := range a {
+		if agent != Unassigned {
+			u += bs[agent].ValueOf(a.Flags(agent))
+		}
+	}
+	return
+}
+
+// SolveAllocationOR is SolveAllocation for OR bids, valuing each agent's
+// items by the best packing of its atoms. To price the solution, use
+// CalculatePrices with bs.XOR(m).
+func SolveAllocationOR(bs ORBidSet, n, m int) (s Solution) {
+	s, _ = solve(context.Background(), n, m, 0, Options{}, func(a Allocation, flags []int64) (u float64) {
+		for agent := 1; agent < len(flags); agent++ {
+			u += bs[agent].ValueOf(flags[agent])
+		}
+		return
+	}, nil)
+	return
+}
@@ -0,0 +1,47 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestORBidValueOf(t *testing.T) {
+	b := ORBid{0x1: 5, 0x2: 3, 0x3: 7, 0x6: 4}
+	for bundle, want := range map[int64]float64{0x0: 0, 0x1: 5, 0x3: 8, 0x6: 4, 0x7: 9, 0x4: 0} {
+		if got := b.ValueOf(bundle); got != want {
+			t.Errorf("ValueOf(%b) = %v, want %v", bundle, got, want)
+		}
+	}
+}
+
+// TestORDivergesFromXOR solves the same bids as XOR and as OR bids. Agent 1
+// wants item 0 for 5 and item 1 for 3, agent 2 both for 7: as XOR bids agent
+// 1 wins one item at most and agent 2 wins, as OR bids agent 1 wins both.
+func TestORDivergesFromXOR(t *testing.T) {
+	xor := SolveAllocation(BidSet{nil, Bid{0x1: 5, 0x2: 3}, Bid{0x3: 7}}, 2, 2)
+	if got := xor.Allocation.owners(); !reflect.DeepEqual(got, []int{2, 2}) || xor.TotalUtility != 7 {
+		t.Errorf("XOR: items owned by %v with utility %v, want [2 2] with utility 7", got, xor.TotalUtility)
+	}
+
+	or := ORBidSet{nil, ORBid{0x1: 5, 0x2: 3}, ORBid{0x3: 7}}
+	s := SolveAllocationOR(or, 2, 2)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{1, 1}) || s.TotalUtility != 8 {
+		t.Errorf("OR: items owned by %v with utility %v, want [1 1] with utility 8", got, s.TotalUtility)
+	}
+	if u := s.Allocation.FindTotalUtilityOR(or); u != 8 {
+		t.Errorf("FindTotalUtilityOR = %v, want 8", u)
+	}
+
+	// the XOR equivalent of the OR bids solves and prices the same way
+	x := or.XOR(2)
+	want := SolveAllocation(x, 2, 2)
+	if want.TotalUtility != s.TotalUtility || !reflect.DeepEqual(want.Allocation.owners(), s.Allocation.owners()) {
+		t.Errorf("XOR equivalent: got %v with utility %v", want.Allocation, want.TotalUtility)
+	}
+	if err := s.CalculatePrices(x, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if s.PricePerAgent[1] != 7 || s.PricePerAgent[2] != 0 {
+		t.Errorf("prices %v, want 7 and 0", s.PricePerAgent)
+	}
+}
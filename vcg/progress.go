@@ -0,0 +1,74 @@
+package vcg
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// progressInterval is the number of allocations between two reports to
+// Options.OnProgress.
+const progressInterval = 1 << 16
+
+// progress counts the complete allocations a search has dealt with, either
+// by evaluating them or by pruning the subtree holding them.
+type progress struct {
+	report  func(visited, total int64)
+	visited int64 // updated atomically
+	total   int64
+	choices int64 // agents which may receive an item
+}
+
+// newProgress returns nil when there is nobody to report to.
+func newProgress(report func(visited, total int64), choices int64, m int) *progress {
+	if report == nil {
+		return nil
+	}
+	p := &progress{report: report, choices: choices}
+	p.total = p.leaves(m)
+	return p
+}
+
+// leaves returns the number of allocations of items more items.
+func (p *progress) leaves(items int) int64 {
+	return allocations(p.choices, items)
+}
+
+// allocations returns choices^items, the number of ways to give each of
+// items items to one of choices agents, at most math.MaxInt64.
+func allocations(choices int64, items int) (l int64) {
+	l = 1
+	for i := 0; i < items; i++ {
+		if choices > 0 && l > math.MaxInt64/choices {
+			return math.MaxInt64
+		}
+		l *= choices
+	}
+	return
+}
+
+// add counts l more allocations and reports whenever another
+// progressInterval of them are done.
+func (p *progress) add(l int64) {
+	if p == nil {
+		return
+	}
+	visited := atomic.AddInt64(&p.visited, l)
+	if visited/progressInterval != (visited-l)/progressInterval {
+		p.report(visited, p.total)
+	}
+}
+
+// skip counts the allocations of a pruned subtree with items unallocated
+// items.
+func (p *progress) skip(items int) {
+	if p != nil {
+		p.add(p.leaves(items))
+	}
+}
+
+// done reports the end of a complete search.
+func (p *progress) done() {
+	if p != nil {
+		p.report(p.total, p.total)
+	}
+}
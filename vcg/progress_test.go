@@ -0,0 +1,37 @@
+package vcg
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestOnProgress solves 5^8 allocations, reported every 2^16, sequentially,
+// in parallel and with pruning, which skips subtrees but counts them.
+func TestOnProgress(t *testing.T) {
+	const n, m = 4, 8
+	const total = 390625
+	bs := randomBidSet(n, m, 1)
+	for _, opts := range []Options{{Sequential: true}, {Parallelism: 4, ParallelThreshold: 1}, {Sequential: true, Prune: true}} {
+		var mu sync.Mutex
+		var reports []int64
+		opts.OnProgress = func(visited, estimated int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			if estimated != total {
+				t.Errorf("%+v: estimated %d allocations, want %d", opts, estimated, total)
+			}
+			if visited > estimated {
+				t.Errorf("%+v: visited %d of %d allocations", opts, visited, estimated)
+			}
+			reports = append(reports, visited)
+		}
+		SolveAllocationWithOptions(bs, n, m, opts)
+
+		if !opts.Prune && len(reports) < total/progressInterval {
+			t.Errorf("%+v: %d reports, want at least %d", opts, len(reports), total/progressInterval)
+		}
+		if len(reports) == 0 || reports[len(reports)-1] != total {
+			t.Errorf("%+v: last reports %v, want the search to end at %d", opts, reports, total)
+		}
+	}
+}
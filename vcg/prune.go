@@ -0,0 +1,92 @@
+package vcg
+
+import (
+	"math"
+	"sort"
+)
+
+// maxBoundItems limits the size of the tables built by newBound,
+// which hold 2^m values per agent.
+const maxBoundItems = 20
+
+// contestedOrder orders the m items by decreasing range of their values
+// alone to everyone who may hold them: the single-item bids of agents 1..n
+// and the item's reserve price for agent 0. Ties stay in increasing order of
+// items. Deciding the items whose holder matters most first tends to find
+// good allocations early, so pruning cuts more branches.
+func contestedOrder(bs BidSet, n, m int, opts Options) (order []int) {
+	spread := make([]float64, m)
+	for item := 0; item < m; item++ {
+		var reserve float64
+		if item < len(opts.ReservePrices) {
+			reserve = opts.ReservePrices[item]
+		}
+		low, high := reserve, reserve
+		for agent := 1; agent <= n; agent++ {
+			v := opts.value(bs, agent, 1<<uint(item))
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+		spread[item] = high - low
+		order = append(order, item)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return spread[order[i]] > spread[order[j]]
+	})
+	return
+}
+
+// newBound returns a bounder for branch-and-bound pruning, or nil when the
+// instance has too many items to tabulate.
+//
+// For every agent it tabulates the highest bid (but at least 0) on any bundle
+// within each set of items. Whatever an agent ends up with lies within the
+// items it already holds plus the items not allocated yet, so the sum of
+// these per-agent maxima, plus the best the seller can get from reserves and
+// its own bid (which never decreases when it keeps more items), is
+// never below the utility of a complete allocation. With a DefaultValue every
+// bundle is tabulated, otherwise only the listed ones.
+func newBound(bs BidSet, n, m int, opts Options) bounder {
+	if m > maxBoundItems {
+		return nil
+	}
+	full := int64(1)<<uint(m) - 1
+	tables := make([][]float64, n+1)
+	for agent := 1; agent <= n; agent++ {
+		t := make([]float64, full+1)
+		if opts.DefaultValue != nil {
+			for flags := int64(0); flags <= full; flags++ {
+				if utility := opts.value(bs, agent, flags); utility > t[flags] {
+					t[flags] = utility
+				}
+			}
+		} else {
+			for flags, utility := range bs[agent] {
+				if flags&^full == 0 && utility*opts.weight(agent) > t[flags] {
+					t[flags] = utility * opts.weight(agent)
+				}
+			}
+		}
+		for item := uint(0); item < uint(m); item++ {
+			for mask := int64(0); mask <= full; mask++ {
+				if mask&(1<<item) != 0 && t[mask&^(1<<item)] > t[mask] {
+					t[mask] = t[mask&^(1<<item)]
+				}
+			}
+		}
+		tables[agent] = t
+	}
+
+	return func(a Allocation, flags []int64, remaining int64) (u float64) {
+		u = reserveValue(opts.ReservePrices, flags[Unassigned])
+		for item := 0; item < m && item < len(opts.ReservePrices); item++ {
+			if remaining&(1<<uint(item)) != 0 && opts.ReservePrices[item] > 0 {
+				u += opts.ReservePrices[item]
+			}
+		}
+		u += sellerValue(bs, flags[Unassigned]|remaining)
+		for agent := 1; agent <= n; agent++ {
+			u += tables[agent][flags[agent]|remaining]
+		}
+		return
+	}
+}
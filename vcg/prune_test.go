@@ -0,0 +1,114 @@
+package vcg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestPruneMatchesExhaustive solves 200 random instances with and without
+// Options.Prune, some sparse, some with negative bids or reserve prices, and
+// checks pruning never changes the optimal utility.
+func TestPruneMatchesExhaustive(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		n, m := 1+int(seed%4), 1+int(seed%7)
+		bs := randomBidSet(n, m, seed)
+		if seed%3 != 0 {
+			for agent := 1; agent <= n; agent++ {
+				for bundle := range bs[agent] {
+					if bundle%int64(seed%3+2) == 0 {
+						delete(bs[agent], bundle)
+					}
+				}
+			}
+		}
+		var opts Options
+		if seed%5 == 1 {
+			bs[1][1] = -1
+		}
+		if seed%5 == 2 {
+			opts.ReservePrices = make([]float64, m)
+			for item := range opts.ReservePrices {
+				opts.ReservePrices[item] = float64(item%3) * 0.5
+			}
+		}
+
+		opts.Sequential = true
+		want := SolveAllocationWithOptions(bs, n, m, opts)
+		opts.Prune = true
+		got := SolveAllocationWithOptions(bs, n, m, opts)
+
+		if math.Abs(got.TotalUtility-want.TotalUtility) > 1e-9 {
+			t.Errorf("seed %d (n = %d, m = %d): pruned utility %v, unpruned %v", seed, n, m, got.TotalUtility, want.TotalUtility)
+		}
+		held := 0
+		for agent := range got.Allocation {
+			held += len(got.Allocation[agent])
+		}
+		if held != m {
+			t.Errorf("seed %d: pruned allocation holds %d of %d items", seed, held, m)
+		}
+		if got.Evaluated > want.Evaluated {
+			t.Errorf("seed %d: pruned search evaluated %d allocations, more than %d", seed, got.Evaluated, want.Evaluated)
+		}
+	}
+}
+
+// TestContestedOrder checks items are ordered by the range of their
+// single-item bids and reserve, the seller's reserve of 0 included.
+func TestContestedOrder(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 1, 0x2: 5, 0x4: 3, 0x8: 2}, Bid{0x1: 4, 0x2: 5, 0x4: 1}}
+	// ranges 4, 5, 3 and 2
+	if got := contestedOrder(bs, 2, 4, Options{}); !reflect.DeepEqual(got, []int{1, 0, 2, 3}) {
+		t.Errorf("got %v", got)
+	}
+	// ranges 4, 1, 3 and 2
+	if got := contestedOrder(bs, 2, 4, Options{ReservePrices: []float64{0, 4}}); !reflect.DeepEqual(got, []int{0, 2, 3, 1}) {
+		t.Errorf("with reserves got %v", got)
+	}
+}
+
+// TestContestedOrderEvaluatesFewer checks pruning evaluates fewer
+// allocations in the default order than in increasing order of items,
+// summed over random instances.
+func TestContestedOrderEvaluatesFewer(t *testing.T) {
+	var index, contested int64
+	for seed := int64(0); seed < 60; seed++ {
+		n, m := 3+int(seed%3), 6+int(seed%3)
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Distribution: Distribution(seed % 6), Sparsity: float64(seed%2) * 0.5, Seed: seed})
+		order := make([]int, m)
+		for item := range order {
+			order[item] = item
+		}
+		index += SolveAllocationWithOptions(bs, n, m, Options{Prune: true, Sequential: true, ItemOrder: order}).Evaluated
+		contested += SolveAllocationWithOptions(bs, n, m, Options{Prune: true, Sequential: true}).Evaluated
+	}
+	t.Logf("evaluated %d allocations in increasing order, %d in the default order", index, contested)
+	if contested >= index {
+		t.Errorf("the default order evaluated %d allocations, increasing order %d", contested, index)
+	}
+}
+
+// BenchmarkContestedOrder prunes random instances with items in increasing
+// order and in the default order of contestedOrder.
+func BenchmarkContestedOrder(b *testing.B) {
+	var instances []BidSet
+	for seed := int64(0); seed < 12; seed++ {
+		instances = append(instances, GenerateBidSet(GenOptions{Agents: 4, Items: 7, Distribution: Distribution(seed % 6), Sparsity: float64(seed%2) * 0.5, Seed: seed}))
+	}
+	for _, bc := range []struct {
+		name  string
+		order []int
+	}{
+		{"index", []int{0, 1, 2, 3, 4, 5, 6}},
+		{"contested", nil},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, bs := range instances {
+					SolveAllocationWithOptions(bs, 4, 7, Options{Prune: true, Sequential: true, ItemOrder: bc.order})
+				}
+			}
+		})
+	}
+}
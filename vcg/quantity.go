@@ -0,0 +1,77 @@
+package vcg
+
+import (
+	"fmt"
+)
+
+// QuantityBid is an agent's bid on a divisible item, mapping a number of
+// units to its utility. It is a step function: any larger number of units is
+// worth at least as much, and fewer units than the smallest step are worth 0.
+type QuantityBid map[int]float64
+
+// ValueOf returns the utility of units of the item.
+func (b QuantityBid) ValueOf(units int) (u float64) {
+	for quantity, utility := range b {
+		if quantity <= units && utility > u {
+			u = utility
+		}
+	}
+	return
+}
+
+// QuantityBidSet holds the bids of all agents (1..n) on divisible items,
+// indexed by agent and then by item. Agents value items independently.
+type QuantityBidSet []map[int]QuantityBid
+
+// QuantitySolution is the outcome of SolveQuantities or SolveUnits.
+type QuantitySolution struct {
+	// Units is the number of units of each item every agent receives,
+	// indexed by agent and item. Unsold units are not listed.
+	Units        map[int]map[int]int
+	TotalUtility float64
+
+	// PricePerAgent is the VCG price of every agent (1..n).
+	PricePerAgent map[int]float64
+}
+
+// Valuations returns the bids of bs as valuations of the bundles of
+// capacities, see Units.Valuation: a bundle is worth the sum over the items
+// of the bid on its number of units of each. Index 0 is unused.
+func (bs QuantityBidSet) Valuations(capacities Units) (vs Valuations) {
+	vs = make(Valuations, len(bs))
+	for agent := 1; agent < len(bs); agent++ {
+		bids := bs[agent]
+		vs[agent] = capacities.Valuation(func(units []int) (u float64) {
+			for item, b := range bids {
+				u += b.ValueOf(units[item])
+			}
+			return
+		})
+	}
+	return
+}
+
+// SolveQuantities allocates capacities[item] units of every divisible item
+// to the agents, maximizing the total utility of bs, and prices the
+// allocation with VCG. It solves the Valuations of bs with SolveUnits, so
+// at most MaxFlagItems units may be allocated in total.
+func SolveQuantities(bs QuantityBidSet, capacities []int) (s QuantitySolution, err error) {
+	for item, capacity := range capacities {
+		if capacity < 0 {
+			return s, fmt.Errorf("item %d has negative capacity %d", item, capacity)
+		}
+	}
+	for agent := 1; agent < len(bs); agent++ {
+		for item, b := range bs[agent] {
+			if item < 0 || item >= len(capacities) {
+				return s, fmt.Errorf("agent %d bids on unknown item %d", agent, item)
+			}
+			for units := range b {
+				if units <= 0 {
+					return s, fmt.Errorf("agent %d bids on %d units of item %d", agent, units, item)
+				}
+			}
+		}
+	}
+	return SolveUnits(bs.Valuations(capacities), capacities)
+}
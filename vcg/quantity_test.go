@@ -0,0 +1,70 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuantityBidValueOf(t *testing.T) {
+	b := QuantityBid{4: 6, 8: 9}
+	for units, want := range map[int]float64{0: 0, 3: 0, 4: 6, 7: 6, 8: 9, 100: 9} {
+		if got := b.ValueOf(units); got != want {
+			t.Errorf("ValueOf(%d) = %v, want %v", units, got, want)
+		}
+	}
+}
+
+// TestSolveQuantitiesSplit splits 10 units of one divisible item: agent 1
+// wants 4 units for 6 or 8 for 9, agent 2 wants 6 for 7. They share it for
+// 13. Without agent 1, agent 2 still gets its 6 units, so agent 1 pays 0;
+// without agent 2, agent 1 takes 8 units for 9, so agent 2 pays 9 - 6 = 3.
+func TestSolveQuantitiesSplit(t *testing.T) {
+	bs := QuantityBidSet{nil, {0: {4: 6, 8: 9}}, {0: {6: 7}}}
+	s, err := SolveQuantities(bs, []int{10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TotalUtility != 13 {
+		t.Errorf("utility %v, want 13", s.TotalUtility)
+	}
+	if want := map[int]map[int]int{1: {0: 4}, 2: {0: 6}}; !reflect.DeepEqual(s.Units, want) {
+		t.Errorf("units %v, want %v", s.Units, want)
+	}
+	if want := map[int]float64{1: 0, 2: 3}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+// TestQuantityValuations solves the split of TestSolveQuantitiesSplit with
+// the bundle search, treating the 10 units as distinct items: the
+// valuations of the quantity bids give the same utility and prices.
+func TestQuantityValuations(t *testing.T) {
+	bs := QuantityBidSet{nil, {0: {4: 6, 8: 9}}, {0: {6: 7}}}
+	vs := bs.Valuations(Units{10})
+	s := SolveAllocationValuations(vs, 2, 10)
+	if err := s.CalculatePricesValuations(vs, 2, 10); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Allocation.FindTotalUtilityValuations(vs); s.TotalUtility != 13 || got != 13 {
+		t.Errorf("utility %v, allocation %s worth %v, want 13", s.TotalUtility, s.Allocation, got)
+	}
+	if want := map[int]float64{1: 0, 2: 3}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+func TestSolveQuantitiesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		bs         QuantityBidSet
+		capacities []int
+	}{
+		{QuantityBidSet{nil, {0: {1: 1}}}, []int{-1}},
+		{QuantityBidSet{nil, {1: {1: 1}}}, []int{5}},
+		{QuantityBidSet{nil, {0: {0: 1}}}, []int{5}},
+		{QuantityBidSet{nil, {0: {1: 1}}}, []int{40, 30}},
+	} {
+		if _, err := SolveQuantities(tt.bs, tt.capacities); err == nil {
+			t.Errorf("%v with capacities %v: no error", tt.bs, tt.capacities)
+		}
+	}
+}
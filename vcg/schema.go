@@ -0,0 +1,211 @@
+package vcg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// InputSchema is the JSON Schema of the documents read by LoadBidSet,
+// which ValidateInputJSON checks them against. It uses only the keywords
+// type, required, properties, items, minimum, maximum and $ref to
+// definitions.
+const InputSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "vcg-auction bids",
+  "type": "object",
+  "required": ["agents"],
+  "definitions": {
+    "agent": {
+      "type": "object",
+      "required": ["bids"],
+      "properties": {
+        "bids": {"type": "array", "items": {"$ref": "#/definitions/bid"}}
+      }
+    },
+    "bid": {
+      "type": "object",
+      "required": ["items", "value"],
+      "properties": {
+        "items": {"type": "array", "items": {"type": "integer", "minimum": 0, "maximum": 62}},
+        "value": {"type": "number"}
+      }
+    }
+  },
+  "properties": {
+    "items": {"type": "integer", "minimum": 0, "maximum": 63},
+    "agents": {"type": "array", "items": {"$ref": "#/definitions/agent"}},
+    "seller": {"$ref": "#/definitions/agent"}
+  }
+}`
+
+// ValidateInputJSON checks data against InputSchema, and that every item is
+// below "items" when it is given, which a schema cannot express. Syntax
+// errors are reported with their line and column, other errors with the
+// path of the offending field, like
+// `agents[1].bids[0].value: want a number, got "5"`.
+func ValidateInputJSON(data []byte) error {
+	return validateInput(data, MaxFlagItems)
+}
+
+// validateInput is ValidateInputJSON for documents of at most max_items
+// items: the bounds of InputSchema on the items are raised or lowered to
+// match.
+func validateInput(data []byte, max_items int) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		if serr, ok := err.(*json.SyntaxError); ok {
+			// Offset counts the offending byte
+			line, column := position(data, serr.Offset-1)
+			return fmt.Errorf("line %d, column %d: %s", line, column, serr)
+		}
+		return err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(InputSchema), &schema); err != nil {
+		panic("vcg: invalid InputSchema: " + err.Error())
+	}
+	definitions := schema["definitions"].(map[string]interface{})
+	bid_items := definitions["bid"].(map[string]interface{})["properties"].(map[string]interface{})["items"]
+	bid_items.(map[string]interface{})["items"].(map[string]interface{})["maximum"] = float64(max_items - 1)
+	schema["properties"].(map[string]interface{})["items"].(map[string]interface{})["maximum"] = float64(max_items)
+	if err := validateValue(schema, definitions, doc, "document"); err != nil {
+		return err
+	}
+
+	root := doc.(map[string]interface{})
+	items, ok := root["items"].(float64)
+	if !ok {
+		return nil
+	}
+	agents := root["agents"].([]interface{})
+	for i, agent := range agents {
+		if err := checkItems(agent, fmt.Sprintf("agents[%d]", i), int(items)); err != nil {
+			return err
+		}
+	}
+	if seller, ok := root["seller"]; ok {
+		return checkItems(seller, "seller", int(items))
+	}
+	return nil
+}
+
+// validateValue checks v at path against schema s, resolving $ref in
+// definitions. The keys of an object are checked in sorted order, so the
+// first error found does not change from run to run. Keys s does not name
+// are ignored, as by LoadBidSet.
+func validateValue(s, definitions map[string]interface{}, v interface{}, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		s = definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+	}
+	switch s["type"] {
+	case "object":
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError(path, "an object", v)
+		}
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, key := range required {
+				if _, ok := o[key.(string)]; !ok {
+					return fmt.Errorf("%s: missing %q", path, key)
+				}
+			}
+		}
+		properties, _ := s["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := o[key]
+			if !ok {
+				continue
+			}
+			key_path := path + "." + key
+			if path == "document" {
+				key_path = key
+			}
+			if err := validateValue(properties[key].(map[string]interface{}), definitions, value, key_path); err != nil {
+				return err
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return typeError(path, "an array", v)
+		}
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range a {
+				if err := validateValue(items, definitions, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) {
+			return typeError(path, "an integer", v)
+		}
+		return checkBounds(s, f, path)
+	case "number":
+		f, ok := v.(float64)
+		if !ok {
+			return typeError(path, "a number", v)
+		}
+		return checkBounds(s, f, path)
+	}
+	return nil
+}
+
+// checkBounds checks f at path against the minimum and maximum of schema
+// s, if any.
+func checkBounds(s map[string]interface{}, f float64, path string) error {
+	min, max := math.Inf(-1), math.Inf(1)
+	if v, ok := s["minimum"].(float64); ok {
+		min = v
+	}
+	if v, ok := s["maximum"].(float64); ok {
+		max = v
+	}
+	if f < min || f > max {
+		return fmt.Errorf("%s: %v out of range %v..%v", path, f, min, max)
+	}
+	return nil
+}
+
+// checkItems checks every item bid on by the agent at path, which matches
+// InputSchema, is below m.
+func checkItems(v interface{}, path string, m int) error {
+	for i, b := range v.(map[string]interface{})["bids"].([]interface{}) {
+		for j, item := range b.(map[string]interface{})["items"].([]interface{}) {
+			if f := item.(float64); f >= float64(m) {
+				return fmt.Errorf("%s.bids[%d].items[%d]: %v out of range 0..%d", path, i, j, f, m-1)
+			}
+		}
+	}
+	return nil
+}
+
+func typeError(path, want string, got interface{}) error {
+	text, _ := json.Marshal(got)
+	return fmt.Errorf("%s: want %s, got %s", path, want, text)
+}
+
+// position converts a byte offset of data into a line and column, both
+// starting at 1.
+func position(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = len(before) - bytes.LastIndex(before, []byte("\n"))
+	return
+}
@@ -0,0 +1,178 @@
+package vcg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestValidateInputJSON(t *testing.T) {
+	for _, test := range []struct {
+		name, doc, err string
+	}{
+		{"valid", `{"items": 3, "agents": [{"bids": [{"items": [0, 2], "value": 1.5}]}], "seller": {"bids": []}}`, ""},
+		{"missing agents", `{"items": 2}`, `document: missing "agents"`},
+		{"not an object", `[1, 2]`, "document: want an object, got [1,2]"},
+		{"agents not an array", `{"agents": {}}`, "agents: want an array, got {}"},
+		{"missing bids", `{"agents": [{"bids": []}, {}]}`, `agents[1]: missing "bids"`},
+		{"missing value", `{"agents": [{"bids": [{"items": [0]}]}]}`, `agents[0].bids[0]: missing "value"`},
+		{"string value", `{"agents": [{"bids": [{"items": [0], "value": 1}, {"items": [1], "value": "5"}]}]}`, `agents[0].bids[1].value: want a number, got "5"`},
+		{"fractional item", `{"agents": [{"bids": [{"items": [0.5], "value": 1}]}]}`, "agents[0].bids[0].items[0]: want an integer, got 0.5"},
+		{"item out of range", `{"items": 2, "agents": [{"bids": [{"items": [1, 2], "value": 1}]}]}`, "agents[0].bids[0].items[1]: 2 out of range 0..1"},
+		{"item beyond flags", `{"agents": [{"bids": [{"items": [63], "value": 1}]}]}`, "agents[0].bids[0].items[0]: 63 out of range 0..62"},
+		{"too many items", `{"items": 64, "agents": []}`, "items: 64 out of range 0..63"},
+		{"bad seller", `{"agents": [], "seller": {"bids": [{"items": [-1], "value": 1}]}}`, "seller.bids[0].items[0]: -1 out of range 0..62"},
+		{"syntax error", "{\n  \"agents\": [\n    {\"bids\": []},\n  ]\n}", "line 4, column 3: invalid character ']' looking for beginning of value"},
+	} {
+		err := ValidateInputJSON([]byte(test.doc))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: got error %q", test.name, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+// TestInputSchema checks InputSchema is JSON and that the example bids
+// validate against it.
+func TestInputSchema(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(InputSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("../examples/problem1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateInputJSON(data); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestInputSchemaConstraints reads every constraint of InputSchema, the
+// types, required keys and bounds of the document, an agent and a bid, and
+// checks ValidateInputJSON reports a document breaking it with the
+// matching error.
+func TestInputSchemaConstraints(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(InputSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	definitions := schema["definitions"].(map[string]interface{})
+	resolve := func(s map[string]interface{}) map[string]interface{} {
+		if ref, ok := s["$ref"].(string); ok {
+			return definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+		}
+		return s
+	}
+	wants := map[string]string{"object": "an object", "array": "an array", "integer": "an integer", "number": "a number"}
+
+	check := func(doc interface{}, want string) {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateInputJSON(data); err == nil || err.Error() != want {
+			t.Errorf("%s: got error %v, want %q", data, err, want)
+		}
+	}
+	// checkValue checks the type and bounds of s for a value at path, put
+	// into a document by build.
+	checkValue := func(s map[string]interface{}, path string, build func(v interface{}) interface{}) {
+		s = resolve(s)
+		check(build("x"), fmt.Sprintf(`%s: want %s, got "x"`, path, wants[s["type"].(string)]))
+		if min, ok := s["minimum"].(float64); ok {
+			check(build(min-1), fmt.Sprintf("%s: %v out of range %v..%v", path, min-1, min, s["maximum"]))
+		}
+		if max, ok := s["maximum"].(float64); ok {
+			check(build(max+1), fmt.Sprintf("%s: %v out of range %v..%v", path, max+1, s["minimum"], max))
+		}
+	}
+
+	// every object of the schema, at a path in a document built around a
+	// valid instance of it
+	for _, place := range []struct {
+		schema map[string]interface{}
+		path   string
+		valid  map[string]interface{}
+		build  func(v interface{}) interface{}
+	}{
+		{schema, "document", map[string]interface{}{"agents": []interface{}{}},
+			func(v interface{}) interface{} { return v }},
+		{definitions["agent"].(map[string]interface{}), "agents[0]", map[string]interface{}{"bids": []interface{}{}},
+			func(v interface{}) interface{} { return map[string]interface{}{"agents": []interface{}{v}} }},
+		{definitions["agent"].(map[string]interface{}), "seller", map[string]interface{}{"bids": []interface{}{}},
+			func(v interface{}) interface{} { return map[string]interface{}{"agents": []interface{}{}, "seller": v} }},
+		{definitions["bid"].(map[string]interface{}), "agents[0].bids[0]", map[string]interface{}{"items": []interface{}{0}, "value": 1},
+			func(v interface{}) interface{} {
+				return map[string]interface{}{"agents": []interface{}{map[string]interface{}{"bids": []interface{}{v}}}}
+			}},
+	} {
+		place := place
+		if place.path != "document" {
+			checkValue(place.schema, place.path, place.build)
+		}
+		with := func(key string, v interface{}) interface{} {
+			o := make(map[string]interface{})
+			for k, x := range place.valid {
+				o[k] = x
+			}
+			if v == nil {
+				delete(o, key)
+			} else {
+				o[key] = v
+			}
+			return place.build(o)
+		}
+		for _, key := range place.schema["required"].([]interface{}) {
+			check(with(key.(string), nil), fmt.Sprintf("%s: missing %q", place.path, key))
+		}
+		for key, p := range place.schema["properties"].(map[string]interface{}) {
+			key, p := key, resolve(p.(map[string]interface{}))
+			path := place.path + "." + key
+			if place.path == "document" {
+				path = key
+			}
+			checkValue(p, path, func(v interface{}) interface{} { return with(key, v) })
+			if items, ok := p["items"].(map[string]interface{}); ok {
+				checkValue(items, path+"[0]", func(v interface{}) interface{} { return with(key, []interface{}{v}) })
+			}
+		}
+	}
+}
+
+// TestValidateInputWide checks the bounds of InputSchema on the items follow
+// the number of items validateInput is given.
+func TestValidateInputWide(t *testing.T) {
+	for _, test := range []struct {
+		doc, err string
+	}{
+		{`{"agents": [{"bids": [{"items": [99], "value": 1}]}]}`, ""},
+		{`{"items": 100, "agents": [{"bids": [{"items": [99], "value": 1}]}]}`, ""},
+		{`{"items": 100, "agents": [{"bids": [{"items": [100], "value": 1}]}]}`, "agents[0].bids[0].items[0]: 100 out of range 0..99"},
+		{`{"agents": [{"bids": [{"items": [4096], "value": 1}]}]}`, "agents[0].bids[0].items[0]: 4096 out of range 0..4095"},
+		{`{"items": 4097, "agents": []}`, "items: 4097 out of range 0..4096"},
+	} {
+		err := validateInput([]byte(test.doc), maxWideItems)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: got error %q", test.doc, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%s: got error %v, want %q", test.doc, err, test.err)
+		}
+	}
+}
+
+func TestValidateInputJSONTruncated(t *testing.T) {
+	err := ValidateInputJSON([]byte("{\"agents\": ["))
+	if err == nil || err.Error() != "line 1, column 12: unexpected end of JSON input" {
+		t.Errorf("got error %v", err)
+	}
+	err = ValidateInputJSON([]byte("x"))
+	if err == nil || err.Error() != "line 1, column 1: invalid character 'x' looking for beginning of value" {
+		t.Errorf("got error %v", err)
+	}
+}
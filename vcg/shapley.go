@@ -0,0 +1,57 @@
+package vcg
+
+// ShapleyValues returns the Shapley value of every agent, indexed by agent
+// (index 0 is unused), in the cooperative game in which a coalition of
+// agents is worth the highest total utility of allocating the items among
+// its members only. Each agent gets its marginal contribution to the
+// coalition of the agents before it, averaged over all orders of the
+// agents, so the values add up to the worth of all agents together minus
+// that of none (the seller's reservation value, if any).
+//
+// The worth of each of the 2^n coalitions is solved once, so it is only
+// meant for a handful of agents.
+// Like SolveAllocation, it panics if bs is not valid.
+func ShapleyValues(bs BidSet, n, m int) (values []float64) {
+	if err := bs.Validate(n, m); err != nil {
+		panic("vcg: " + err.Error())
+	}
+	worth := make([]float64, 1<<uint(n))
+	for coalition := range worth {
+		members := make(BidSet, len(bs))
+		if len(bs) > Unassigned {
+			members[Unassigned] = bs[Unassigned]
+		}
+		for agent := 1; agent <= n; agent++ {
+			if coalition&(1<<uint(agent-1)) != 0 {
+				members[agent] = bs[agent]
+			} else {
+				members[agent] = make(Bid)
+			}
+		}
+		worth[coalition] = SolveAllocation(members, n, m).TotalUtility
+	}
+
+	// factorial[k] is k!, so a coalition of size k before agent i, out of
+	// n agents, occurs in k!(n-k-1)!/n! of all orders
+	factorial := make([]float64, n+1)
+	factorial[0] = 1
+	for k := 1; k <= n; k++ {
+		factorial[k] = factorial[k-1] * float64(k)
+	}
+	values = make([]float64, n+1)
+	for agent := 1; agent <= n; agent++ {
+		bit := 1 << uint(agent-1)
+		for coalition := range worth {
+			if coalition&bit != 0 {
+				continue
+			}
+			size := 0
+			for c := coalition; c != 0; c &= c - 1 {
+				size++
+			}
+			share := factorial[size] * factorial[n-size-1] / factorial[n]
+			values[agent] += share * (worth[coalition|bit] - worth[coalition])
+		}
+	}
+	return
+}
@@ -0,0 +1,26 @@
+package vcg
+
+import (
+	"math"
+	"testing"
+)
+
+// TestShapleyValues checks the values of three agents computed by hand:
+// agents 1 and 2 compete for item 0, worth 4 and 2 to them, and agent 3
+// alone wants item 1, worth 3. Agent 3 always adds 3; agent 1 adds 4 when
+// first or after agent 3 and 2 otherwise, 3 on average; agent 2 adds 2 when
+// before agent 1 and nothing otherwise, 1 on average.
+func TestShapleyValues(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 4}, Bid{0x1: 2}, Bid{0x2: 3}}
+	want := []float64{0, 3, 1, 3}
+	got := ShapleyValues(bs, 3, 2)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for agent := range want {
+		if math.Abs(got[agent]-want[agent]) > priceTolerance {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+}
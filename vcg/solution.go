@@ -0,0 +1,225 @@
+package vcg
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// priceTolerance absorbs rounding errors when checking prices.
+const priceTolerance = 1e-9
+
+type Solution struct {
+	Allocation   Allocation
+	TotalUtility float64
+
+	// Optimal is true when the allocation is known to maximize the total
+	// utility, i.e. the search completed without being cut short.
+	Optimal bool
+
+	// PricePerAgent is the VCG price of every real agent, keyed by agent
+	// (1..n). Agent 0 has no price. It is nil until prices are calculated.
+	PricePerAgent map[int]float64
+
+	// Breakdown holds the two welfares every VCG price of PricePerAgent is
+	// calculated from, keyed by agent like PricePerAgent. It is nil until
+	// prices are calculated, and for prices other than VCG.
+	Breakdown map[int]PaymentBreakdown
+
+	// Evaluated is the number of complete allocations the exhaustive search
+	// evaluated to find the allocation, pruned ones not included. It is 0
+	// when the solution was found another way, e.g. with Options.Memoize.
+	Evaluated int64
+
+	// Nodes is the number of nodes of its search tree the exhaustive search
+	// explored, one per item tried with an agent, pruned subtrees not
+	// included. Like Evaluated it is 0 when the solution was found another
+	// way.
+	Nodes int64
+
+	// RunnerUpUtility is the highest total utility among the evaluated
+	// allocations other than the chosen one, equal to TotalUtility on a tie,
+	// and Margin is TotalUtility minus it. Both are only set when Evaluated
+	// is at least 2, and not under MaxRevenue. Allocations in branches cut
+	// by Options.Prune are not evaluated, so the true runner-up may be
+	// higher then.
+	RunnerUpUtility float64
+	Margin          float64
+}
+
+// PaymentBreakdown shows how the VCG price of an agent comes about: the
+// agent pays the welfare it displaces from the others, which is the welfare
+// the others would reach without it, WelfareWithoutAgent, minus the welfare
+// they reach with it, WelfareOfOthers. Externality is that difference. The
+// price is the externality divided by the agent's weight under
+// Options.Weights, and 0 for an agent which pays nothing under MaxRevenue.
+type PaymentBreakdown struct {
+	WelfareWithoutAgent float64
+	WelfareOfOthers     float64
+	Externality         float64
+}
+
+// CalculatePrices sets the VCG price of every agent of s, which must have
+// been found for bs without options. Without agents there is nothing to
+// price and PricePerAgent is left empty. With reserve prices, use
+// CalculatePricesWithOptions, so a winner pays at least the reserves of the
+// items it keeps from the seller.
+func (s *Solution) CalculatePrices(bs BidSet, n, m int) error {
+	return s.CalculatePricesWithOptions(bs, n, m, Options{})
+}
+
+// CalculatePricesWithOptions is CalculatePrices for a solution found with
+// SolveAllocationWithOptions. The seller's reserves count towards the
+// welfare of the other agents, both with and without the priced agent.
+// The priced agent is left out by giving it no items, so every agent keeps
+// its number in the leave-one-out instances.
+//
+// With opts.Weights, the welfare is weighted and each price is divided by
+// the weight of its agent. With MaxRevenue, agents receiving no items pay
+// nothing.
+//
+// With opts.Memoize all leave-one-out instances share one memoization table,
+// so subproblems which do not involve the excluded agent are solved once.
+//
+// Unless opts.ForceFullAllocation or opts.RequireSale is set, the items of
+// the priced agent can always go unsold instead, so no price may be
+// negative. A negative price means the solution is not optimal for bs and
+// is reported as an error, after all prices have been calculated. So is a
+// price exceeding the agent's budget in opts.Budgets, as a *BudgetError.
+func (s *Solution) CalculatePricesWithOptions(bs BidSet, n, m int, opts Options) error {
+	return s.CalculatePricesContextWithOptions(context.Background(), bs, n, m, opts)
+}
+
+// CalculatePricesContextWithOptions is CalculatePricesWithOptions which
+// gives up when ctx is done, leaving s.PricePerAgent nil and returning
+// ctx.Err().
+func (s *Solution) CalculatePricesContextWithOptions(ctx context.Context, bs BidSet, n, m int, opts Options) error {
+	if n < 0 {
+		return fmt.Errorf("cannot price an auction with %d agents", n)
+	}
+	if err := bs.Validate(n, m); err != nil {
+		return err
+	}
+	if err := opts.validateOrder(m); err != nil {
+		return err
+	}
+	var ms *memoSearch
+	if opts.Memoize {
+		ms = newMemoSearch(ctx, bs, n, m, opts)
+	}
+	s.PricePerAgent = make(map[int]float64)
+	s.Breakdown = make(map[int]PaymentBreakdown)
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		var alternative_utility float64
+		if ms != nil {
+			alternative_utility = ms.best(allItems(m), 1, agent, 0)
+			if math.IsInf(alternative_utility, -1) {
+				alternative_utility = 0
+			}
+		} else {
+			alternative_solution, _ := searchWithout(ctx, bs, n, m, opts, agent)
+			alternative_utility = alternative_solution.TotalUtility
+		}
+		if err := ctx.Err(); err != nil {
+			s.PricePerAgent, s.Breakdown = nil, nil
+			return err
+		}
+		if opts.Logger != nil {
+			opts.Logger.Printf("Total utility used for computing price for Agent %d: %f", agent, alternative_utility)
+		}
+		others_utility := opts.utilityExceptAgent(s.Allocation, bs, agent)
+		s.Breakdown[agent] = PaymentBreakdown{alternative_utility, others_utility, alternative_utility - others_utility}
+		s.PricePerAgent[agent] = alternative_utility - others_utility
+		if w := opts.weight(agent); w > 0 && !opts.paysNothing(s.Allocation, agent) {
+			s.PricePerAgent[agent] /= w
+		} else {
+			s.PricePerAgent[agent] = 0
+		}
+	}
+	if !opts.ForceFullAllocation && !opts.RequireSale {
+		for agent := 1; agent < len(s.Allocation); agent++ {
+			if s.PricePerAgent[agent] < -priceTolerance {
+				return fmt.Errorf("agent %d has negative price %f, the allocation is not optimal", agent, s.PricePerAgent[agent])
+			}
+		}
+	}
+	if violations := s.BudgetViolations(opts.Budgets); violations != nil {
+		return &BudgetError{Agents: violations}
+	}
+	return nil
+}
+
+// VerifyOptimal solves bs again and reports whether s is an optimal
+// solution: its allocation is feasible for m items, worth s.TotalUtility,
+// and nothing is worth more. It always returns the true optimum.
+func VerifyOptimal(bs BidSet, s Solution, n, m int) (bool, float64) {
+	optimum := SolveAllocation(bs, n, m).TotalUtility
+	if IsFeasible(s.Allocation, m) != nil || len(s.Allocation) > n+1 {
+		return false, optimum
+	}
+	if math.Abs(SocialWelfare(s.Allocation, bs)-s.TotalUtility) > priceTolerance {
+		return false, optimum
+	}
+	return s.TotalUtility >= optimum-priceTolerance, optimum
+}
+
+// VerifyIndividualRationality reports whether no agent pays more for its
+// bundle than its bid on it, and lists the agents who do in increasing
+// order. VCG prices always satisfy this for an optimal allocation, so a
+// violation means s was not priced or solved correctly for bs.
+func VerifyIndividualRationality(bs BidSet, s Solution) (bool, []int) {
+	var violators []int
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		var value float64
+		if agent < len(bs) {
+			value = bs[agent][s.Allocation.Flags(agent)]
+		}
+		if s.PricePerAgent[agent] > value+priceTolerance {
+			violators = append(violators, agent)
+		}
+	}
+	return len(violators) == 0, violators
+}
+
+// Revenue is the sum of the prices of all agents, 0 until prices are
+// calculated.
+func (s Solution) Revenue() (revenue float64) {
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		revenue += s.PricePerAgent[agent]
+	}
+	return
+}
+
+// PayAsBidPrices returns what every agent would pay under a first-price
+// rule, indexed by agent (index 0 is unused): its bid on the bundle it
+// receives in sol, or 0 if it receives nothing.
+func PayAsBidPrices(bs BidSet, sol Solution) (prices []float64) {
+	prices = make([]float64, len(sol.Allocation))
+	for agent := 1; agent < len(prices) && agent < len(bs); agent++ {
+		if bundle := sol.Allocation.Flags(agent); bundle != 0 {
+			prices[agent] = bs[agent][bundle]
+		}
+	}
+	return
+}
+
+// VCGDiscount is how much less the seller earns from the VCG prices of sol
+// than from PayAsBidPrices, summed over all agents.
+func VCGDiscount(bs BidSet, sol Solution) (discount float64) {
+	for _, price := range PayAsBidPrices(bs, sol) {
+		discount += price
+	}
+	return discount - sol.Revenue()
+}
+
+// Efficiency is the welfare of the allocation relative to the highest
+// welfare any allocation of bs achieves, 1 for an optimal allocation.
+// When nothing is worth anything, every allocation has efficiency 1.
+func (s Solution) Efficiency(bs BidSet) float64 {
+	n, m := bs.Size()
+	optimum := SolveAllocation(bs, n, m).TotalUtility
+	if optimum == 0 {
+		return 1
+	}
+	return SocialWelfare(s.Allocation, bs) / optimum
+}
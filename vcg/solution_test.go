@@ -0,0 +1,352 @@
+package vcg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCalculatePricesMemoizeMatches prices random instances with and
+// without a memoization table shared by the leave-one-out solves.
+func TestCalculatePricesMemoizeMatches(t *testing.T) {
+	for seed := int64(0); seed < 30; seed++ {
+		n, m := 1+int(seed%5), 1+int(seed%4)
+		bs := randomBidSet(n, m, seed)
+		for bundle := range bs[1] {
+			if bundle%3 == 0 {
+				delete(bs[1], bundle)
+			}
+		}
+		want := SolveAllocation(bs, n, m)
+		if err := want.CalculatePrices(bs, n, m); err != nil {
+			t.Fatal(err)
+		}
+		got := SolveAllocationWithOptions(bs, n, m, Options{Memoize: true})
+		if err := got.CalculatePricesWithOptions(bs, n, m, Options{Memoize: true}); err != nil {
+			t.Fatal(err)
+		}
+		for agent := 1; agent <= n; agent++ {
+			if math.Abs(got.PricePerAgent[agent]-want.PricePerAgent[agent]) > 1e-9 {
+				t.Errorf("seed %d: agent %d pays %v memoized, %v without", seed, agent, got.PricePerAgent[agent], want.PricePerAgent[agent])
+			}
+		}
+	}
+}
+
+// TestCalculatePricesRejectsNegativeAgents checks pricing fails cleanly
+// for a negative number of agents.
+func TestCalculatePricesRejectsNegativeAgents(t *testing.T) {
+	var s Solution
+	if err := s.CalculatePrices(BidSet{nil}, -1, 1); err == nil {
+		t.Error("priced an auction with -1 agents")
+	}
+}
+
+// BenchmarkCalculatePricesEightAgents prices an auction of 8 agents,
+// solving each leave-one-out instance separately and with a shared
+// memoization table.
+func BenchmarkCalculatePricesEightAgents(b *testing.B) {
+	const n, m = 8, 5
+	bs := randomBidSet(n, m, 1)
+	s := SolveAllocation(bs, n, m)
+	for _, bc := range []struct {
+		name string
+		opts Options
+	}{
+		{"separate", Options{}},
+		{"memoized", Options{Memoize: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := s.CalculatePricesWithOptions(bs, n, m, bc.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestPricePerAgentKeys checks PricePerAgent holds exactly the real agents,
+// keyed by agent.
+func TestPricePerAgentKeys(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 5}, Bid{0x1: 3, 0x2: 2}, Bid{0x2: 4}}
+	s := SolveAllocation(bs, 3, 2)
+	if err := s.CalculatePrices(bs, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.PricePerAgent) != 3 {
+		t.Errorf("%d prices, want one for each of the 3 agents", len(s.PricePerAgent))
+	}
+	if _, ok := s.PricePerAgent[Unassigned]; ok {
+		t.Error("agent 0 has a price")
+	}
+	if !reflect.DeepEqual(s.PricePerAgent, map[int]float64{1: 3, 2: 0, 3: 2}) {
+		t.Errorf("prices %v, want agent 1 to pay 3 and agent 3 to pay 2", s.PricePerAgent)
+	}
+}
+
+// TestCalculatePricesHandComputed prices an auction in which agent 1 wants
+// both items for 6, agent 2 item 0 for 4 and agent 3 item 1 for 3. Agents 2
+// and 3 win; without agent 2 the best is agent 1's 6, so agent 2 pays
+// 6 - 3 = 3, and agent 3 pays 6 - 4 = 2.
+func TestCalculatePricesHandComputed(t *testing.T) {
+	bs := BidSet{nil, Bid{0x3: 6}, Bid{0x1: 4}, Bid{0x2: 3}}
+	s := SolveAllocation(bs, 3, 2)
+	if err := s.CalculatePrices(bs, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.PricePerAgent, map[int]float64{1: 0, 2: 3, 3: 2}) {
+		t.Errorf("prices %v, want 0, 3 and 2", s.PricePerAgent)
+	}
+	for agent, price := range s.PricePerAgent {
+		if price < 0 {
+			t.Errorf("agent %d has negative price %v", agent, price)
+		}
+	}
+}
+
+// TestCalculatePricesNegative prices the solution of an auction without
+// constraints under a constraint it violates, keeping agents 2 and 3 apart,
+// so the solution is not optimal and agent 1 would get a negative price.
+func TestCalculatePricesNegative(t *testing.T) {
+	bs := BidSet{nil, Bid{0x3: 6}, Bid{0x1: 4}, Bid{0x2: 3}}
+	s := SolveAllocation(bs, 3, 2)
+	err := s.CalculatePricesWithOptions(bs, 3, 2, Options{ExclusiveGroups: [][]int{{2, 3}}})
+	if err == nil || !strings.Contains(err.Error(), "agent 1 has negative price") {
+		t.Errorf("got error %v, want agent 1 to have a negative price", err)
+	}
+}
+
+// TestProblem1 solves the example of problem1, whose optimum gives item 3
+// to agent 1 (4), items 0 and 1 to agent 2 (5) and item 2 to agent 3 (4),
+// 13 in total. The Clarke payments, computed by hand, are:
+//
+//	agent 1: without it agent 4 takes item 3 for 3:  12 - 9 = 3
+//	agent 2: without it agent 3 takes items 1 and 2 for 7,
+//	         agent 1 item 3 and agent 4 item 0 for 1: 12 - 8 = 4
+//	agent 3: without it agent 1 takes all items for 11: 11 - 9 = 2
+//	agent 4: wins nothing:                           13 - 13 = 0
+//
+// for a revenue of 9.
+func TestProblem1(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if s.TotalUtility != 13 {
+		t.Errorf("utility %v, want 13", s.TotalUtility)
+	}
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{2, 2, 3, 1}) {
+		t.Errorf("items owned by %v, want [2 2 3 1]", got)
+	}
+	if u := s.Allocation.FindTotalUtilityExceptAgent(bs, 2); u != 8 {
+		t.Errorf("utility except agent 2 = %v, want 8", u)
+	}
+	if err := s.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 3, 2: 4, 3: 2, 4: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+	if s.Revenue() != 9 {
+		t.Errorf("revenue %v, want 9", s.Revenue())
+	}
+}
+
+func TestVerifyOptimal(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if ok, optimum := VerifyOptimal(bs, s, 4, 4); !ok || optimum != 13 {
+		t.Errorf("optimal solution: got %v with optimum %v, want true with 13", ok, optimum)
+	}
+
+	// agent 1 taking all items is worth 11
+	worse := Solution{Allocation: allocationOf(4, map[int][]int{1: {0, 1, 2, 3}}), TotalUtility: 11}
+	if ok, optimum := VerifyOptimal(bs, worse, 4, 4); ok || optimum != 13 {
+		t.Errorf("suboptimal solution: got %v with optimum %v, want false with 13", ok, optimum)
+	}
+
+	// a solution claiming more than its allocation is worth
+	lying := worse
+	lying.TotalUtility = 13
+	if ok, _ := VerifyOptimal(bs, lying, 4, 4); ok {
+		t.Error("accepted a solution claiming more than its allocation is worth")
+	}
+
+	// an infeasible allocation
+	infeasible := Solution{Allocation: allocationOf(4, map[int][]int{1: {3}, 2: {0, 1}}), TotalUtility: 9}
+	if ok, _ := VerifyOptimal(bs, infeasible, 4, 4); ok {
+		t.Error("accepted an allocation leaving item 2 unassigned")
+	}
+}
+
+// TestRevenueAndEfficiency checks the revenue of the problem1 example is the
+// sum of the hand-computed payments of TestProblem1, 3 + 4 + 2 + 0, and
+// that agent 1 taking all items for 11 reaches 11/13 of the optimum.
+func TestRevenueAndEfficiency(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if s.Revenue() != 0 {
+		t.Errorf("revenue %v before pricing, want 0", s.Revenue())
+	}
+	if err := s.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	if s.Revenue() != 3+4+2+0 {
+		t.Errorf("revenue %v, want 9", s.Revenue())
+	}
+	if e := s.Efficiency(bs); e != 1 {
+		t.Errorf("efficiency of the optimum %v, want 1", e)
+	}
+	worse := Solution{Allocation: allocationOf(4, map[int][]int{1: {0, 1, 2, 3}})}
+	if e := worse.Efficiency(bs); math.Abs(e-11.0/13) > priceTolerance {
+		t.Errorf("efficiency %v, want 11/13", e)
+	}
+	nothing := Solution{Allocation: allocationOf(1, nil)}
+	if e := nothing.Efficiency(BidSet{nil, Bid{}}); e != 1 {
+		t.Errorf("efficiency %v when nothing is worth anything, want 1", e)
+	}
+}
+
+// TestMaxRevenue crafts an instance where selling item 1 to agent 3 for
+// nothing lowers agent 1's price from 9 to 8: the welfare-maximizing
+// allocation sells both items for a revenue of 8, the revenue-maximizing
+// one only item 0, for 9.
+func TestMaxRevenue(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x1: 10}, Bid{0x3: 9}, Bid{0x2: 1}}
+	for _, test := range []struct {
+		objective  Objective
+		allocation string
+		utility    float64
+		revenue    float64
+	}{
+		{MaxWelfare, "agent1:{item0} agent3:{item1}", 11, 8},
+		{MaxRevenue, "agent1:{item0} unsold:{item1}", 10, 9},
+	} {
+		opts := Options{Objective: test.objective}
+		s := SolveAllocationWithOptions(bs, 3, 2, opts)
+		if got := s.Allocation.String(); got != test.allocation || s.TotalUtility != test.utility {
+			t.Errorf("objective %d: got %s worth %v, want %s worth %v", test.objective, got, s.TotalUtility, test.allocation, test.utility)
+		}
+		if err := s.CalculatePricesWithOptions(bs, 3, 2, opts); err != nil {
+			t.Fatal(err)
+		}
+		if revenue := s.Revenue(); math.Abs(revenue-test.revenue) > priceTolerance {
+			t.Errorf("objective %d: revenue %v, want %v", test.objective, revenue, test.revenue)
+		}
+	}
+}
+
+// TestCalculatePricesReserve checks a winner facing no competing bid pays
+// exactly the reserve of the items it keeps the seller from holding, and
+// the competing bid instead once it is higher than the reserve.
+func TestCalculatePricesReserve(t *testing.T) {
+	opts := Options{ReservePrices: []float64{2, 1, 0.5}}
+	for _, test := range []struct {
+		name   string
+		bs     BidSet
+		prices map[int]float64
+	}{
+		{"reserves", BidSet{Bid{}, Bid{0x1: 5}, Bid{0x2: 4}}, map[int]float64{1: 2, 2: 1}},
+		{"bundle", BidSet{Bid{}, Bid{0x5: 6}, Bid{0x2: 4}}, map[int]float64{1: 2.5, 2: 1}},
+		{"competing bid", BidSet{Bid{}, Bid{0x1: 5}, Bid{0x1: 3, 0x2: 0.5}}, map[int]float64{1: 3, 2: 0}},
+	} {
+		s := SolveAllocationWithOptions(test.bs, 2, 3, opts)
+		if err := s.CalculatePricesWithOptions(test.bs, 2, 3, opts); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !reflect.DeepEqual(s.PricePerAgent, test.prices) {
+			t.Errorf("%s: %s priced at %v, want %v", test.name, s.Allocation, s.PricePerAgent, test.prices)
+		}
+	}
+}
+
+// TestVerifyIndividualRationality checks no agent of problem1 pays more
+// than its bid, and that overcharging agents is reported.
+func TestVerifyIndividualRationality(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if err := s.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	if ok, violators := VerifyIndividualRationality(bs, s); !ok || violators != nil {
+		t.Errorf("got %v, %v, want IR to hold", ok, violators)
+	}
+
+	// agent 2 bids 5 on its bundle and agent 4 wins nothing
+	s.PricePerAgent[2], s.PricePerAgent[4] = 5.5, 0.1
+	if ok, violators := VerifyIndividualRationality(bs, s); ok || !reflect.DeepEqual(violators, []int{2, 4}) {
+		t.Errorf("got %v, %v, want agents 2 and 4", ok, violators)
+	}
+}
+
+// TestPayAsBid compares the revenues of problem1: its winners bid 4, 5 and
+// 4 on their bundles, 13 in all, and pay 3, 4 and 2 under VCG, 9 in all,
+// which makes a discount of 4.
+func TestPayAsBid(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if err := s.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	prices := PayAsBidPrices(bs, s)
+	if want := []float64{0, 4, 5, 4, 0}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("pay-as-bid prices %v, want %v", prices, want)
+	}
+	var revenue float64
+	for _, price := range prices {
+		revenue += price
+	}
+	if revenue != 13 || s.Revenue() != 9 {
+		t.Errorf("pay-as-bid revenue %v and VCG revenue %v, want 13 and 9", revenue, s.Revenue())
+	}
+	if d := VCGDiscount(bs, s); d != 4 {
+		t.Errorf("discount %v, want 4", d)
+	}
+}
+
+// TestPaymentBreakdown checks the breakdown of problem1 against the hand
+// computation in problem1/main.go, and that on random instances every price
+// is the welfare without the agent minus the welfare of the others.
+func TestPaymentBreakdown(t *testing.T) {
+	bs := problem1Bids()
+	s := SolveAllocation(bs, 4, 4)
+	if err := s.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]PaymentBreakdown{1: {12, 9, 3}, 2: {12, 8, 4}, 3: {11, 9, 2}, 4: {13, 13, 0}}
+	if !reflect.DeepEqual(s.Breakdown, want) {
+		t.Errorf("breakdown %v, want %v", s.Breakdown, want)
+	}
+
+	for seed := int64(0); seed < 30; seed++ {
+		n, m := 1+int(seed%4), 1+int(seed%5)
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: float64(seed%3) * 0.3, Seed: seed})
+		s := SolveAllocation(bs, n, m)
+		if err := s.CalculatePrices(bs, n, m); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Breakdown) != n {
+			t.Errorf("seed %d: breakdown of %d agents, want %d", seed, len(s.Breakdown), n)
+		}
+		for agent, b := range s.Breakdown {
+			if b.Externality != b.WelfareWithoutAgent-b.WelfareOfOthers || b.Externality != s.PricePerAgent[agent] {
+				t.Errorf("seed %d: agent %d pays %v, breakdown %+v", seed, agent, s.PricePerAgent[agent], b)
+			}
+		}
+	}
+}
+
+func BenchmarkCalculatePrices(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		bs := GenerateBidSet(GenOptions{Agents: size.n, Items: size.m, Seed: 1})
+		s := SolveAllocation(bs, size.n, size.m)
+		b.Run(fmt.Sprintf("n=%d,m=%d", size.n, size.m), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := s.CalculatePrices(bs, size.n, size.m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,670 @@
+package vcg
+
+import (
+	"context"
+	"log"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// SolveAllocation finds the allocation of m items to n agents (plus agent 0)
+// which maximizes the total utility of the bids.
+// Bid flags hold at most MaxFlagItems items, use SolveAllocationWide above
+// that.
+// It panics if bs is not valid for n agents and m items, see BidSet.Validate.
+func SolveAllocation(bs BidSet, n, m int) (s Solution) {
+	return SolveAllocationWithOptions(bs, n, m, Options{})
+}
+
+// SolveAllocationSequential is SolveAllocation without any goroutines.
+// It serves as the reference implementation for the parallel search.
+func SolveAllocationSequential(bs BidSet, n, m int) (s Solution) {
+	return SolveAllocationWithOptions(bs, n, m, Options{Sequential: true})
+}
+
+// SolveAllocationWithOptions is SolveAllocation tuned by opts.
+// Like SolveAllocation, it panics if bs is not valid for n agents and m
+// items, see BidSet.Validate.
+func SolveAllocationWithOptions(bs BidSet, n, m int, opts Options) (s Solution) {
+	s, err := solveContext(context.Background(), bs, n, m, opts)
+	if err != nil {
+		panic("vcg: " + err.Error())
+	}
+	return
+}
+
+// SolveAllocationContext is SolveAllocation which gives up when ctx is done.
+// It then returns the best allocation found so far (if any) along with
+// ctx.Err(), e.g. context.DeadlineExceeded. Instead of panicking on an
+// invalid bs, it returns the error of BidSet.Validate.
+func SolveAllocationContext(ctx context.Context, bs BidSet, n, m int) (s Solution, err error) {
+	return solveContext(ctx, bs, n, m, Options{})
+}
+
+// SolveAllocationContextWithOptions is SolveAllocationContext tuned by opts.
+// With opts.Memoize there is no best-so-far allocation, so it returns an
+// empty Solution when ctx is done first.
+func SolveAllocationContextWithOptions(ctx context.Context, bs BidSet, n, m int, opts Options) (s Solution, err error) {
+	return solveContext(ctx, bs, n, m, opts)
+}
+
+func solveContext(ctx context.Context, bs BidSet, n, m int, opts Options) (s Solution, err error) {
+	if err = bs.Validate(n, m); err != nil {
+		return
+	}
+	if err = opts.validateOrder(m); err != nil {
+		return
+	}
+	if opts.Objective == MaxRevenue {
+		return solveRevenue(ctx, bs, n, m, opts)
+	}
+	if opts.Memoize {
+		return solveMemoized(ctx, bs, n, m, opts)
+	}
+	return searchWithout(ctx, bs, n, m, opts, 0)
+}
+
+// searchWithout runs the exhaustive search on the auction without agent
+// excluded (0 to exclude nobody). The excluded agent keeps its number but
+// receives no items and its bid is ignored.
+func searchWithout(ctx context.Context, bs BidSet, n, m int, opts Options, excluded int) (s Solution, err error) {
+	// the fast path neither reports progress, logs nor watches ctx
+	if m == 1 && !opts.limitsSizes() && opts.OnProgress == nil && opts.Logger == nil && ctx.Err() == nil {
+		return solveSingleItem(bs, n, opts, excluded), nil
+	}
+	var bound bounder
+	if opts.Prune {
+		bound = newBound(bs, n, m, opts)
+		if bound != nil && opts.ItemOrder == nil {
+			opts.ItemOrder = contestedOrder(bs, n, m, opts)
+		}
+	}
+	return solve(ctx, n, m, excluded, opts, func(a Allocation, flags []int64) float64 {
+		return opts.utilityOfFlags(flags, bs, excluded)
+	}, bound)
+}
+
+// evaluator returns the total utility of a complete allocation. flags holds
+// the items of each agent in a as Bid flags, indexed by agent; the search
+// keeps it up to date as it assigns items, so evaluators need not rebuild
+// it with Allocation.Flags. It is only meaningful up to MaxFlagItems items.
+type evaluator func(a Allocation, flags []int64) float64
+
+// bounder returns an upper bound on the total utility of any allocation
+// extending a, in which the items of remaining, as Bid flags, are not
+// allocated yet. flags is as for evaluator.
+type bounder func(a Allocation, flags []int64, remaining int64) float64
+
+// defaultParallelThreshold is the default Options.ParallelThreshold. Below
+// it, starting the workers and handing out jobs costs more than it saves.
+const defaultParallelThreshold = 1 << 10
+
+// parallelSplitItem is the depth at which the parallel search hands subtrees
+// to workers: the assignments of all items before it form one job.
+const parallelSplitItem = 2
+
+// search holds what stays the same during a run of
+// recursiveAllocationGenerator.
+type search struct {
+	ctx          context.Context
+	interrupted  int32 // set atomically once ctx stopped the search
+	inc          *incumbent
+	eval         evaluator
+	bound        bounder // nil disables pruning
+	first_agent  int     // 1 when agent 0 may not hold items
+	require_sale bool    // see Options.RequireSale
+	excluded     int     // agent which may not hold items, 0 for none
+	logger       *log.Logger
+	eligible     func(agent, item int) bool
+	size_limit   func(agent int) (min, max int) // nil without size limits
+	rivals       [][]int                        // see Options.rivals, nil without exclusive groups
+	order        []int                          // items by depth, nil for 0..m-1
+	remaining    []int64                        // Bid flags of the items at depth and after, with bound
+	progress     *progress                      // nil when nobody watches
+	agents       int
+	items        int
+	split_item   int // depth at which subtrees become jobs, 0 for a sequential search
+	workers      int
+	iterative    bool        // use iterativeAllocationGenerator
+	checkpoint   *checkpoint // records where the iterative search was interrupted, if not nil
+	resume       []frame     // stack to resume the iterative search from
+}
+
+// solve runs the exhaustive search. If ctx is done before the search
+// completes, it returns the best solution found so far, not marked Optimal,
+// and ctx.Err().
+func solve(ctx context.Context, n, m, excluded int, opts Options, eval evaluator, bound bounder) (s Solution, err error) {
+	sr := newSearch(ctx, n, m, excluded, opts, eval, bound)
+	sr.run()
+	s = sr.inc.s
+	s.Nodes = atomic.LoadInt64(&sr.inc.nodes)
+	if s.Evaluated >= 2 {
+		s.Margin = s.TotalUtility - s.RunnerUpUtility
+	}
+	if atomic.LoadInt32(&sr.interrupted) != 0 {
+		if sr.checkpoint != nil && sr.checkpoint.stack != nil {
+			sr.checkpoint.best = s
+			sr.checkpoint.best.Allocation = s.Allocation.Copy()
+		}
+		return s, ctx.Err()
+	}
+	sr.progress.done()
+	s.Optimal = true
+	return s, nil
+}
+
+// newSearch prepares a search, in parallel unless opts or the size of the
+// instance call for a sequential one.
+func newSearch(ctx context.Context, n, m, excluded int, opts Options, eval evaluator, bound bounder) (sr *search) {
+	sr = &search{
+		ctx:          ctx,
+		inc:          &incumbent{rank: opts.ranks(n)},
+		eval:         eval,
+		bound:        bound,
+		excluded:     excluded,
+		logger:       opts.Logger,
+		eligible:     opts.eligible,
+		require_sale: opts.RequireSale,
+		rivals:       opts.rivals(n),
+		order:        opts.ItemOrder,
+		agents:       n,
+		items:        m,
+		split_item:   parallelSplitItem,
+		workers:      opts.Parallelism,
+		iterative:    opts.Iterative,
+	}
+	if sr.workers <= 0 {
+		sr.workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.ForceFullAllocation {
+		sr.first_agent = 1
+	}
+	if opts.limitsSizes() {
+		sr.size_limit = opts.sizeLimit
+	}
+	if bound != nil {
+		sr.remaining = make([]int64, m+1)
+		for depth := m - 1; depth >= 0; depth-- {
+			sr.remaining[depth] = sr.remaining[depth+1] | 1<<uint(sr.item(depth))
+		}
+	}
+	choices := int64(n + 1 - sr.first_agent)
+	if excluded > 0 {
+		choices--
+	}
+	sr.progress = newProgress(opts.OnProgress, choices, m)
+	if opts.Workers(n, m) == 1 {
+		sr.split_item = 0
+	}
+	if cp := opts.checkpoint; cp != nil && excluded == 0 {
+		if cp.stack != nil {
+			// resume sequentially, like the search which was interrupted
+			sr.resume, cp.stack = cp.stack, nil
+			sr.split_item, sr.iterative = 0, true
+			sr.inc.s, sr.inc.nodes = cp.best, cp.best.Nodes
+			if cp.best.Allocation != nil {
+				sr.inc.s.Allocation = cp.best.Allocation.Copy()
+				sr.inc.publish()
+			}
+		}
+		if sr.split_item == 0 && sr.iterative {
+			sr.checkpoint = cp
+		}
+	}
+	return
+}
+
+// newAllocation returns an allocation of nothing to agents 0..n.
+func newAllocation(n int) (a Allocation) {
+	a = make(Allocation)
+	for agent := 0; agent <= n; agent++ {
+		a[agent] = make(map[int]bool)
+	}
+	return
+}
+
+// run searches all allocations. In a parallel search, the assignments of
+// the items at depths before split_item are enumerated here and sent as
+// jobs to a fixed number of workers. Each worker owns one allocation, into
+// which it replays a job's assignments before searching the rest of the
+// tree sequentially and backtracking, so no allocation is copied per
+// branch. It offers the allocations to its own incumbent, merged into
+// sr.inc after every job, so workers only contend for sr.inc once per job.
+func (sr *search) run() {
+	if sr.items == 0 {
+		// the only allocation assigns nothing, which sells nothing
+		a, flags := newAllocation(sr.agents), make([]int64, sr.agents+1)
+		if !sr.require_sale {
+			sr.inc.offer(a, sr.eval(a, flags))
+		}
+		sr.progress.add(1)
+		return
+	}
+	if sr.split_item == 0 {
+		sr.generate(sr.inc, newAllocation(sr.agents), make([]int64, sr.agents+1), 0, nil)
+		return
+	}
+
+	jobs := make(chan []int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < sr.workers; w++ {
+		wg.Add(1)
+		go func(a Allocation, flags []int64, inc *incumbent) {
+			defer wg.Done()
+			for job := range jobs {
+				for depth, agent := range job {
+					a[agent][sr.item(depth)] = true
+					flags[agent] |= 1 << uint(sr.item(depth))
+				}
+				sr.generate(inc, a, flags, sr.split_item, nil)
+				for depth, agent := range job {
+					delete(a[agent], sr.item(depth))
+					flags[agent] &^= 1 << uint(sr.item(depth))
+				}
+				sr.inc.merge(inc)
+			}
+		}(newAllocation(sr.agents), make([]int64, sr.agents+1), sr.inc.worker())
+	}
+	sr.generate(sr.inc, newAllocation(sr.agents), make([]int64, sr.agents+1), 0, jobs)
+	close(jobs)
+	wg.Wait()
+}
+
+// generate searches the allocations extending a from first_depth of the
+// search order on, offering them to inc, with iterativeAllocationGenerator
+// or recursiveAllocationGenerator.
+func (sr *search) generate(inc *incumbent, a Allocation, flags []int64, first_depth int, jobs chan<- []int) {
+	if sr.iterative {
+		sr.iterativeAllocationGenerator(inc, a, flags, first_depth, jobs)
+	} else {
+		sr.recursiveAllocationGenerator(inc, a, flags, first_depth, jobs)
+	}
+}
+
+// item returns the item at depth in the search order.
+func (sr *search) item(depth int) int {
+	if sr.order == nil {
+		return depth
+	}
+	return sr.order[depth]
+}
+
+// job returns the agents holding the items at the depths before
+// split_item, indexed by depth.
+func (sr *search) job(a Allocation) (job []int) {
+	owners := a.owners()
+	job = make([]int, sr.split_item)
+	for depth := range job {
+		job[depth] = owners[sr.item(depth)]
+	}
+	return
+}
+
+// incumbent holds the best solution found so far by a single search, or by
+// one worker of a parallel search. Until the first offer s.Allocation is
+// nil, so any utility, even a negative one, replaces it.
+// While the search runs, s, ties and top are only read and written by offer
+// and merge, which hold mu. beats only reads best and found, atomically.
+type incumbent struct {
+	mu sync.Mutex
+	s  Solution
+
+	found int32  // set once s.Allocation is not nil
+	best  uint64 // math.Float64bits of s.TotalUtility when found is set
+
+	all  bool         // keep every allocation tied with s in ties
+	ties []Allocation // including s.Allocation, only when all is set
+
+	top *topK // keep the best allocations in top, when not nil
+
+	rank []int // of every agent for breaking ties, see Options.ranks
+
+	nodes int64 // explored since the last merge, updated atomically
+}
+
+// worker returns an empty incumbent for a worker of the search of inc, which
+// keeps what inc keeps. See merge.
+func (inc *incumbent) worker() *incumbent {
+	w := &incumbent{all: inc.all, rank: inc.rank}
+	if inc.top != nil {
+		w.top = &topK{k: inc.top.k}
+	}
+	return w
+}
+
+// offer replaces the incumbent with allocation a if it has higher utility.
+// On equal utility the allocation which gives item 0, item 1 and so on to
+// lower-numbered agents, or agents preferred by Options.TieBreak, wins, so
+// the result does not depend on scheduling.
+// The allocation is copied, so the caller may keep mutating a afterwards.
+func (inc *incumbent) offer(a Allocation, total_utility float64) {
+	inc.mu.Lock()
+	defer inc.mu.Unlock()
+	inc.s.Evaluated++
+	if inc.top != nil {
+		inc.top.offer(a, total_utility)
+	}
+	if inc.s.Allocation == nil || inc.s.TotalUtility < total_utility ||
+		(inc.s.TotalUtility == total_utility && a.lessRanked(inc.s.Allocation, inc.rank)) {
+		if inc.s.Allocation != nil {
+			inc.runnerUp(inc.s.TotalUtility)
+		}
+		if inc.all && (inc.s.Allocation == nil || inc.s.TotalUtility < total_utility) {
+			inc.ties = nil
+		}
+		if inc.s.Allocation != nil && !inc.all {
+			// nothing else refers to the allocation it replaces
+			a.copyTo(inc.s.Allocation)
+		} else {
+			inc.s.Allocation = a.Copy()
+		}
+		inc.s.TotalUtility = total_utility
+		if inc.all {
+			inc.ties = append(inc.ties, inc.s.Allocation)
+		}
+		inc.publish()
+	} else {
+		inc.runnerUp(total_utility)
+		if inc.all && inc.s.TotalUtility == total_utility {
+			inc.ties = append(inc.ties, a.Copy())
+		}
+	}
+}
+
+// runnerUp offers the utility of an allocation which is not the incumbent
+// as the runner-up. The second offer brings the first one.
+func (inc *incumbent) runnerUp(u float64) {
+	if inc.s.Evaluated == 2 || u > inc.s.RunnerUpUtility {
+		inc.s.RunnerUpUtility = u
+	}
+}
+
+// merge offers everything worker incumbent w was offered since it was last
+// merged to inc, as if it had been offered to inc directly, adds the nodes
+// it explored, and empties w.
+// The result does not depend on the order of the merges, since offer breaks
+// ties by a fixed order.
+func (inc *incumbent) merge(w *incumbent) {
+	atomic.AddInt64(&inc.nodes, atomic.SwapInt64(&w.nodes, 0))
+	if w.s.Allocation == nil {
+		// only allocations which did not sell anything, none offered
+		return
+	}
+	inc.mu.Lock()
+	defer inc.mu.Unlock()
+	if inc.top != nil {
+		for _, s := range w.top.solutions {
+			inc.top.offer(s.Allocation, s.TotalUtility)
+		}
+	}
+	if inc.s.Allocation == nil {
+		inc.s.Allocation, inc.s.TotalUtility = w.s.Allocation, w.s.TotalUtility
+		inc.s.RunnerUpUtility = w.s.RunnerUpUtility
+		inc.ties = w.ties
+	} else {
+		// the runner-up is the better of the two runners-up, if any, and
+		// the loser of the two
+		runner_up := w.s.TotalUtility
+		if w.s.TotalUtility > inc.s.TotalUtility ||
+			(w.s.TotalUtility == inc.s.TotalUtility && w.s.Allocation.lessRanked(inc.s.Allocation, inc.rank)) {
+			runner_up = inc.s.TotalUtility
+			if inc.all && w.s.TotalUtility > inc.s.TotalUtility {
+				inc.ties = nil
+			}
+			inc.ties = append(w.ties, inc.ties...)
+			inc.s.Allocation, inc.s.TotalUtility = w.s.Allocation, w.s.TotalUtility
+		} else if inc.all && w.s.TotalUtility == inc.s.TotalUtility {
+			inc.ties = append(inc.ties, w.ties...)
+		}
+		if inc.s.Evaluated >= 2 && inc.s.RunnerUpUtility > runner_up {
+			runner_up = inc.s.RunnerUpUtility
+		}
+		if w.s.Evaluated >= 2 && w.s.RunnerUpUtility > runner_up {
+			runner_up = w.s.RunnerUpUtility
+		}
+		inc.s.RunnerUpUtility = runner_up
+	}
+	inc.s.Evaluated += w.s.Evaluated
+	inc.publish()
+
+	w.s, w.ties = Solution{}, nil
+	if w.top != nil {
+		w.top = &topK{k: w.top.k}
+	}
+}
+
+// publish makes the utility of s.Allocation visible to beats. It must be
+// called with mu held whenever s.Allocation changes.
+func (inc *incumbent) publish() {
+	atomic.StoreUint64(&inc.best, math.Float64bits(inc.s.TotalUtility))
+	atomic.StoreInt32(&inc.found, 1)
+}
+
+// beats reports whether the incumbent is strictly better than utility u.
+// Ties are not pruned, so the tie-break of offer still sees every candidate.
+// It does not take mu, so it may miss an offer made concurrently; as the
+// utility of the incumbent only grows, it then prunes less, never wrongly.
+func (inc *incumbent) beats(u float64) bool {
+	return atomic.LoadInt32(&inc.found) != 0 && math.Float64frombits(atomic.LoadUint64(&inc.best)) > u
+}
+
+// beaten reports whether the best allocation offered to inc, or found by the
+// whole search so far, is strictly better than utility u.
+func (sr *search) beaten(inc *incumbent, u float64) bool {
+	return inc.beats(u) || inc != sr.inc && sr.inc.beats(u)
+}
+
+// reachesMinSizes reports whether the agents holding the items of flags can
+// all still reach their minimum bundle size with the remaining items.
+func (sr *search) reachesMinSizes(flags []int64, remaining int) bool {
+	for agent := 1; agent < len(flags); agent++ {
+		if agent == sr.excluded {
+			continue
+		}
+		if min, _ := sr.size_limit(agent); min > 0 {
+			if missing := min - bundleSize(flags[agent]); missing > 0 {
+				remaining -= missing
+			}
+		}
+	}
+	return remaining >= 0
+}
+
+// mayReceive reports whether agent, holding the items of flags, may also
+// receive item.
+func (sr *search) mayReceive(flags []int64, agent, item int) bool {
+	if agent != Unassigned && agent == sr.excluded || !sr.eligible(agent, item) {
+		return false
+	}
+	if sr.size_limit != nil && agent != Unassigned {
+		if _, max := sr.size_limit(agent); max >= 0 && bundleSize(flags[agent]) >= max {
+			return false
+		}
+	}
+	if sr.rivals != nil {
+		for _, rival := range sr.rivals[agent] {
+			if flags[rival] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sells reports whether a real agent holds any of the items of flags.
+func sells(flags []int64) bool {
+	for agent := 1; agent < len(flags); agent++ {
+		if flags[agent] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelled reports whether the search should stop.
+func (sr *search) cancelled() bool {
+	select {
+	case <-sr.ctx.Done():
+		atomic.StoreInt32(&sr.interrupted, 1)
+		return true
+	default:
+		return false
+	}
+}
+
+// recursiveAllocationGenerator tries every agent for the item at depth in
+// the search order and recurses into the next one, offering complete
+// allocations to the incumbent.
+// Each iteration assigns exactly one (agent, current_item) pair and removes
+// it again before the next one, so a and flags, which holds the same
+// assignments as Bid flags, are back in their state on entry by the time
+// the function returns.
+//
+// When jobs is not nil, the subtree at depth split_item is not searched but
+// sent to jobs, see search.job.
+func (sr *search) recursiveAllocationGenerator(inc *incumbent, a Allocation, flags []int64, depth int, jobs chan<- []int) {
+	if sr.cancelled() {
+		return
+	}
+	if jobs != nil && depth == sr.split_item {
+		jobs <- sr.job(a)
+		return
+	}
+	current_item := sr.item(depth)
+	for agent := sr.first_agent; agent < len(a); agent++ {
+		if !sr.mayReceive(flags, agent, current_item) {
+			continue
+		}
+		if sr.logger != nil {
+			sr.logger.Printf("agent: %d, current_item: %d", agent, current_item)
+		}
+		atomic.AddInt64(&inc.nodes, 1)
+		a[agent][current_item] = true
+		flags[agent] |= 1 << uint(current_item)
+
+		if sr.size_limit != nil && !sr.reachesMinSizes(flags, sr.items-depth-1) {
+			// too few items are left for every agent to get enough
+			sr.progress.skip(sr.items - depth - 1)
+		} else if depth < sr.items-1 {
+			if sr.bound != nil && sr.beaten(inc, sr.bound(a, flags, sr.remaining[depth+1])) {
+				// no allocation below this node can beat the incumbent
+				sr.progress.skip(sr.items - depth - 1)
+			} else {
+				sr.recursiveAllocationGenerator(inc, a, flags, depth+1, jobs)
+			}
+		} else if sr.require_sale && !sells(flags) {
+			sr.progress.add(1)
+		} else {
+			total_utility := sr.eval(a, flags)
+			if sr.logger != nil {
+				sr.logger.Printf("Considering allocation: %+v, total utility: %f", a, total_utility)
+			}
+
+			inc.offer(a, total_utility)
+			sr.progress.add(1)
+		}
+
+		// cleanup for backtrack
+		delete(a[agent], current_item)
+		flags[agent] &^= 1 << uint(current_item)
+	}
+}
+
+// frame is the state of one depth of iterativeAllocationGenerator: the
+// agent currently holding the item at depth in the search order, or one
+// before the first agent to try.
+type frame struct {
+	depth int
+	agent int
+}
+
+// iterativeAllocationGenerator is recursiveAllocationGenerator with an
+// explicit stack of frames instead of recursion, so its stack does not grow
+// with m. It visits allocations in the same order and leaves a and flags
+// as it found them, even when cancelled. It then saves the stack to
+// sr.checkpoint, if any, and a later search can start again from there with
+// sr.resume.
+func (sr *search) iterativeAllocationGenerator(inc *incumbent, a Allocation, flags []int64, first_depth int, jobs chan<- []int) {
+	stack := make([]frame, 1, sr.items-first_depth+1)
+	stack[0] = frame{first_depth, sr.first_agent - 1}
+	if sr.resume != nil && first_depth == 0 && jobs == nil {
+		stack, sr.resume = sr.resume, nil
+		for _, f := range stack {
+			if f.agent >= sr.first_agent {
+				a[f.agent][sr.item(f.depth)] = true
+				flags[f.agent] |= 1 << uint(sr.item(f.depth))
+			}
+		}
+	}
+	entered := true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		depth := top.depth
+		current_item := sr.item(depth)
+		if entered {
+			entered = false
+			if sr.cancelled() {
+				if sr.checkpoint != nil {
+					sr.checkpoint.stack = append([]frame(nil), stack...)
+				}
+				for _, f := range stack[:len(stack)-1] {
+					delete(a[f.agent], sr.item(f.depth))
+					flags[f.agent] &^= 1 << uint(sr.item(f.depth))
+				}
+				return
+			}
+			if jobs != nil && depth == sr.split_item {
+				jobs <- sr.job(a)
+				stack = stack[:len(stack)-1]
+				continue
+			}
+		}
+
+		// cleanup for backtrack
+		if top.agent >= sr.first_agent {
+			delete(a[top.agent], current_item)
+			flags[top.agent] &^= 1 << uint(current_item)
+		}
+		agent := top.agent + 1
+		for agent < len(a) && !sr.mayReceive(flags, agent, current_item) {
+			agent++
+		}
+		if agent == len(a) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		top.agent = agent
+
+		if sr.logger != nil {
+			sr.logger.Printf("agent: %d, current_item: %d", agent, current_item)
+		}
+		atomic.AddInt64(&inc.nodes, 1)
+		a[agent][current_item] = true
+		flags[agent] |= 1 << uint(current_item)
+
+		if sr.size_limit != nil && !sr.reachesMinSizes(flags, sr.items-depth-1) {
+			// too few items are left for every agent to get enough
+			sr.progress.skip(sr.items - depth - 1)
+		} else if depth < sr.items-1 {
+			if sr.bound != nil && sr.beaten(inc, sr.bound(a, flags, sr.remaining[depth+1])) {
+				// no allocation below this node can beat the incumbent
+				sr.progress.skip(sr.items - depth - 1)
+			} else {
+				stack = append(stack, frame{depth + 1, sr.first_agent - 1})
+				entered = true
+			}
+		} else if sr.require_sale && !sells(flags) {
+			sr.progress.add(1)
+		} else {
+			total_utility := sr.eval(a, flags)
+			if sr.logger != nil {
+				sr.logger.Printf("Considering allocation: %+v, total utility: %f", a, total_utility)
+			}
+
+			inc.offer(a, total_utility)
+			sr.progress.add(1)
+		}
+	}
+}
@@ -0,0 +1,865 @@
+package vcg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tiedBidSet returns bids of 3 agents on 5 items with small integer values,
+// so that many allocations tie for the optimum.
+func tiedBidSet() BidSet {
+	bs := make(BidSet, 4)
+	bs[Unassigned] = make(Bid)
+	for agent := 1; agent <= 3; agent++ {
+		bs[agent] = make(Bid)
+		for flags := int64(1); flags < 1<<5; flags++ {
+			size := int64(0)
+			for f := flags; f != 0; f &= f - 1 {
+				size++
+			}
+			bs[agent][flags] = float64((flags*int64(agent+2))%4 + size)
+		}
+	}
+	return bs
+}
+
+// TestSolveAllocationParallelIsStable solves the same instance many times,
+// concurrently and each in parallel, and checks every solve returns the
+// same solution. Run it with -race.
+func TestSolveAllocationParallelIsStable(t *testing.T) {
+	bs := tiedBidSet()
+	opts := Options{Parallelism: 4, ParallelThreshold: 1}
+	want := SolveAllocationSequential(bs, 3, 5)
+
+	const solves = 300
+	solutions := make([]Solution, solves)
+	var wg sync.WaitGroup
+	for i := range solutions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			solutions[i] = SolveAllocationWithOptions(bs, 3, 5, opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, s := range solutions {
+		if s.TotalUtility != want.TotalUtility || s.Allocation.String() != want.Allocation.String() {
+			t.Fatalf("solve %d: got %v with utility %v, want %v with utility %v", i, s.Allocation, s.TotalUtility, want.Allocation, want.TotalUtility)
+		}
+		if s.Evaluated != want.Evaluated || s.RunnerUpUtility != want.RunnerUpUtility {
+			t.Fatalf("solve %d: evaluated %d with runner-up %v, want %d with %v", i, s.Evaluated, s.RunnerUpUtility, want.Evaluated, want.RunnerUpUtility)
+		}
+	}
+}
+
+// TestSolveAllocationTieBreak gives two agents the same bid on either of
+// two items, so that giving item 0 to agent 1 and item 1 to agent 2 ties
+// with the opposite. The allocation giving item 0 to the lower-numbered
+// agent must win every time.
+func TestSolveAllocationTieBreak(t *testing.T) {
+	bs := BidSet{
+		nil,
+		Bid{1 << 0: 5, 1 << 1: 5},
+		Bid{1 << 0: 5, 1 << 1: 5},
+	}
+	for _, opts := range []Options{{}, {Sequential: true}, {Parallelism: 2, ParallelThreshold: 1}} {
+		for run := 0; run < 100; run++ {
+			s := SolveAllocationWithOptions(bs, 2, 2, opts)
+			if got := s.Allocation.String(); got != "agent1:{item0} agent2:{item1}" || s.TotalUtility != 10 {
+				t.Fatalf("%+v run %d: got %s with utility %v, want agent1:{item0} agent2:{item1} with utility 10", opts, run, got, s.TotalUtility)
+			}
+		}
+	}
+}
+
+// TestTieBreakPriority checks Options.TieBreak favors the agents listed
+// first on an explicit tie, in the search, with pruning and in parallel,
+// and that unlisted agents come after the listed ones.
+func TestTieBreakPriority(t *testing.T) {
+	bs := BidSet{
+		nil,
+		Bid{1 << 0: 5, 1 << 1: 5},
+		Bid{1 << 0: 5, 1 << 1: 5},
+		Bid{1 << 0: 5, 1 << 1: 5},
+	}
+	for _, test := range []struct {
+		tie_break []int
+		want      string
+	}{
+		{[]int{3, 1, 2}, "agent1:{item1} agent3:{item0}"},
+		{[]int{2}, "agent1:{item1} agent2:{item0}"},
+		{[]int{2, 3}, "agent2:{item0} agent3:{item1}"},
+		{nil, "agent1:{item0} agent2:{item1}"},
+	} {
+		for _, opts := range []Options{{Sequential: true}, {Prune: true}, {Parallelism: 2, ParallelThreshold: 1}} {
+			opts.TieBreak = test.tie_break
+			s := SolveAllocationWithOptions(bs, 3, 2, opts)
+			if got := s.Allocation.String(); got != test.want || s.TotalUtility != 10 {
+				t.Errorf("%+v: got %s with utility %v, want %s", opts, got, s.TotalUtility, test.want)
+			}
+		}
+	}
+}
+
+// TestSolveAllocationWideItem80 solves an auction of 120 items in which
+// only a few are bid on, item 80 among them. Bids are XOR, so agent 2 wins
+// items 3 and 80 for 8, beating agent 1 on item 80 and agent 2 on item 100.
+func TestSolveAllocationWideItem80(t *testing.T) {
+	bs := WideBidSet{nil, make(WideBid), make(WideBid)}
+	bs[1].Set(NewBundle(80), 5)
+	bs[2].Set(NewBundle(3), 1)
+	bs[2].Set(NewBundle(3, 80), 8)
+	bs[2].Set(NewBundle(100), 2)
+
+	s := SolveAllocationWide(bs, 2, 120)
+	if s.TotalUtility != 8 || !s.Optimal {
+		t.Fatalf("got utility %v (optimal %v), want 8", s.TotalUtility, s.Optimal)
+	}
+	if got, want := s.Allocation.items(2), []int{3, 80}; !reflect.DeepEqual(got, want) {
+		t.Errorf("agent 2 holds %v, want %v", got, want)
+	}
+	if got := s.Allocation.items(1); len(got) != 0 {
+		t.Errorf("agent 1 holds %v, want nothing", got)
+	}
+	if err := IsFeasible(s.Allocation, 120); err != nil {
+		t.Error(err)
+	}
+	if got := s.Allocation.FindTotalUtilityWide(bs); got != 8 {
+		t.Errorf("FindTotalUtilityWide = %v, want 8", got)
+	}
+
+	// without agent 2, agent 1 takes item 80 for 5
+	if err := s.CalculatePricesWide(bs, 2, 120); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 0, 2: 5}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+// TestSolveAllocationWideNegativeEmptyBundle gives agent 1 a negative bid on
+// the empty bundle: it is better off with an item nobody bids on, worth 0,
+// so one is searched and given to it.
+func TestSolveAllocationWideNegativeEmptyBundle(t *testing.T) {
+	bs := WideBidSet{nil, make(WideBid), make(WideBid)}
+	bs[1].Set(NewBundle(), -3)
+	bs[2].Set(NewBundle(80), 4)
+	if got := bs.SearchedItems(2, 120); !reflect.DeepEqual(got, []int{0, 80}) {
+		t.Errorf("searched items %v, want [0 80]", got)
+	}
+	s := SolveAllocationWide(bs, 2, 120)
+	if got := s.Allocation.items(1); s.TotalUtility != 4 || len(got) != 1 || got[0] == 80 {
+		t.Errorf("got %v with utility %v, want agent 1 to hold an item other than 80 and utility 4", got, s.TotalUtility)
+	}
+	if err := s.CalculatePricesWide(bs, 2, 120); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 0, 2: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+func TestWideBidSetValidate(t *testing.T) {
+	tooMany := WideBidSet{nil, make(WideBid)}
+	for item := 0; item <= MaxFlagItems; item++ {
+		tooMany[1].Set(NewBundle(item), 1)
+	}
+	seller := WideBidSet{make(WideBid), make(WideBid)}
+	seller[0].Set(NewBundle(1), 1)
+	outside := WideBidSet{nil, make(WideBid)}
+	outside[1].Set(NewBundle(120), 1)
+	for _, tt := range []struct {
+		bs   WideBidSet
+		n, m int
+		err  string
+	}{
+		{tooMany, 1, 100, "64 items to search, at most 63 supported"},
+		{seller, 1, 100, "seller: bids are not supported with wide bids"},
+		{outside, 1, 120, "agent 1: bundle [120] holds items outside 0..119"},
+		{WideBidSet{nil, nil}, 1, 100, "agent 1: bid is nil"},
+		{WideBidSet{nil}, 1, 100, "bid set has 1 entries, want 2 for agent 0 and 1 agents"},
+	} {
+		if err := tt.bs.Validate(tt.n, tt.m); err == nil || err.Error() != tt.err {
+			t.Errorf("got error %v, want %q", err, tt.err)
+		}
+	}
+	if s := SolveAllocationWide(WideBidSet{}, 0, 100); s.TotalUtility != 0 || len(s.Allocation[Unassigned]) != 100 {
+		t.Errorf("without agents got %v", s.Allocation)
+	}
+}
+
+// TestSolveAllocationWideMatchesSolveAllocation solves and prices small
+// random instances both ways, with non-negative bids and with negative
+// ones, some on the empty bundle, which need items nobody bids on.
+func TestSolveAllocationWideMatchesSolveAllocation(t *testing.T) {
+	for seed := int64(0); seed < 40; seed++ {
+		n, m := 1+int(seed%3), 2+int(seed%4)
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: 0.3, Seed: seed})
+		if seed%2 == 1 {
+			bs[1][1] = -1
+		}
+		if seed%4 == 3 {
+			bs[n][0] = -2
+		}
+		wide := bs.Wide()
+		want := SolveAllocation(bs, n, m)
+		got := SolveAllocationWide(wide, n, m)
+		if got.TotalUtility != want.TotalUtility {
+			t.Errorf("seed %d: got %v with utility %v, want %v with utility %v", seed, got.Allocation, got.TotalUtility, want.Allocation, want.TotalUtility)
+			continue
+		}
+		if err := want.CalculatePrices(bs, n, m); err != nil {
+			t.Fatal(err)
+		}
+		if err := got.CalculatePricesWide(wide, n, m); err != nil {
+			t.Fatal(err)
+		}
+		for agent := 1; agent <= n; agent++ {
+			if math.Abs(got.PricePerAgent[agent]-want.PricePerAgent[agent]) > 1e-9 {
+				t.Errorf("seed %d: agent %d pays %v, want %v", seed, agent, got.PricePerAgent[agent], want.PricePerAgent[agent])
+			}
+		}
+	}
+}
+
+// TestReservePricesKeepItemsUnsold sets reserves above every bid, so all
+// items must stay with agent 0 and nobody pays anything.
+func TestReservePricesKeepItemsUnsold(t *testing.T) {
+	bs := BidSet{
+		nil,
+		Bid{0x1: 2, 0x2: 1, 0x3: 4},
+		Bid{0x1: 1, 0x2: 3, 0x3: 3.5},
+	}
+	opts := Options{ReservePrices: []float64{2.5, 3.5}}
+	s := SolveAllocationWithOptions(bs, 2, 2, opts)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{0, 0}) {
+		t.Errorf("owners %v, want [0 0]", got)
+	}
+	if s.TotalUtility != 6 {
+		t.Errorf("utility %v, want the reserves 6", s.TotalUtility)
+	}
+	s.CalculatePricesWithOptions(bs, 2, 2, opts)
+	for agent, price := range s.PricePerAgent {
+		if price != 0 {
+			t.Errorf("agent %d pays %v, want 0", agent, price)
+		}
+	}
+
+	// a bid beating its reserve sells the item
+	bs[2][0x2] = 4
+	s = SolveAllocationWithOptions(bs, 2, 2, opts)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("owners %v, want [0 2]", got)
+	}
+}
+
+// TestForceFullAllocation leaves an item nobody bids on, which goes unsold
+// by default and to some agent with ForceFullAllocation, in every mode of
+// the search.
+func TestForceFullAllocation(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 2, 0x5: 1}, Bid{0x2: 1}}
+	s := SolveAllocation(bs, 2, 3)
+	if got := s.Allocation.items(Unassigned); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("default: unsold items %v, want [2]", got)
+	}
+
+	want := SolveAllocationWithOptions(bs, 2, 3, Options{ForceFullAllocation: true, Sequential: true})
+	for _, opts := range []Options{
+		{ForceFullAllocation: true},
+		{ForceFullAllocation: true, Memoize: true},
+		{ForceFullAllocation: true, Prune: true},
+		{ForceFullAllocation: true, Parallelism: 2, ParallelThreshold: 1},
+	} {
+		s := SolveAllocationWithOptions(bs, 2, 3, opts)
+		if got := s.Allocation.items(Unassigned); len(got) != 0 {
+			t.Errorf("%+v: agent 0 holds %v", opts, got)
+		}
+		if err := IsFeasible(s.Allocation, 3); err != nil {
+			t.Errorf("%+v: %s", opts, err)
+		}
+		if s.TotalUtility != want.TotalUtility || s.TotalUtility != 2 {
+			t.Errorf("%+v: utility %v, want 2", opts, s.TotalUtility)
+		}
+	}
+}
+
+// TestSequentialMatchesParallel solves 500 random instances sequentially
+// and in parallel, which must agree on the allocation, not only on its
+// utility, as both break ties the same way.
+func TestSequentialMatchesParallel(t *testing.T) {
+	parallel := Options{Parallelism: 3, ParallelThreshold: 1}
+	for seed := int64(0); seed < 500; seed++ {
+		n, m := 1+int(seed%4), 1+int(seed%6)
+		bs := GenerateBidSet(GenOptions{
+			Agents:       n,
+			Items:        m,
+			Distribution: Distribution(seed % 6),
+			Sparsity:     float64(seed%3) * 0.3,
+			Seed:         seed,
+		})
+		want := SolveAllocationSequential(bs, n, m)
+		got := SolveAllocationWithOptions(bs, n, m, parallel)
+		if got.TotalUtility != want.TotalUtility || got.Allocation.String() != want.Allocation.String() {
+			t.Errorf("seed %d (n = %d, m = %d): parallel %v with utility %v, sequential %v with utility %v",
+				seed, n, m, got.Allocation, got.TotalUtility, want.Allocation, want.TotalUtility)
+		}
+		if got.Evaluated != want.Evaluated {
+			t.Errorf("seed %d: parallel evaluated %d allocations, sequential %d", seed, got.Evaluated, want.Evaluated)
+		}
+		// every item tried with every agent, at every depth
+		nodes, width := int64(0), int64(1)
+		for depth := 0; depth < m; depth++ {
+			width *= int64(n + 1)
+			nodes += width
+		}
+		if want.Nodes != nodes || got.Nodes != nodes {
+			t.Errorf("seed %d: sequential explored %d nodes, parallel %d, want %d", seed, want.Nodes, got.Nodes, nodes)
+		}
+	}
+}
+
+// TestSolveAllocationContextCancel gives an instance of 7^9 allocations a
+// deadline of 20ms, which must return promptly with the best allocation
+// found so far.
+func TestSolveAllocationContextCancel(t *testing.T) {
+	const n, m = 6, 9
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: 1})
+	for _, opts := range []Options{{Sequential: true}, {Parallelism: 4, ParallelThreshold: 1}, {Prune: true}} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		s, err := SolveAllocationContextWithOptions(ctx, bs, n, m, opts)
+		elapsed := time.Since(start)
+		cancel()
+
+		if err != context.DeadlineExceeded {
+			t.Fatalf("%+v: got error %v, want %v", opts, err, context.DeadlineExceeded)
+		}
+		if elapsed > time.Second {
+			t.Errorf("%+v: returned after %s", opts, elapsed)
+		}
+		if s.Optimal {
+			t.Errorf("%+v: cancelled solution reported optimal", opts)
+		}
+		if err := IsFeasible(s.Allocation, m); err != nil {
+			t.Errorf("%+v: best so far: %s", opts, err)
+		}
+		if u := s.Allocation.FindTotalUtility(bs); u != s.TotalUtility {
+			t.Errorf("%+v: best so far is worth %v, not %v", opts, u, s.TotalUtility)
+		}
+	}
+
+	// a context done before the search starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SolveAllocationContext(ctx, bs, n, m); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestSolutionOptimal checks complete searches report an optimal solution.
+func TestSolutionOptimal(t *testing.T) {
+	bs := problem1Bids()
+	for _, opts := range []Options{{}, {Sequential: true}, {Prune: true}, {Memoize: true}, {Iterative: true}} {
+		s, err := solveContext(context.Background(), bs, 4, 4, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !s.Optimal {
+			t.Errorf("%+v: complete search not reported optimal", opts)
+		}
+	}
+	if s := SolveAllocation(bs, 4, 4); !s.Optimal {
+		t.Error("SolveAllocation not reported optimal")
+	}
+}
+
+// benchmarkSizes are the numbers of agents and items of the instances the
+// benchmarks solve.
+var benchmarkSizes = []struct{ n, m int }{{2, 4}, {3, 6}, {4, 7}, {6, 7}}
+
+func BenchmarkSolveAllocation(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		bs := randomBidSet(size.n, size.m, 1)
+		b.Run(fmt.Sprintf("n=%d,m=%d", size.n, size.m), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SolveAllocation(bs, size.n, size.m)
+			}
+		})
+	}
+}
+
+// fuzzBidSet decodes a small auction from fuzz input: the number of agents
+// and items, then (agent, bundle, value) triples, values in quarters.
+func fuzzBidSet(data []byte) (bs BidSet, n, m int) {
+	if len(data) < 2 {
+		return nil, 0, 0
+	}
+	n, m = 1+int(data[0]%4), 1+int(data[1]%5)
+	bs = make(BidSet, n+1)
+	for agent := range bs {
+		bs[agent] = make(Bid)
+	}
+	for data = data[2:]; len(data) >= 3; data = data[3:] {
+		agent := 1 + int(data[0])%n
+		bs[agent][int64(data[1])&allItems(m)] = float64(int8(data[2])) / 4
+	}
+	return
+}
+
+// encodeFuzzBidSet is the inverse of fuzzBidSet, for bids of up to 4 agents
+// on up to 5 items, in quarters from -32 to 31.75.
+func encodeFuzzBidSet(bs BidSet, n, m int) (data []byte) {
+	data = []byte{byte(n - 1), byte(m - 1)}
+	for agent := 1; agent <= n; agent++ {
+		for flags, utility := range bs[agent] {
+			data = append(data, byte(agent-1), byte(flags), byte(int8(utility*4)))
+		}
+	}
+	return
+}
+
+// bruteForceUtility enumerates all (n+1)^m assignments of items to agents,
+// counting each agent's bid on exactly the items it is assigned.
+func bruteForceUtility(bs BidSet, n, m int) (best float64) {
+	best = math.Inf(-1)
+	owner := make([]int, m)
+	for {
+		flags := make([]int64, n+1)
+		for item, agent := range owner {
+			flags[agent] |= 1 << uint(item)
+		}
+		var u float64
+		for agent := 1; agent <= n; agent++ {
+			u += bs[agent][flags[agent]]
+		}
+		if u > best {
+			best = u
+		}
+		item := 0
+		for ; item < m && owner[item] == n; item++ {
+			owner[item] = 0
+		}
+		if item == m {
+			return
+		}
+		owner[item]++
+	}
+}
+
+// FuzzSolve checks the optimum of SolveAllocation against brute force.
+func FuzzSolve(f *testing.F) {
+	f.Add(encodeFuzzBidSet(problem1Bids(), 4, 4))
+	f.Add([]byte{1, 2, 0, 3, 8, 1, 1, 12, 1, 2, 12})
+	// agents paying to receive something rather than nothing
+	f.Add([]byte{2, 0, 0, 0, 0xbe, 1, 0, 0xbe})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		bs, n, m := fuzzBidSet(data)
+		if bs == nil {
+			return
+		}
+		want := bruteForceUtility(bs, n, m)
+		s := SolveAllocation(bs, n, m)
+		if math.Abs(s.TotalUtility-want) > priceTolerance {
+			t.Fatalf("%v: solver found %v (%v), brute force %v", bs, s.TotalUtility, s.Allocation, want)
+		}
+		if u := s.Allocation.FindTotalUtility(bs); math.Abs(u-s.TotalUtility) > priceTolerance {
+			t.Fatalf("%v: allocation %v worth %v, not %v", bs, s.Allocation, u, s.TotalUtility)
+		}
+	})
+}
+
+// TestGeneratorsRestoreAllocation starts both generators at every depth,
+// the items before it already assigned, and checks they leave the
+// allocation and its flags exactly as they found them, and that the flags
+// match the allocation at every leaf.
+func TestGeneratorsRestoreAllocation(t *testing.T) {
+	const n, m = 3, 5
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: 0.5, Seed: 3})
+	for _, opts := range []Options{{Sequential: true}, {Sequential: true, Iterative: true}, {Sequential: true, Prune: true}} {
+		for depth := 0; depth < m; depth++ {
+			var mismatch string
+			eval := func(a Allocation, flags []int64) float64 {
+				for agent := range flags {
+					if flags[agent] != a.Flags(agent) && mismatch == "" {
+						mismatch = fmt.Sprintf("flags %v at leaf %v", flags, a)
+					}
+				}
+				return opts.utilityOfFlags(flags, bs, 0)
+			}
+			var bound bounder
+			if opts.Prune {
+				bound = newBound(bs, n, m, opts)
+			}
+			sr := newSearch(context.Background(), n, m, 0, opts, eval, bound)
+
+			a, flags := newAllocation(n), make([]int64, n+1)
+			for d := 0; d < depth; d++ {
+				agent := (d + 1) % (n + 1)
+				a[agent][sr.item(d)] = true
+				flags[agent] |= 1 << uint(sr.item(d))
+			}
+			before, before_flags := a.String(), append([]int64(nil), flags...)
+			sr.generate(sr.inc, a, flags, depth, nil)
+
+			if a.String() != before || !reflect.DeepEqual(flags, before_flags) {
+				t.Errorf("%+v, depth %d: left %v with flags %v, want %v with flags %v", opts, depth, a, flags, before, before_flags)
+			}
+			if mismatch != "" {
+				t.Errorf("%+v, depth %d: %s", opts, depth, mismatch)
+			}
+			if sr.inc.s.Allocation == nil {
+				t.Errorf("%+v, depth %d: no allocation offered", opts, depth)
+			}
+		}
+	}
+}
+
+// TestParallelSolveAllocations checks the parallel search allocates per
+// job and per worker, not per node: far fewer times than it evaluates
+// allocations.
+func TestParallelSolveAllocations(t *testing.T) {
+	const n, m = 4, 6
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: 1})
+	opts := Options{Parallelism: 4, ParallelThreshold: 1}
+	evaluated := SolveAllocationWithOptions(bs, n, m, opts).Evaluated
+	allocs := testing.AllocsPerRun(10, func() {
+		SolveAllocationWithOptions(bs, n, m, opts)
+	})
+	t.Logf("%.0f allocations for %d allocations evaluated", allocs, evaluated)
+	if allocs > float64(evaluated)/20 {
+		t.Errorf("%.0f allocations for %d allocations evaluated", allocs, evaluated)
+	}
+}
+
+// BenchmarkSolveAllocationParallel reports the allocations of the parallel
+// search, run it with -benchmem.
+func BenchmarkSolveAllocationParallel(b *testing.B) {
+	const n, m = 4, 7
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: 1})
+	opts := Options{Parallelism: 4, ParallelThreshold: 1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SolveAllocationWithOptions(bs, n, m, opts)
+	}
+}
+
+// TestParallelSolveBoundsGoroutines watches the number of goroutines while
+// 3 workers search, which must not grow with the size of the search tree.
+func TestParallelSolveBoundsGoroutines(t *testing.T) {
+	const n, m = 4, 6
+	bs := randomBidSet(n, m, 1)
+	before := runtime.NumGoroutine()
+	var most int32
+	// the hook runs for every agent left without items, during the search
+	opts := Options{Parallelism: 3, ParallelThreshold: 1, DefaultValue: func(agent int, bundle int64) float64 {
+		for g := int32(runtime.NumGoroutine()); ; {
+			old := atomic.LoadInt32(&most)
+			if g <= old || atomic.CompareAndSwapInt32(&most, old, g) {
+				break
+			}
+		}
+		return 0
+	}}
+	SolveAllocationWithOptions(bs, n, m, opts)
+	if most == 0 {
+		t.Fatal("hook never called")
+	}
+	if most > int32(before+3+1) {
+		t.Errorf("%d goroutines while 3 workers searched, %d before", most, before)
+	}
+}
+
+// BenchmarkParallelism solves the same instance with 1 worker, 4 and
+// GOMAXPROCS.
+func BenchmarkParallelism(b *testing.B) {
+	const n, m = 5, 7
+	bs := randomBidSet(n, m, 1)
+	for _, bc := range []struct {
+		name    string
+		workers int
+	}{
+		{"workers=1", 1},
+		{"workers=4", 4},
+		{"workers=GOMAXPROCS", runtime.GOMAXPROCS(0)},
+	} {
+		opts := Options{Parallelism: bc.workers, ParallelThreshold: 1}
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SolveAllocationWithOptions(bs, n, m, opts)
+			}
+		})
+	}
+}
+
+// TestIncrementalFlagsMatchFindTotalUtility checks evaluating leaves from
+// the flags maintained by the search finds the same solutions as
+// recomputing every agent's bundle from the allocation. Single items take
+// the fast path of solveSingleItem, which must agree with the search.
+func TestIncrementalFlagsMatchFindTotalUtility(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		n, m := 1+int(seed%4), 1+int(seed%6)
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: float64(seed%3) * 0.3, Seed: seed})
+		want, _ := solve(context.Background(), n, m, 0, Options{Sequential: true}, func(a Allocation, flags []int64) float64 {
+			return a.FindTotalUtility(bs)
+		}, nil)
+		got := SolveAllocationWithOptions(bs, n, m, Options{Sequential: true})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %d: got %+v, want %+v", seed, got, want)
+		}
+	}
+}
+
+// BenchmarkLeafEvaluation compares searching with leaves evaluated from the
+// maintained flags and from the allocation's maps. Every search visits
+// 7^7 = 823543 leaves.
+func BenchmarkLeafEvaluation(b *testing.B) {
+	const n, m = 6, 7
+	bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: 1})
+	for _, bc := range []struct {
+		name string
+		eval evaluator
+	}{
+		{"flags", func(a Allocation, flags []int64) float64 { return Options{}.utilityOfFlags(flags, bs, 0) }},
+		{"maps", func(a Allocation, flags []int64) float64 { return a.FindTotalUtility(bs) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				solve(context.Background(), n, m, 0, Options{Sequential: true}, bc.eval, nil)
+			}
+		})
+	}
+}
+
+// TestAllNegativeBids checks the least bad allocation is found when every
+// allocation is worth less than nothing: both agents pay to avoid being
+// left empty-handed, agent 1 more so.
+func TestAllNegativeBids(t *testing.T) {
+	bs := BidSet{Bid{}, Bid{0x0: -5, 0x1: -1}, Bid{0x0: -3, 0x1: -2}}
+	for _, opts := range []Options{{}, {Sequential: true}, {Prune: true}, {Memoize: true}, {Iterative: true}} {
+		s := SolveAllocationWithOptions(bs, 2, 1, opts)
+		if got := s.Allocation.String(); got != "agent1:{item0}" || s.TotalUtility != -4 {
+			t.Errorf("%+v: got %s worth %v, want agent1:{item0} worth -4", opts, got, s.TotalUtility)
+		}
+	}
+
+	// every item must be sold and every bid is negative
+	bs = BidSet{Bid{}, Bid{0x1: -1, 0x2: -5, 0x3: -4.5}, Bid{0x1: -2, 0x2: -3, 0x3: -6}}
+	s := SolveAllocationWithOptions(bs, 2, 2, Options{ForceFullAllocation: true})
+	if got := s.Allocation.String(); got != "agent1:{item0} agent2:{item1}" || s.TotalUtility != -4 {
+		t.Errorf("got %s worth %v, want agent1:{item0} agent2:{item1} worth -4", got, s.TotalUtility)
+	}
+}
+
+// TestIterativeMatchesRecursive solves 200 random instances with both
+// generators, with and without pruning and parallelism, and checks they
+// find the same solution, evaluating the same allocations and exploring the
+// same nodes unless pruning in parallel.
+func TestIterativeMatchesRecursive(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		n, m := 1+int(seed%4), 2+int(seed%6)
+		bs := GenerateBidSet(GenOptions{
+			Agents:       n,
+			Items:        m,
+			Distribution: Distribution(seed % 6),
+			Sparsity:     float64(seed%3) * 0.3,
+			Seed:         seed,
+		})
+		opts := Options{Prune: seed%2 == 1}
+		if seed%3 == 0 {
+			opts.Parallelism, opts.ParallelThreshold = 3, 1
+		} else {
+			opts.Sequential = true
+		}
+		want := SolveAllocationWithOptions(bs, n, m, opts)
+		opts.Iterative = true
+		got := SolveAllocationWithOptions(bs, n, m, opts)
+		if opts.Prune && !opts.Sequential {
+			// what parallel workers prune depends on timing
+			got.Evaluated, got.Nodes, got.RunnerUpUtility, got.Margin = 0, 0, 0, 0
+			want.Evaluated, want.Nodes, want.RunnerUpUtility, want.Margin = 0, 0, 0, 0
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %d (n = %d, m = %d, %+v): iterative %+v, recursive %+v", seed, n, m, opts, got, want)
+		}
+	}
+}
+
+// BenchmarkIterative compares both generators at many items.
+func BenchmarkIterative(b *testing.B) {
+	for _, size := range []struct{ n, m int }{{1, 18}, {2, 11}} {
+		bs := GenerateBidSet(GenOptions{Agents: size.n, Items: size.m, BundlesPerAgent: 64, Seed: 1})
+		for _, iterative := range []bool{false, true} {
+			b.Run(fmt.Sprintf("n=%d,m=%d,iterative=%v", size.n, size.m, iterative), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					SolveAllocationWithOptions(bs, size.n, size.m, Options{Sequential: true, Iterative: iterative})
+				}
+			})
+		}
+	}
+}
+
+// TestParallelThreshold checks instances are searched in parallel from
+// ParallelThreshold allocations on, and that the search picked for
+// instances around the default threshold finds the optimum.
+func TestParallelThreshold(t *testing.T) {
+	tests := []struct {
+		opts Options
+		n, m int
+		want bool
+	}{
+		{Options{}, 3, 4, false},
+		{Options{}, 3, 5, true},
+		{Options{ParallelThreshold: 257}, 3, 4, false},
+		{Options{ParallelThreshold: 256}, 3, 4, true},
+		{Options{ParallelThreshold: 1}, 1, 1, true},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.parallel(tt.n, tt.m); got != tt.want {
+			t.Errorf("%d agents and %d items with threshold %d: parallel %v, want %v", tt.n, tt.m, tt.opts.ParallelThreshold, got, tt.want)
+		}
+	}
+
+	for seed := int64(0); seed < 40; seed++ {
+		n, m := 3, 3+int(seed%4)
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: float64(seed%3) * 0.3, Seed: seed})
+		want := SolveAllocationWithOptions(bs, n, m, Options{Sequential: true})
+		got := SolveAllocationWithOptions(bs, n, m, Options{Parallelism: 2})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %d: got %+v, want %+v", seed, got, want)
+		}
+		if u := bruteForceUtility(bs, n, m); math.Abs(got.TotalUtility-u) > priceTolerance {
+			t.Errorf("seed %d: utility %v, want %v", seed, got.TotalUtility, u)
+		}
+	}
+}
+
+// TestWorkers checks Options.Workers counts the goroutines of the parallel
+// search, and 1 whenever the search runs sequentially.
+func TestWorkers(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+	tests := []struct {
+		opts Options
+		n, m int
+		want int
+	}{
+		{Options{}, 3, 5, procs},
+		{Options{}, 3, 4, 1},
+		{Options{Parallelism: 3}, 3, 5, 3},
+		{Options{Parallelism: 3, ParallelThreshold: 1}, 3, 2, 1},
+		{Options{Parallelism: 3, ParallelThreshold: 1}, 3, 3, 3},
+		{Options{Parallelism: 1}, 3, 5, 1},
+		{Options{Parallelism: 3, Sequential: true}, 3, 5, 1},
+		{Options{Parallelism: 3, Deterministic: true}, 3, 5, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.Workers(tt.n, tt.m); got != tt.want {
+			t.Errorf("%+v with %d agents and %d items: %d workers, want %d", tt.opts, tt.n, tt.m, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkParallelThreshold searches 3 agents and 3 to 8 items, 64 to
+// 65536 allocations, sequentially and in parallel, to find where the
+// parallel search starts to pay off. defaultParallelThreshold is set from
+// it; the crossover only shows with GOMAXPROCS above 1.
+func BenchmarkParallelThreshold(b *testing.B) {
+	const n = 3
+	for m := 3; m <= 8; m++ {
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Seed: 1})
+		for _, bc := range []struct {
+			name string
+			opts Options
+		}{
+			{"sequential", Options{Sequential: true}},
+			{"parallel", Options{ParallelThreshold: 1}},
+		} {
+			b.Run(fmt.Sprintf("%s/allocations=%d", bc.name, allocations(n+1, m)), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					SolveAllocationWithOptions(bs, n, m, bc.opts)
+				}
+			})
+		}
+	}
+}
+
+// TestEmptyAuctions solves and prices auctions without agents, without
+// items, and with a single agent in every mode, checking nothing panics:
+// without agents nothing is sold or priced, without items nothing is worth
+// anything, and a single agent takes what it values most for free.
+func TestEmptyAuctions(t *testing.T) {
+	modes := []Options{{}, {Sequential: true}, {Prune: true}, {Memoize: true}, {Iterative: true}, {Parallelism: 2, ParallelThreshold: 1}, {RequireSale: true}, {ForceFullAllocation: true}}
+	tests := []struct {
+		bs         BidSet
+		n, m       int
+		allocation string
+		utility    float64
+		prices     map[int]float64
+	}{
+		{BidSet{}, 0, 0, "{}", 0, map[int]float64{}},
+		{BidSet{nil}, 0, 2, "unsold:{item0,item1}", 0, map[int]float64{}},
+		{BidSet{nil, Bid{}, Bid{}}, 2, 0, "{}", 0, map[int]float64{1: 0, 2: 0}},
+		{BidSet{nil, Bid{0x1: 1, 0x2: 2, 0x3: 2.5}}, 1, 2, "agent1:{item0,item1}", 2.5, map[int]float64{1: 0}},
+	}
+	for _, tt := range tests {
+		for _, opts := range modes {
+			s := SolveAllocationWithOptions(tt.bs, tt.n, tt.m, opts)
+			if err := s.CalculatePricesWithOptions(tt.bs, tt.n, tt.m, opts); err != nil {
+				t.Errorf("%d agents, %d items, %+v: %s", tt.n, tt.m, opts, err)
+				continue
+			}
+			if opts.RequireSale && tt.n*tt.m == 0 || opts.ForceFullAllocation && tt.n == 0 && tt.m > 0 {
+				// nothing can be sold, or the items cannot all be
+				if s.Allocation != nil || len(s.PricePerAgent) != 0 {
+					t.Errorf("%d agents, %d items, %+v: got %s with prices %v", tt.n, tt.m, opts, s.Allocation, s.PricePerAgent)
+				}
+				continue
+			}
+			if got := s.Allocation.String(); got != tt.allocation || s.TotalUtility != tt.utility || !s.Optimal {
+				t.Errorf("%d agents, %d items, %+v: got %s worth %v, want %s worth %v", tt.n, tt.m, opts, got, s.TotalUtility, tt.allocation, tt.utility)
+			}
+			if !reflect.DeepEqual(s.PricePerAgent, tt.prices) {
+				t.Errorf("%d agents, %d items, %+v: prices %v, want %v", tt.n, tt.m, opts, s.PricePerAgent, tt.prices)
+			}
+		}
+	}
+}
+
+// TestEmptyAuctionDiagnostics checks that the Shapley values, the core
+// violations and the LP bound accept the auctions without agents or items
+// which Validate accepts, including an empty bid set without a seller bid.
+func TestEmptyAuctionDiagnostics(t *testing.T) {
+	tests := []struct {
+		bs    BidSet
+		n, m  int
+		bound float64
+	}{
+		{BidSet{}, 0, 0, 0},
+		{BidSet{}, 0, 2, 0},
+		{BidSet{nil}, 0, 2, 0},
+		{BidSet{Bid{0x1: 1}}, 0, 1, 1},
+		{BidSet{nil, Bid{}, Bid{}}, 2, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := ShapleyValues(tt.bs, tt.n, tt.m); !reflect.DeepEqual(got, make([]float64, tt.n+1)) {
+			t.Errorf("%d agents, %d items: Shapley values %v, want all 0", tt.n, tt.m, got)
+		}
+		s := SolveAllocation(tt.bs, tt.n, tt.m)
+		if got := CoreViolations(tt.bs, s, tt.n, tt.m); len(got) != 0 {
+			t.Errorf("%d agents, %d items: core violations %v, want none", tt.n, tt.m, got)
+		}
+		if got := LPUpperBound(tt.bs, tt.n, tt.m); got != tt.bound {
+			t.Errorf("%d agents, %d items: LP bound %v, want %v", tt.n, tt.m, got, tt.bound)
+		}
+		if got := s.GapBound(tt.bs); got != 0 {
+			t.Errorf("%d agents, %d items: gap bound %v, want 0", tt.n, tt.m, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package vcg
+
+import (
+	"context"
+	"errors"
+)
+
+// Solver solves auctions with the options it holds, inferring the number of
+// agents and items from the bids. The zero value solves the plain auction,
+// like SolveAllocation.
+//
+// A Solver remembers the bids it solved last, so single bids can be changed
+// with UpdateBid and the auction solved again with ReSolve. It is not safe
+// for concurrent use.
+type Solver struct {
+	Options
+
+	// Parallel, if not nil, chooses between the parallel and the sequential
+	// search in place of Options.Sequential. Nil keeps the default: parallel
+	// on large enough auctions. Options.Deterministic always searches
+	// sequentially.
+	Parallel *bool
+
+	bs         BidSet      // bids of the last Solve, with updates
+	bids_owned bool        // bs was copied and may be modified
+	ms         *memoSearch // subproblems solved by ReSolve for bs, if any
+	cp         *checkpoint // where the last SolveContext was interrupted, or loaded
+}
+
+// Solve finds the allocation maximizing the total utility of bs.
+func (sv *Solver) Solve(bs BidSet) Solution {
+	sv.bs, sv.bids_owned, sv.ms = bs, false, nil
+	s, err := sv.SolveContext(context.Background(), bs)
+	if err != nil {
+		panic("vcg: " + err.Error())
+	}
+	return s
+}
+
+// UpdateBid sets the utility agent bids on bundle in the bids of the last
+// Solve, adding the agent if needed. The BidSet passed to Solve is not
+// modified.
+func (sv *Solver) UpdateBid(agent int, bundle int64, value float64) {
+	if !sv.bids_owned {
+		bs := make(BidSet, len(sv.bs))
+		for a, bid := range sv.bs {
+			bs[a] = make(Bid)
+			for flags, utility := range bid {
+				bs[a][flags] = utility
+			}
+		}
+		sv.bs, sv.bids_owned = bs, true
+		if sv.ms != nil {
+			sv.ms.bs = bs
+		}
+	}
+	for len(sv.bs) <= agent {
+		sv.bs = append(sv.bs, make(Bid))
+		sv.ms = nil
+	}
+	sv.bs[agent][bundle] = value
+	if sv.ms != nil {
+		sv.ms.invalidate(agent, bundle)
+	}
+}
+
+// ReSolve solves the bids of the last Solve again, with all updates since.
+// It always solves with dynamic programming, like Options.Memoize, and
+// keeps the solved subproblems: after UpdateBid, only those which depend on
+// the changed bid are solved again. The first ReSolve solves them all.
+// It maximizes total utility whatever the Objective.
+// Like Solve, it panics if the bids are not valid.
+func (sv *Solver) ReSolve() Solution {
+	n, m := sv.size(sv.bs)
+	if err := sv.bs.Validate(n, m); err != nil {
+		panic("vcg: " + err.Error())
+	}
+	if sv.ms == nil || sv.ms.all != allItems(m) {
+		sv.ms = newMemoSearch(context.Background(), sv.bs, n, m, sv.options())
+	}
+	s, _ := sv.ms.solve(m)
+	return s
+}
+
+// SolveContext is Solve which gives up when ctx is done, see
+// SolveAllocationContext. If the search was run sequentially with
+// Options.Iterative, where it stopped can then be saved with SaveCheckpoint.
+// After LoadCheckpoint it resumes the interrupted search.
+func (sv *Solver) SolveContext(ctx context.Context, bs BidSet) (Solution, error) {
+	n, m := sv.size(bs)
+	opts := sv.options()
+	resume, err := sv.cp.resumes(n, m)
+	if err != nil {
+		return Solution{}, errors.New("vcg: " + err.Error())
+	}
+	if resume {
+		opts.Memoize = false
+		opts.checkpoint = sv.cp
+	} else {
+		opts.checkpoint = &checkpoint{agents: n, items: m}
+	}
+	s, err := solveContext(ctx, bs, n, m, opts)
+	sv.cp = nil
+	if opts.checkpoint.stack != nil {
+		sv.cp = opts.checkpoint
+	}
+	return s, err
+}
+
+// Prices returns the VCG price of every agent for sol, keyed by agent like
+// Solution.PricePerAgent. It returns nil when bs has no agents.
+func (sv *Solver) Prices(bs BidSet, sol Solution) map[int]float64 {
+	n, m := sv.size(bs)
+	if err := sol.CalculatePricesWithOptions(bs, n, m, sv.options()); err != nil {
+		return nil
+	}
+	return sol.PricePerAgent
+}
+
+// options returns the Options to search with, sequentially unless Parallel
+// is set, if it is not nil.
+func (sv *Solver) options() Options {
+	opts := sv.Options
+	if sv.Parallel != nil {
+		opts.Sequential = !*sv.Parallel
+	}
+	return opts
+}
+
+// size returns the number of agents and items of bs, counting items which
+// only have a reserve price.
+func (sv *Solver) size(bs BidSet) (n, m int) {
+	n, m = bs.Size()
+	if len(sv.ReservePrices) > m {
+		m = len(sv.ReservePrices)
+	}
+	return
+}
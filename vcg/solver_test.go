@@ -0,0 +1,128 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// problem1Bids returns the bids of the problem1 example: 4 agents on 4
+// items.
+func problem1Bids() BidSet {
+	return BidSet{
+		nil,
+		Bid{0x1: 1, 0x2: 2, 0x4: 2, 0x8: 4, 0xf: 11},
+		Bid{0x1: 1, 0x2: 1, 0x4: 1, 0x8: 1, 0x3: 5},
+		Bid{0x1: 1, 0x2: 2, 0x4: 4, 0x8: 1, 0x6: 7},
+		Bid{0x1: 1, 0x2: 1, 0x4: 1, 0x8: 3},
+	}
+}
+
+func TestSolverInfersSize(t *testing.T) {
+	bs := problem1Bids()
+	var sv Solver
+	s := sv.Solve(bs)
+	want := SolveAllocation(bs, 4, 4)
+	if !reflect.DeepEqual(s.Allocation.owners(), want.Allocation.owners()) || s.TotalUtility != want.TotalUtility {
+		t.Errorf("Solve = %v with utility %v, want %v with utility %v", s.Allocation, s.TotalUtility, want.Allocation, want.TotalUtility)
+	}
+	if err := want.CalculatePrices(bs, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	if got := sv.Prices(bs, s); !reflect.DeepEqual(got, want.PricePerAgent) {
+		t.Errorf("Prices = %v, want %v", got, want.PricePerAgent)
+	}
+}
+
+// TestSolverOptions checks the options of a Solver apply, and that an item
+// with only a reserve price counts towards the number of items.
+func TestSolverOptions(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 2}, Bid{0x1: 3}}
+	sv := Solver{Options: Options{ReservePrices: []float64{1, 4}}}
+	s := sv.Solve(bs)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{2, 0}) || s.TotalUtility != 7 {
+		t.Errorf("items owned by %v with utility %v, want [2 0] with utility 7", got, s.TotalUtility)
+	}
+	if got := sv.Prices(bs, s); got[1] != 0 || got[2] != 2 {
+		t.Errorf("prices %v, want agent 2 to pay 2", got)
+	}
+
+	sv.Options = Options{ForceFullAllocation: true}
+	s = sv.Solve(BidSet{nil, Bid{0x2: 1}})
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{1, 1}) {
+		t.Errorf("ForceFullAllocation: items owned by %v, want [1 1]", got)
+	}
+}
+
+// TestSolverParallel checks Parallel overrides Options.Sequential, nil
+// keeping it, and that all of them find the same solution.
+func TestSolverParallel(t *testing.T) {
+	bs := problem1Bids()
+	want := SolveAllocation(bs, 4, 4)
+	parallel, sequential := true, false
+	for _, test := range []struct {
+		name     string
+		sv       Solver
+		parallel bool
+	}{
+		{"default", Solver{Options: Options{ParallelThreshold: 1, Parallelism: 2}}, true},
+		{"Sequential", Solver{Options: Options{ParallelThreshold: 1, Parallelism: 2, Sequential: true}}, false},
+		{"Parallel false", Solver{Options: Options{ParallelThreshold: 1, Parallelism: 2}, Parallel: &sequential}, false},
+		{"Parallel true", Solver{Options: Options{ParallelThreshold: 1, Parallelism: 2, Sequential: true}, Parallel: &parallel}, true},
+		{"Deterministic", Solver{Options: Options{ParallelThreshold: 1, Parallelism: 2, Deterministic: true}, Parallel: &parallel}, false},
+	} {
+		if got := test.sv.options().Workers(4, 4) > 1; got != test.parallel {
+			t.Errorf("%s: parallel search %v, want %v", test.name, got, test.parallel)
+		}
+		s := test.sv.Solve(bs)
+		if !reflect.DeepEqual(s.Allocation.owners(), want.Allocation.owners()) || s.TotalUtility != want.TotalUtility {
+			t.Errorf("%s: got %v with utility %v, want %v with utility %v", test.name, s.Allocation, s.TotalUtility, want.Allocation, want.TotalUtility)
+		}
+	}
+}
+
+func TestSolverPricesWithoutAgents(t *testing.T) {
+	var sv Solver
+	bs := BidSet{nil}
+	if got := sv.Prices(bs, sv.Solve(bs)); len(got) != 0 {
+		t.Errorf("Prices = %v, want none", got)
+	}
+}
+
+// TestReSolveAfterUpdateBid changes bids one at a time, adding an agent
+// too, and checks ReSolve matches solving the changed bids from scratch.
+func TestReSolveAfterUpdateBid(t *testing.T) {
+	bs := problem1Bids()
+	var sv Solver
+	sv.Solve(bs)
+	if s := sv.ReSolve(); s.TotalUtility != 13 {
+		t.Fatalf("first ReSolve: utility %v, want 13", s.TotalUtility)
+	}
+
+	changed := problem1Bids()
+	for _, update := range []struct {
+		agent  int
+		bundle int64
+		value  float64
+	}{
+		{4, 0xf, 15},
+		{2, 0x3, 1},
+		{3, 0x6, 9},
+		{5, 0x1, 6},
+		{4, 0xf, 0},
+	} {
+		sv.UpdateBid(update.agent, update.bundle, update.value)
+		for len(changed) <= update.agent {
+			changed = append(changed, make(Bid))
+		}
+		changed[update.agent][update.bundle] = update.value
+
+		n := len(changed) - 1
+		got, want := sv.ReSolve(), SolveAllocation(changed, n, 4)
+		if got.TotalUtility != want.TotalUtility || got.Allocation.FindTotalUtility(changed) != want.TotalUtility {
+			t.Errorf("after %+v: got %v with utility %v, want utility %v (%v)", update, got.Allocation, got.TotalUtility, want.TotalUtility, want.Allocation)
+		}
+	}
+	if _, ok := bs[4][0xf]; ok || len(bs) != 5 {
+		t.Error("the bids passed to Solve were modified")
+	}
+}
@@ -0,0 +1,80 @@
+package vcg
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+)
+
+// SolveTopK returns the k allocations of highest total utility, best
+// first, each as a Solution with its own total utility and no prices.
+// Allocations of equal utility are ordered as by the tie-break of
+// SolveAllocation, so the first one is what SolveAllocation returns and is
+// the only one marked Optimal. Fewer than k are returned when there are
+// fewer allocations.
+// Like SolveAllocation, it panics if bs is not valid.
+func SolveTopK(bs BidSet, n, m, k int) (solutions []Solution) {
+	if err := bs.Validate(n, m); err != nil {
+		panic("vcg: " + err.Error())
+	}
+	if k < 1 {
+		return nil
+	}
+	opts := Options{}
+	sr := newSearch(context.Background(), n, m, 0, opts, func(a Allocation, flags []int64) float64 {
+		return opts.utilityOfFlags(flags, bs, 0)
+	}, nil)
+	sr.inc.top = &topK{k: k}
+	sr.run()
+
+	solutions = sr.inc.top.solutions
+	sort.Slice(solutions, func(i, j int) bool {
+		return sr.inc.top.Less(j, i)
+	})
+	if len(solutions) > 0 {
+		solutions[0].Optimal = true
+	}
+	return
+}
+
+// topK keeps the best k allocations offered to it. It is a heap with the
+// worst of them on top, to be replaced by anything better.
+type topK struct {
+	k         int
+	solutions []Solution
+}
+
+// offer keeps a, copied, if it is among the best k so far.
+func (t *topK) offer(a Allocation, total_utility float64) {
+	s := Solution{Allocation: a, TotalUtility: total_utility}
+	if len(t.solutions) < t.k {
+		s.Allocation = a.Copy()
+		heap.Push(t, s)
+		return
+	}
+	if worse(s, t.solutions[0]) {
+		return
+	}
+	s.Allocation = a.Copy()
+	t.solutions[0] = s
+	heap.Fix(t, 0)
+}
+
+// worse reports whether s comes after r: it has lower utility or, on equal
+// utility, comes later in the tie-break order of the search.
+func worse(s, r Solution) bool {
+	if s.TotalUtility != r.TotalUtility {
+		return s.TotalUtility < r.TotalUtility
+	}
+	return r.Allocation.less(s.Allocation)
+}
+
+func (t *topK) Len() int           { return len(t.solutions) }
+func (t *topK) Less(i, j int) bool { return worse(t.solutions[i], t.solutions[j]) }
+func (t *topK) Swap(i, j int)      { t.solutions[i], t.solutions[j] = t.solutions[j], t.solutions[i] }
+func (t *topK) Push(x interface{}) { t.solutions = append(t.solutions, x.(Solution)) }
+func (t *topK) Pop() interface{} {
+	s := t.solutions[len(t.solutions)-1]
+	t.solutions = t.solutions[:len(t.solutions)-1]
+	return s
+}
@@ -0,0 +1,43 @@
+package vcg
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSolveTopK checks the k best allocations of random instances are
+// sorted by decreasing utility, distinct, and start with the optimum, and
+// that asking for more than (n+1)^m returns all of them.
+func TestSolveTopK(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		n, m := 1+int(seed%3), 1+int(seed%4)
+		bs := GenerateBidSet(GenOptions{Agents: n, Items: m, Sparsity: 0.5, Seed: seed})
+		solutions := SolveTopK(bs, n, m, 10)
+		if want := int(math.Min(10, float64(allocations(int64(n+1), m)))); len(solutions) != want {
+			t.Errorf("seed %d: got %d solutions, want %d", seed, len(solutions), want)
+		}
+		seen := make(map[string]bool)
+		for i, s := range solutions {
+			if i > 0 && s.TotalUtility > solutions[i-1].TotalUtility {
+				t.Errorf("seed %d: solution %d is worth %v, more than %v", seed, i, s.TotalUtility, solutions[i-1].TotalUtility)
+			}
+			if key := s.Allocation.String(); seen[key] {
+				t.Errorf("seed %d: %s returned twice", seed, key)
+			} else {
+				seen[key] = true
+			}
+			if u := s.Allocation.FindTotalUtility(bs); math.Abs(u-s.TotalUtility) > priceTolerance {
+				t.Errorf("seed %d: %s is worth %v, not %v", seed, s.Allocation, u, s.TotalUtility)
+			}
+			if s.Optimal != (i == 0) {
+				t.Errorf("seed %d: solution %d has Optimal %v", seed, i, s.Optimal)
+			}
+		}
+		if best := SolveAllocation(bs, n, m); solutions[0].Allocation.String() != best.Allocation.String() {
+			t.Errorf("seed %d: first solution %s, SolveAllocation found %s", seed, solutions[0].Allocation, best.Allocation)
+		}
+	}
+	if solutions := SolveTopK(problem1Bids(), 4, 4, 0); solutions != nil {
+		t.Errorf("k = 0: got %v", solutions)
+	}
+}
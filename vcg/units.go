@@ -0,0 +1,184 @@
+package vcg
+
+import (
+	"fmt"
+	"math"
+)
+
+// Units is the supply of identical items: item i has Units[i] units. A
+// bundle of units is given as Bid flags, the units of every item taking
+// consecutive flags after those of the items before it, so a Valuation can
+// value units like any other bundle.
+type Units []int
+
+// UnitValuationFunc is the utility an agent has for every number of units of
+// each item. Unlike QuantityBidSet, which values items independently, it may
+// value any combination. It must not keep units, which is reused.
+type UnitValuationFunc func(units []int) float64
+
+// Valuation returns f as a Valuation of the bundles of u: a bundle is worth
+// f of the number of units of each item it contains.
+func (u Units) Valuation(f UnitValuationFunc) Valuation {
+	return ValuationFunc(func(bundle int64) float64 {
+		return f(u.Count(bundle))
+	})
+}
+
+// Count returns the number of units of each item in bundle.
+func (u Units) Count(bundle int64) (units []int) {
+	units = make([]int, len(u))
+	for item, supply := range u {
+		for k := 0; k < supply; k++ {
+			if bundle&1 != 0 {
+				units[item]++
+			}
+			bundle >>= 1
+		}
+	}
+	return
+}
+
+// Bundle returns the bundle of the first units[i] units of every item i.
+func (u Units) Bundle(units []int) (bundle int64) {
+	shift := uint(0)
+	for item, supply := range u {
+		bundle |= (int64(1)<<uint(units[item]) - 1) << shift
+		shift += uint(supply)
+	}
+	return
+}
+
+// unitSearch solves the allocation of units agent by agent, like
+// memoSearch: agent k takes some of the remaining units and agents k+1..n
+// share the rest. A vector of units is stored as one mixed-radix number,
+// item i counting stride[i].
+type unitSearch struct {
+	vs       Valuations
+	supply   Units
+	stride   []int
+	excluded int
+	best     map[[2]int]float64 // keyed by agent and remaining units
+	taken    map[[2]int]int     // units taken by the agent for best
+}
+
+// SolveUnits allocates supply[item] identical units of every item to agents
+// 1..n of vs (index 0 is unused), maximizing the total utility, and prices
+// the allocation with VCG. Valuations must value a bundle only by its
+// number of units of each item, as those of Units.Valuation do; only the
+// first units of every item are asked for. Every subproblem of (agent,
+// remaining units) is solved once, so it calls Value O(n · S²) times per
+// solve, S being the product of supply[item]+1, and solves once more per
+// agent to price. SolveAllocationValuations finds the same allocation by
+// enumerating units as distinct items, at far higher cost.
+func SolveUnits(vs Valuations, supply Units) (s QuantitySolution, err error) {
+	states, total := 1, 0
+	for item, units := range supply {
+		if units < 0 {
+			return s, fmt.Errorf("item %d has negative supply %d", item, units)
+		}
+		if states > math.MaxInt32/(units+1) {
+			return s, fmt.Errorf("too many combinations of units to search")
+		}
+		states *= units + 1
+		total += units
+	}
+	if total > MaxFlagItems {
+		return s, fmt.Errorf("%d units do not fit into bundle flags, at most %d do", total, MaxFlagItems)
+	}
+
+	us := newUnitSearch(vs, supply, 0)
+	all := us.encode(supply)
+	s.TotalUtility = us.solve(1, all)
+	s.Units = make(map[int]map[int]int)
+	taken := make([][]int, len(vs))
+	remaining := all
+	for agent := 1; agent < len(vs); agent++ {
+		code := us.taken[[2]int{agent, remaining}]
+		taken[agent] = us.decode(code)
+		remaining -= code
+		s.Units[agent] = make(map[int]int)
+		for item, units := range taken[agent] {
+			if units > 0 {
+				s.Units[agent][item] = units
+			}
+		}
+	}
+
+	s.PricePerAgent = make(map[int]float64)
+	for agent := 1; agent < len(vs); agent++ {
+		alternative_utility := newUnitSearch(vs, supply, agent).solve(1, all)
+		s.PricePerAgent[agent] = alternative_utility - (s.TotalUtility - vs[agent].Value(supply.Bundle(taken[agent])))
+	}
+	return
+}
+
+func newUnitSearch(vs Valuations, supply Units, excluded int) *unitSearch {
+	us := &unitSearch{
+		vs:       vs,
+		supply:   supply,
+		stride:   make([]int, len(supply)),
+		excluded: excluded,
+		best:     make(map[[2]int]float64),
+		taken:    make(map[[2]int]int),
+	}
+	stride := 1
+	for item, units := range supply {
+		us.stride[item] = stride
+		stride *= units + 1
+	}
+	return us
+}
+
+// encode returns units as a mixed-radix number.
+func (us *unitSearch) encode(units []int) (code int) {
+	for item, u := range units {
+		code += u * us.stride[item]
+	}
+	return
+}
+
+// decode returns the units of each item of code.
+func (us *unitSearch) decode(code int) (units []int) {
+	units = make([]int, len(us.supply))
+	for item := len(units) - 1; item >= 0; item-- {
+		units[item] = code / us.stride[item]
+		code %= us.stride[item]
+	}
+	return
+}
+
+// solve returns the highest total utility of sharing remaining units
+// between agents agent..n, the excluded agent taking none.
+func (us *unitSearch) solve(agent, remaining int) float64 {
+	if agent >= len(us.vs) {
+		return 0
+	}
+	key := [2]int{agent, remaining}
+	if u, ok := us.best[key]; ok {
+		return u
+	}
+	best, taken := us.solve(agent+1, remaining), 0
+	if agent != us.excluded {
+		best += us.vs[agent].Value(0)
+		// enumerate every vector of units up to remaining, like an odometer
+		limit := us.decode(remaining)
+		units := make([]int, len(limit))
+		for {
+			item := 0
+			for item < len(units) && units[item] == limit[item] {
+				units[item] = 0
+				item++
+			}
+			if item == len(units) {
+				break
+			}
+			units[item]++
+			code := us.encode(units)
+			if u := us.vs[agent].Value(us.supply.Bundle(units)) + us.solve(agent+1, remaining-code); u > best {
+				best, taken = u, code
+			}
+		}
+	}
+	us.best[key], us.taken[key] = best, taken
+	return best
+}
@@ -0,0 +1,131 @@
+package vcg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// upTo values units of item 0 by values[k-1] for k units, any units beyond
+// len(values) being worth nothing more.
+func upTo(values ...float64) UnitValuationFunc {
+	return func(units []int) float64 {
+		k := units[0]
+		if k > len(values) {
+			k = len(values)
+		}
+		if k == 0 {
+			return 0
+		}
+		return values[k-1]
+	}
+}
+
+// TestSolveUnits splits 3 identical units between two agents wanting up to
+// 2: agent 1 values 1 or 2 units by 5 or 8, agent 2 by 4 or 6. Agent 1 gets
+// 2 and agent 2 one for 12. Without agent 1, agent 2 takes 2 units for 6,
+// so agent 1 pays 6 - 4 = 2; without agent 2, agent 1 still takes only 2,
+// so agent 2 pays nothing.
+func TestSolveUnits(t *testing.T) {
+	supply := Units{3}
+	s, err := SolveUnits(Valuations{nil, supply.Valuation(upTo(5, 8)), supply.Valuation(upTo(4, 6))}, supply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TotalUtility != 12 {
+		t.Errorf("utility %v, want 12", s.TotalUtility)
+	}
+	if want := map[int]map[int]int{1: {0: 2}, 2: {0: 1}}; !reflect.DeepEqual(s.Units, want) {
+		t.Errorf("units %v, want %v", s.Units, want)
+	}
+	if want := map[int]float64{1: 2, 2: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+// TestSolveUnitsComplements gives one unit of each of two items to the
+// agent valuing only the pair, 10, over one valuing each item by 4.
+func TestSolveUnitsComplements(t *testing.T) {
+	pair := UnitValuationFunc(func(units []int) float64 {
+		if units[0] > 0 && units[1] > 0 {
+			return 10
+		}
+		return 0
+	})
+	single := UnitValuationFunc(func(units []int) float64 {
+		return 4 * float64(units[0]+units[1])
+	})
+	supply := Units{1, 1}
+	s, err := SolveUnits(Valuations{nil, supply.Valuation(pair), supply.Valuation(single)}, supply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]map[int]int{1: {0: 1, 1: 1}, 2: {}}; s.TotalUtility != 10 || !reflect.DeepEqual(s.Units, want) {
+		t.Errorf("utility %v and units %v, want 10 and %v", s.TotalUtility, s.Units, want)
+	}
+	if want := map[int]float64{1: 8, 2: 0}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+// TestSolveUnitsMatchesValuations checks SolveUnits finds the utility and
+// prices of SolveAllocationValuations, which searches units as distinct
+// items, on the same valuations.
+func TestSolveUnitsMatchesValuations(t *testing.T) {
+	supply := Units{2, 1, 2}
+	vs := Valuations{nil}
+	for agent := 1; agent <= 3; agent++ {
+		weight := float64(agent)
+		vs = append(vs, supply.Valuation(func(units []int) float64 {
+			u := weight*float64(units[0]) + float64(units[1]*units[2])*(4-weight)
+			if units[0] == 2 {
+				u += 3 - weight
+			}
+			return u
+		}))
+	}
+	s, err := SolveUnits(vs, supply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SolveAllocationValuations(vs, 3, 5)
+	if err := want.CalculatePricesValuations(vs, 3, 5); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(s.TotalUtility-want.TotalUtility) > 1e-9 {
+		t.Errorf("utility %v, want %v", s.TotalUtility, want.TotalUtility)
+	}
+	var worth float64
+	for agent := 1; agent <= 3; agent++ {
+		units := make([]int, len(supply))
+		for item, k := range s.Units[agent] {
+			units[item] = k
+		}
+		worth += vs[agent].Value(supply.Bundle(units))
+		if math.Abs(s.PricePerAgent[agent]-want.PricePerAgent[agent]) > 1e-9 {
+			t.Errorf("agent %d pays %v, want %v", agent, s.PricePerAgent[agent], want.PricePerAgent[agent])
+		}
+	}
+	if math.Abs(worth-s.TotalUtility) > 1e-9 {
+		t.Errorf("units %v are worth %v, not the utility %v", s.Units, worth, s.TotalUtility)
+	}
+}
+
+func TestUnitsBundle(t *testing.T) {
+	supply := Units{2, 1, 3}
+	if got := supply.Bundle([]int{1, 1, 2}); got != 0x1d {
+		t.Errorf("Bundle = %#x, want 0x1d", got)
+	}
+	if got := supply.Count(0x2e); !reflect.DeepEqual(got, []int{1, 1, 2}) {
+		t.Errorf("Count = %v, want [1 1 2]", got)
+	}
+}
+
+func TestSolveUnitsErrors(t *testing.T) {
+	for _, supply := range []Units{{-1}, {1 << 16, 1 << 16}, {40, 30}} {
+		vs := Valuations{nil, supply.Valuation(upTo(1))}
+		if _, err := SolveUnits(vs, supply); err == nil {
+			t.Errorf("supply %v: no error", supply)
+		}
+	}
+}
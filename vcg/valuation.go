@@ -0,0 +1,90 @@
+package vcg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Valuation is the utility an agent has for every bundle, given as Bid
+// flags. Bid and ORBid are valuations; ValuationFunc turns any function
+// into one, so valuations too large to list can be computed on demand, and
+// Units.Valuation values units of identical items.
+type Valuation interface {
+	Value(bundle int64) float64
+}
+
+// ValuationFunc is a Valuation computed by calling the function.
+type ValuationFunc func(bundle int64) float64
+
+func (f ValuationFunc) Value(bundle int64) float64 {
+	return f(bundle)
+}
+
+// Value returns the utility of bundle, like ValueOf.
+func (b Bid) Value(bundle int64) float64 {
+	return b.ValueOf(bundle)
+}
+
+// Value returns the utility of bundle, like ValueOf.
+func (b ORBid) Value(bundle int64) float64 {
+	return b.ValueOf(bundle)
+}
+
+// Contains valuations for all agents (1..n)
+type Valuations []Valuation
+
+// Valuations returns the bids of bs as valuations.
+func (bs BidSet) Valuations() (vs Valuations) {
+	vs = make(Valuations, len(bs))
+	for agent, bid := range bs {
+		vs[agent] = bid
+	}
+	return
+}
+
+// FindTotalUtilityValuations is FindTotalUtility for any valuations.
+func (a Allocation) FindTotalUtilityValuations(vs Valuations) (u float64) {
+	flags := make([]int64, len(a))
+	for agent := range flags {
+		flags[agent] = a.Flags(agent)
+	}
+	return vs.utilityOfFlags(flags)
+}
+
+// utilityOfFlags is FindTotalUtilityValuations for the allocation giving
+// each agent the items of flags, indexed by agent.
+func (vs Valuations) utilityOfFlags(flags []int64) (u float64) {
+	for agent := 1; agent < len(flags); agent++ {
+		u += vs[agent].Value(flags[agent])
+	}
+	return
+}
+
+// SolveAllocationValuations is SolveAllocation for any valuations. Every
+// complete allocation calls Value once per agent.
+func SolveAllocationValuations(vs Valuations, n, m int) (s Solution) {
+	s, _ = solve(context.Background(), n, m, 0, Options{}, func(a Allocation, flags []int64) float64 {
+		return vs.utilityOfFlags(flags)
+	}, nil)
+	return
+}
+
+// CalculatePricesValuations is CalculatePrices for a solution found by
+// SolveAllocationValuations.
+func (s *Solution) CalculatePricesValuations(vs Valuations, n, m int) error {
+	if n < 0 {
+		return fmt.Errorf("cannot price an auction with %d agents", n)
+	}
+	s.PricePerAgent = make(map[int]float64)
+	s.Breakdown = make(map[int]PaymentBreakdown)
+	for agent := 1; agent < len(s.Allocation); agent++ {
+		alternative_solution, _ := solve(context.Background(), n, m, agent, Options{}, func(a Allocation, flags []int64) float64 {
+			return vs.utilityOfFlags(flags)
+		}, nil)
+		alternative_utility := alternative_solution.TotalUtility
+		others_utility := s.TotalUtility - vs[agent].Value(s.Allocation.Flags(agent))
+		s.Breakdown[agent] = PaymentBreakdown{alternative_utility, others_utility, alternative_utility - others_utility}
+		s.PricePerAgent[agent] = alternative_utility - others_utility
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package vcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// additive returns a valuation oracle summing the values of the items in a
+// bundle, counting its calls in calls.
+func additive(values []float64, calls *int) Valuation {
+	return ValuationFunc(func(bundle int64) (u float64) {
+		*calls++
+		for item, value := range values {
+			if bundle&(1<<uint(item)) != 0 {
+				u += value
+			}
+		}
+		return
+	})
+}
+
+// TestAdditiveValuation solves an auction of additive valuations, in which
+// each item goes to the agent valuing it most. Without agent 1, agent 2
+// takes everything for 6 instead of 4, so agent 1 pays 2; without agent 2,
+// agent 1 takes everything for 6 instead of 5, so agent 2 pays 1.
+func TestAdditiveValuation(t *testing.T) {
+	var calls int
+	vs := Valuations{nil, additive([]float64{3, 1, 2}, &calls), additive([]float64{1, 4, 1}, &calls)}
+	s := SolveAllocationValuations(vs, 2, 3)
+	if got := s.Allocation.owners(); !reflect.DeepEqual(got, []int{1, 2, 1}) || s.TotalUtility != 9 {
+		t.Errorf("items owned by %v with utility %v, want [1 2 1] with utility 9", got, s.TotalUtility)
+	}
+	if calls != 2*27 {
+		t.Errorf("oracle called %d times for 27 allocations of 2 agents", calls)
+	}
+	if u := s.Allocation.FindTotalUtilityValuations(vs); u != 9 {
+		t.Errorf("FindTotalUtilityValuations = %v, want 9", u)
+	}
+	if err := s.CalculatePricesValuations(vs, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]float64{1: 2, 2: 1}; !reflect.DeepEqual(s.PricePerAgent, want) {
+		t.Errorf("prices %v, want %v", s.PricePerAgent, want)
+	}
+}
+
+// TestBidSetValuations checks bids solve the same way as valuations.
+func TestBidSetValuations(t *testing.T) {
+	bs := problem1Bids()
+	got := SolveAllocationValuations(bs.Valuations(), 4, 4)
+	want := SolveAllocation(bs, 4, 4)
+	if !reflect.DeepEqual(got.Allocation.owners(), want.Allocation.owners()) || got.TotalUtility != want.TotalUtility {
+		t.Errorf("got %v with utility %v, want %v with utility %v", got.Allocation, got.TotalUtility, want.Allocation, want.TotalUtility)
+	}
+}
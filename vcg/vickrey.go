@@ -0,0 +1,41 @@
+package vcg
+
+// solveSingleItem is searchWithout for m == 1, where the auction is a
+// second-price (Vickrey) auction: the item goes to the highest bidder, with
+// agent 0 bidding the reserve price and the seller's bid on the item, and
+// the leave-one-out solves of the pricing make the winner pay the
+// second-highest bid. Ties are broken, and Evaluated, Nodes,
+// RunnerUpUtility and Margin set, as in the exhaustive search.
+func solveSingleItem(bs BidSet, n int, opts Options, excluded int) (s Solution) {
+	rank := opts.ranks(n)
+	a := newAllocation(n)
+	first_agent := 0
+	if opts.ForceFullAllocation || opts.RequireSale {
+		first_agent = 1
+	}
+	for agent := first_agent; agent <= n; agent++ {
+		if agent != Unassigned && agent == excluded || !opts.eligible(agent, 0) {
+			continue
+		}
+		a[agent][0] = true
+		total_utility := opts.utilityExceptAgent(a, bs, excluded)
+		s.Evaluated++
+		s.Nodes++
+		runner_up := total_utility
+		if s.Allocation == nil || total_utility > s.TotalUtility ||
+			total_utility == s.TotalUtility && a.lessRanked(s.Allocation, rank) {
+			runner_up = s.TotalUtility
+			s.Allocation = a.Copy()
+			s.TotalUtility = total_utility
+		}
+		if s.Evaluated == 2 || s.Evaluated > 2 && runner_up > s.RunnerUpUtility {
+			s.RunnerUpUtility = runner_up
+		}
+		delete(a[agent], 0)
+	}
+	if s.Evaluated >= 2 {
+		s.Margin = s.TotalUtility - s.RunnerUpUtility
+	}
+	s.Optimal = true
+	return
+}
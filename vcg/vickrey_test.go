@@ -0,0 +1,120 @@
+package vcg
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSingleItemMatchesSearch solves random single-item auctions with the
+// fast path and with the exhaustive search, and checks the winner pays the
+// second-highest bid.
+func TestSingleItemMatchesSearch(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		n := 1 + int(seed%5)
+		bs := randomBidSet(n, 1, seed)
+		for agent := 1; agent <= n; agent++ {
+			if (seed+int64(agent))%5 < 3 {
+				delete(bs[agent], 1)
+			}
+		}
+		opts := Options{}
+		if seed%3 == 0 {
+			opts.ReservePrices = []float64{0.5}
+		}
+		got := SolveAllocationWithOptions(bs, n, 1, opts)
+		want, _ := solve(context.Background(), n, 1, 0, opts, func(a Allocation, flags []int64) float64 {
+			return opts.utilityOfFlags(flags, bs, 0)
+		}, nil)
+		if !reflect.DeepEqual(got.Allocation.owners(), want.Allocation.owners()) || got.TotalUtility != want.TotalUtility || !got.Optimal {
+			t.Errorf("seed %d: got %v with utility %v, want %v with utility %v", seed, got.Allocation, got.TotalUtility, want.Allocation, want.TotalUtility)
+		}
+		if got.Evaluated != want.Evaluated || got.RunnerUpUtility != want.RunnerUpUtility || got.Margin != want.Margin {
+			t.Errorf("seed %d: evaluated %d, runner-up %v and margin %v, want %d, %v and %v", seed, got.Evaluated, got.RunnerUpUtility, got.Margin, want.Evaluated, want.RunnerUpUtility, want.Margin)
+		}
+
+		if err := got.CalculatePricesWithOptions(bs, n, 1, opts); err != nil {
+			t.Fatal(err)
+		}
+		winner, first, second := Unassigned, 0.0, 0.0
+		if opts.ReservePrices != nil {
+			first, second = opts.ReservePrices[0], opts.ReservePrices[0]
+		}
+		for agent := 1; agent <= n; agent++ {
+			if v := bs[agent][1]; v > first {
+				winner, first, second = agent, v, first
+			} else if v > second {
+				second = v
+			}
+		}
+		for agent := 1; agent <= n; agent++ {
+			want := 0.0
+			if agent == winner {
+				want = second
+			}
+			if got.PricePerAgent[agent] != want {
+				t.Errorf("seed %d: agent %d pays %v, want %v", seed, agent, got.PricePerAgent[agent], want)
+			}
+		}
+	}
+}
+
+// TestSingleItemHooks checks a single-item auction still reports progress,
+// logs and stops on a done context, which the fast path does not do, and
+// finds the same solution.
+func TestSingleItemHooks(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 3}, Bid{0x1: 5}, Bid{0x1: 4}}
+	want := SolveAllocation(bs, 3, 1)
+
+	var last, total int64
+	s := SolveAllocationWithOptions(bs, 3, 1, Options{OnProgress: func(visited, estimated int64) {
+		last, total = visited, estimated
+	}})
+	if total != 4 || last != total {
+		t.Errorf("last report %d of %d allocations, want 4 of 4", last, total)
+	}
+	if s.TotalUtility != want.TotalUtility || !reflect.DeepEqual(s.Allocation.owners(), want.Allocation.owners()) {
+		t.Errorf("with progress got %v worth %v, want %v worth %v", s.Allocation, s.TotalUtility, want.Allocation, want.TotalUtility)
+	}
+
+	var buf bytes.Buffer
+	s = SolveAllocationWithOptions(bs, 3, 1, Options{Logger: log.New(&buf, "", 0)})
+	if !strings.Contains(buf.String(), "Considering allocation") {
+		t.Errorf("logged %q, want the allocations considered", buf.String())
+	}
+	if s.TotalUtility != want.TotalUtility {
+		t.Errorf("with a logger got utility %v, want %v", s.TotalUtility, want.TotalUtility)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SolveAllocationContext(ctx, bs, 3, 1); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestRunnerUp checks the runner-up and margin of problem1, whose next best
+// allocations are worth 12, and of single items sold with and without a
+// tie, or to nobody but the seller.
+func TestRunnerUp(t *testing.T) {
+	tests := []struct {
+		bs                BidSet
+		n, m              int
+		evaluated         int64
+		runner_up, margin float64
+	}{
+		{problem1Bids(), 4, 4, 625, 12, 1},
+		{BidSet{nil, Bid{0x1: 5}, Bid{0x1: 3}}, 2, 1, 3, 3, 2},
+		{BidSet{nil, Bid{0x1: 4}, Bid{0x1: 4}}, 2, 1, 3, 4, 0},
+		{BidSet{nil}, 0, 1, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		s := SolveAllocationWithOptions(tt.bs, tt.n, tt.m, Options{Sequential: true})
+		if s.Evaluated != tt.evaluated || s.RunnerUpUtility != tt.runner_up || s.Margin != tt.margin {
+			t.Errorf("%v: evaluated %d, runner-up %v and margin %v, want %d, %v and %v", tt.bs, s.Evaluated, s.RunnerUpUtility, s.Margin, tt.evaluated, tt.runner_up, tt.margin)
+		}
+	}
+}
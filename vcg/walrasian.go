@@ -0,0 +1,65 @@
+package vcg
+
+// WalrasianPrices returns the lowest per-item prices, indexed by item, at
+// which every agent 1..n demands exactly its bundle of sol: no bundle is
+// worth more to it than its own, net of the prices. Unsold items cost 0.
+// For gross substitutes valuations such prices exist whenever sol is
+// optimal and the lowest ones are unique; otherwise there may be none, and
+// ok is false.
+//
+// The prices are found by linear programming over all 2^m bundles of every
+// agent, so m should be small. Minimizing the sum of the prices subject to
+// p(S) - p(Sᵢ) >= vᵢ(S) - vᵢ(Sᵢ) for every agent i and bundle S has a dual
+// whose origin is feasible, and the prices are its shadow prices.
+func WalrasianPrices(bs BidSet, sol Solution, n, m int) (prices []float64, ok bool) {
+	vs := bs.Valuations()
+	prices = make([]float64, m)
+	held := make([]int64, n+1)
+	var sold int64
+	for agent := 1; agent <= n; agent++ {
+		held[agent] = sol.Allocation.Flags(agent)
+		sold |= held[agent]
+	}
+
+	// one row per sold item, one column per agent and bundle
+	var items []int
+	for item := 0; item < m; item++ {
+		if sold&(1<<uint(item)) != 0 {
+			items = append(items, item)
+		}
+	}
+	a := make([][]float64, len(items))
+	b := make([]float64, len(items))
+	var c []float64
+	for row := range items {
+		b[row] = 1
+	}
+	for agent := 1; agent <= n; agent++ {
+		own := vs[agent].Value(held[agent])
+		for bundle := int64(0); bundle <= allItems(m); bundle++ {
+			if bundle == held[agent] {
+				continue
+			}
+			for row, item := range items {
+				bit := int64(1) << uint(item)
+				var coefficient float64
+				if bundle&bit != 0 {
+					coefficient++
+				}
+				if held[agent]&bit != 0 {
+					coefficient--
+				}
+				a[row] = append(a[row], coefficient)
+			}
+			c = append(c, vs[agent].Value(bundle)-own)
+		}
+	}
+	_, dual, ok := maximizeLPDual(a, b, c)
+	if !ok {
+		return nil, false
+	}
+	for row, item := range items {
+		prices[item] = dual[row]
+	}
+	return prices, true
+}
@@ -0,0 +1,58 @@
+package vcg
+
+import (
+	"math"
+	"testing"
+)
+
+// TestWalrasianPrices prices two unit-demand agents, which are gross
+// substitutes: agent 1 values item 0 by 5 and item 1 by 3, agent 2 by 4 and
+// 1. Agent 1 gets item 1 and agent 2 item 0 for 7, and the lowest prices
+// supporting that are the VCG prices, 2 for item 0 and nothing for item 1.
+func TestWalrasianPrices(t *testing.T) {
+	bs := BidSet{nil, Bid{0x1: 5, 0x2: 3, 0x3: 5}, Bid{0x1: 4, 0x2: 1, 0x3: 4}}
+	s := SolveAllocation(bs, 2, 2)
+	if got := s.Allocation.String(); got != "agent1:{item1} agent2:{item0}" {
+		t.Fatalf("got %s", got)
+	}
+	prices, ok := WalrasianPrices(bs, s, 2, 2)
+	if !ok {
+		t.Fatal("no prices found")
+	}
+	for item, want := range []float64{2, 0} {
+		if math.Abs(prices[item]-want) > priceTolerance {
+			t.Errorf("item %d costs %v, want %v", item, prices[item], want)
+		}
+	}
+	for agent := 1; agent <= 2; agent++ {
+		surplus := func(bundle int64) (u float64) {
+			u = bs[agent].ValueOf(bundle)
+			for item := 0; item < 2; item++ {
+				if bundle&(1<<uint(item)) != 0 {
+					u -= prices[item]
+				}
+			}
+			return
+		}
+		own := s.Allocation.Flags(agent)
+		for bundle := int64(0); bundle < 4; bundle++ {
+			if surplus(bundle) > surplus(own)+priceTolerance {
+				t.Errorf("agent %d prefers %b, worth %v, to its own %b, worth %v", agent, bundle, surplus(bundle), own, surplus(own))
+			}
+		}
+	}
+}
+
+// TestWalrasianPricesComplements checks no prices support selling both
+// items to an agent wanting only the pair, for 3, over another paying up to
+// 2 for either: that agent would demand an item unless each cost 2.
+func TestWalrasianPricesComplements(t *testing.T) {
+	bs := BidSet{nil, Bid{0x3: 3}, Bid{0x1: 2, 0x2: 2, 0x3: 2}}
+	s := SolveAllocation(bs, 2, 2)
+	if got := s.Allocation.String(); got != "agent1:{item0,item1}" {
+		t.Fatalf("got %s", got)
+	}
+	if prices, ok := WalrasianPrices(bs, s, 2, 2); ok {
+		t.Errorf("got prices %v", prices)
+	}
+}